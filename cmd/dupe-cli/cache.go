@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tendant/dupe-cli/internal/cache"
+	"github.com/tendant/dupe-cli/internal/cli"
+	"github.com/tendant/dupe-cli/internal/units"
+)
+
+// cacheCommand returns the cache command
+func cacheCommand() *cli.Command {
+	cacheFile := cache.DefaultPath()
+	return &cli.Command{
+		Name:  "cache",
+		Group: "Maintenance commands",
+		Usage: []string{
+			"cache info     Show the location and size of the digest cache",
+			"cache prune    Remove the entries of files that are gone or have changed",
+			"cache clear    Remove all entries",
+		},
+		Summary: "Manage the digest cache",
+		Description: `The digest cache keeps the digests calculated by 'dupe-cli scan --cache', so
+that files unchanged since (same size, modification time and inode) are not
+hashed again.`,
+		Flags: func(fs *cli.FlagSet) {
+			fs.Group("Cache flags")
+			fs.StringVar(&cacheFile, "cache-file", "", "Digest cache file")
+		},
+		Run: func(_ *cli.FlagSet, args []string) int {
+			return runCache(args, cacheFile)
+		},
+	}
+}
+
+// runCache manages the digest cache
+func runCache(args []string, cacheFile string) int {
+	if len(args) == 0 {
+		return usageError(fmt.Errorf("no cache command specified"), "cache")
+	}
+	if len(args) > 1 {
+		return usageError(fmt.Errorf("unexpected argument: %s", args[1]), "cache")
+	}
+
+	c, err := cache.Load(cacheFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitFatal
+	}
+
+	switch args[0] {
+	case "info":
+		fmt.Printf("Cache file: %s\n", c.Path())
+		fmt.Printf("Entries: %d\n", c.Len())
+		if info, err := os.Stat(c.Path()); err == nil {
+			fmt.Printf("Size: %s\n", units.FormatSize(info.Size()))
+		}
+		return ExitOK
+
+	case "prune":
+		removed := c.Prune()
+		fmt.Printf("Removed %d entries, %d left\n", removed, c.Len())
+
+	case "clear":
+		removed := c.Len()
+		c.Clear()
+		fmt.Printf("Removed %d entries\n", removed)
+
+	default:
+		return usageError(fmt.Errorf("unknown cache command: %s", args[0]), "cache")
+	}
+
+	if err := c.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: error saving cache: %v\n", err)
+		return ExitFatal
+	}
+	return ExitOK
+}
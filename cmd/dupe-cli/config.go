@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tendant/dupe-cli/internal/cli"
+	"github.com/tendant/dupe-cli/internal/config"
+)
+
+// configure returns the function that sets the flags of a command that
+// were not given on the command line from the environment, the selected
+// profile and the config files
+func configure(app *cli.App) func(cmd *cli.Command, fs *cli.FlagSet) error {
+	return func(cmd *cli.Command, fs *cli.FlagSet) error {
+		if cmd.Flags == nil {
+			return nil
+		}
+		cfg, err := loadConfig(app)
+		if err != nil {
+			return err
+		}
+		settings, _, err := resolveSettings(cfg, fs)
+		if err != nil {
+			return err
+		}
+
+		for name, setting := range settings {
+			if err := fs.SetDefault(name, setting.Value); err != nil {
+				return fmt.Errorf("%s: %w", setting.Source, err)
+			}
+		}
+		return nil
+	}
+}
+
+// loadConfig reads the config files and checks that their settings are
+// flags of some command
+func loadConfig(app *cli.App) (*config.Config, error) {
+	cfg, err := config.Load(config.DefaultPaths()...)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool)
+	for _, cmd := range app.Commands {
+		for _, flag := range commandFlags(cmd).Flags() {
+			known[flag.Name] = true
+		}
+	}
+
+	check := func(settings map[string]config.Setting) error {
+		for key, setting := range settings {
+			if !known[key] {
+				return fmt.Errorf("%s: unknown setting %q", setting.Source, key)
+			}
+		}
+		return nil
+	}
+	if err := check(cfg.Defaults); err != nil {
+		return nil, err
+	}
+	for _, name := range cfg.ProfileNames() {
+		if err := check(cfg.Profiles[name]); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// commandFlags returns a new flag set with the flags of a command
+func commandFlags(cmd *cli.Command) *cli.FlagSet {
+	fs := cli.NewFlagSet()
+	if cmd.Flags != nil {
+		cmd.Flags(fs)
+	}
+	return fs
+}
+
+// resolveSettings returns the values of the flags of a command that come
+// from the config files, the profile and environment variables, in
+// increasing order of precedence, and the selected profile. Flags given on
+// the command line are left out.
+func resolveSettings(cfg *config.Config, fs *cli.FlagSet) (map[string]config.Setting, string, error) {
+	settings := make(map[string]config.Setting)
+	for key, setting := range cfg.Defaults {
+		if fs.Lookup(key) != nil {
+			settings[key] = setting
+		}
+	}
+
+	env := make(map[string]config.Setting)
+	for _, flag := range fs.Flags() {
+		name := config.EnvName(flag.Name)
+		if value, ok := os.LookupEnv(name); ok {
+			env[flag.Name] = config.Setting{Value: value, Source: "environment variable " + name}
+		}
+	}
+
+	// The profile is selected by the same precedence as the settings
+	profile := ""
+	if flag := fs.Lookup("profile"); flag != nil {
+		if setting, ok := env["profile"]; ok {
+			profile = setting.Value
+		} else if setting, ok := settings["profile"]; ok {
+			profile = setting.Value
+		}
+		if fs.Changed("profile") {
+			profile = flag.Value.String()
+		}
+	}
+	if profile != "" {
+		values, err := cfg.Profile(profile)
+		if err != nil {
+			return nil, "", err
+		}
+		for key, setting := range values {
+			if fs.Lookup(key) != nil {
+				setting.Source = fmt.Sprintf("profile %s (%s)", profile, setting.Source)
+				settings[key] = setting
+			}
+		}
+	}
+
+	for key, setting := range env {
+		settings[key] = setting
+	}
+	for key := range settings {
+		if fs.Changed(key) {
+			delete(settings, key)
+		}
+	}
+	return settings, profile, nil
+}
+
+// configCommand returns the config command
+func configCommand(app *cli.App) *cli.Command {
+	var profile string
+	return &cli.Command{
+		Name:    "config",
+		Group:   "Maintenance commands",
+		Usage:   []string{"config show [COMMAND] [flags]"},
+		Summary: "Show the effective settings",
+		Description: fmt.Sprintf(`Show the effective settings of a command (scan by default) and where each
+comes from. Settings are read from the config files %s,
+in increasing order of precedence. Settings before the first section set
+default flag values by their long names; [profile NAME] sections hold the
+settings selected with --profile NAME. Environment variables (%sSCAN_TYPE
+for --scan-type) override the profile, and flags override everything.`,
+			strings.Join(config.DefaultPaths(), " and "), config.EnvPrefix),
+		Examples: `  # ~/.config/dupe-cli/config
+  recursive = true
+  progress = log
+
+  [profile photos]
+  directories = ~/Pictures
+  reference = /backup/photos
+  scan-type = content
+  exclude = *.xmp,Thumbs.db
+  keep = oldest
+
+  dupe-cli scan --profile photos
+  dupe-cli config show --profile photos`,
+		Flags: func(fs *cli.FlagSet) {
+			fs.Group("Config flags")
+			fs.StringVar(&profile, "profile", "", "Show the settings of this profile")
+		},
+		Run: func(fs *cli.FlagSet, args []string) int {
+			// Only a profile given on the command line selects one to show
+			if !fs.Changed("profile") {
+				profile = ""
+			}
+			return runConfig(app, args, profile)
+		},
+	}
+}
+
+// runConfig shows the effective settings of a command
+func runConfig(app *cli.App, args []string, profile string) int {
+	if len(args) == 0 || args[0] != "show" {
+		return usageError(fmt.Errorf("expected 'config show'"), "config")
+	}
+	name := "scan"
+	if len(args) > 1 {
+		name = args[1]
+	}
+	if len(args) > 2 {
+		return usageError(fmt.Errorf("unexpected argument: %s", args[2]), "config")
+	}
+	cmd := app.Lookup(name)
+	if cmd == nil {
+		return usageError(fmt.Errorf("unknown command: %s", name), "config")
+	}
+
+	cfg, err := loadConfig(app)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitFatal
+	}
+
+	fs := commandFlags(cmd)
+	if profile != "" {
+		if fs.Lookup("profile") == nil {
+			return usageError(fmt.Errorf("the %s command has no profiles", name), "config")
+		}
+		if err := fs.Set("profile", profile); err != nil {
+			return usageError(err, "config")
+		}
+	}
+	settings, selected, err := resolveSettings(cfg, fs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitFatal
+	}
+	if profile != "" {
+		settings["profile"] = config.Setting{Value: profile, Source: "flag"}
+	}
+
+	fmt.Println("Config files:")
+	for _, path := range config.DefaultPaths() {
+		status := "not found"
+		for _, file := range cfg.Files {
+			if file == path {
+				status = "read"
+			}
+		}
+		fmt.Printf("  %s (%s)\n", path, status)
+	}
+	if names := cfg.ProfileNames(); len(names) > 0 {
+		fmt.Printf("Profiles: %s\n", strings.Join(names, ", "))
+	}
+	if selected != "" {
+		fmt.Printf("Selected profile: %s\n", selected)
+	}
+
+	fmt.Printf("\nSettings of %s:\n", name)
+	flags := fs.Flags()
+	width := 0
+	for _, flag := range flags {
+		if len(flag.Name) > width {
+			width = len(flag.Name)
+		}
+	}
+	for _, flag := range flags {
+		source := "default"
+		if setting, ok := settings[flag.Name]; ok {
+			if err := fs.SetDefault(flag.Name, setting.Value); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s: %v\n", setting.Source, err)
+				return ExitFatal
+			}
+			source = setting.Source
+		}
+		value := flag.Value.String()
+		if value == "" {
+			value = "-"
+		}
+		fmt.Printf("  %-*s  %-20s  %s\n", width, flag.Name, value, source)
+	}
+	return ExitOK
+}
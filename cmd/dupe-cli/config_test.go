@@ -0,0 +1,151 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tendant/dupe-cli/internal/cli"
+	"github.com/tendant/dupe-cli/internal/config"
+)
+
+// configFlags creates the flag set the precedence tests resolve
+func configFlags() *cli.FlagSet {
+	var scanType, keep, exclude, output, profile string
+	fs := cli.NewFlagSet()
+	fs.StringVar(&scanType, "scan-type", "", "Scan type")
+	fs.StringVar(&keep, "keep", "", "Keep policy")
+	fs.StringVar(&exclude, "exclude", "", "Exclude patterns")
+	fs.StringVar(&output, "output", "", "Output format")
+	fs.StringVar(&profile, "profile", "", "Profile")
+	return fs
+}
+
+func TestResolveSettings(t *testing.T) {
+	cfg := &config.Config{
+		Defaults: map[string]config.Setting{
+			"scan-type": {Value: "name", Source: "config:1"},
+			"keep":      {Value: "first", Source: "config:2"},
+			"exclude":   {Value: "*.tmp", Source: "config:3"},
+			"output":    {Value: "text", Source: "config:4"},
+			"unrelated": {Value: "x", Source: "config:5"},
+		},
+		Profiles: map[string]map[string]config.Setting{
+			"photos": {
+				"scan-type": {Value: "content", Source: "config:8"},
+				"keep":      {Value: "oldest", Source: "config:9"},
+				"exclude":   {Value: "*.xmp", Source: "config:10"},
+			},
+			"music": {"scan-type": {Value: "tag", Source: "config:13"}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		want    map[string]string
+		profile string
+	}{
+		{
+			name: "defaults",
+			want: map[string]string{"scan-type": "name", "keep": "first", "exclude": "*.tmp", "output": "text"},
+		},
+		{
+			name:    "profile over defaults",
+			args:    []string{"--profile", "photos"},
+			want:    map[string]string{"scan-type": "content", "keep": "oldest", "exclude": "*.xmp", "output": "text"},
+			profile: "photos",
+		},
+		{
+			name:    "environment over profile",
+			args:    []string{"--profile", "photos"},
+			env:     map[string]string{"DUPE_CLI_KEEP": "newest"},
+			want:    map[string]string{"scan-type": "content", "keep": "newest", "exclude": "*.xmp", "output": "text"},
+			profile: "photos",
+		},
+		{
+			name:    "flags over environment",
+			args:    []string{"--profile", "photos", "--keep", "shortest-path", "--output", "json"},
+			env:     map[string]string{"DUPE_CLI_KEEP": "newest", "DUPE_CLI_OUTPUT": "csv"},
+			want:    map[string]string{"scan-type": "content", "exclude": "*.xmp"},
+			profile: "photos",
+		},
+		{
+			name:    "profile from the environment",
+			env:     map[string]string{"DUPE_CLI_PROFILE": "music"},
+			want:    map[string]string{"scan-type": "tag", "keep": "first", "exclude": "*.tmp", "output": "text", "profile": "music"},
+			profile: "music",
+		},
+		{
+			name:    "profile flag over the environment",
+			args:    []string{"--profile", "photos"},
+			env:     map[string]string{"DUPE_CLI_PROFILE": "music"},
+			want:    map[string]string{"scan-type": "content", "keep": "oldest", "exclude": "*.xmp", "output": "text"},
+			profile: "photos",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			fs := configFlags()
+			if _, err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			settings, profile, err := resolveSettings(cfg, fs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]string)
+			for key, setting := range settings {
+				got[key] = setting.Value
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got settings %v, want %v", got, tt.want)
+			}
+			if profile != tt.profile {
+				t.Errorf("got profile %q, want %q", profile, tt.profile)
+			}
+		})
+	}
+}
+
+func TestResolveSettingsSources(t *testing.T) {
+	cfg := &config.Config{
+		Defaults: map[string]config.Setting{"profile": {Value: "photos", Source: "config:1"}},
+		Profiles: map[string]map[string]config.Setting{
+			"photos": {"keep": {Value: "oldest", Source: "config:4"}},
+		},
+	}
+	t.Setenv("DUPE_CLI_OUTPUT", "json")
+
+	settings, _, err := resolveSettings(cfg, configFlags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"profile": "config:1",
+		"keep":    "profile photos (config:4)",
+		"output":  "environment variable DUPE_CLI_OUTPUT",
+	}
+	got := make(map[string]string)
+	for key, setting := range settings {
+		got[key] = setting.Source
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got sources %v, want %v", got, want)
+	}
+}
+
+func TestResolveSettingsUnknownProfile(t *testing.T) {
+	fs := configFlags()
+	if _, err := fs.Parse([]string{"--profile", "videos"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Profiles: map[string]map[string]config.Setting{}}
+	if _, _, err := resolveSettings(cfg, fs); err == nil {
+		t.Error("resolveSettings with an unknown profile succeeded")
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+)
+
+// csvColumns are the columns that CSV output can have
+var csvColumns = []string{"group", "role", "path", "size", "mtime", "digest", "percentage", "inode", "reason"}
+
+// defaultCSVColumns are the columns of CSV output unless --columns is given
+var defaultCSVColumns = []string{"group", "role", "path", "size", "percentage"}
+
+// parseColumns parses a comma-separated list of CSV columns
+func parseColumns(value string) ([]string, error) {
+	var columns []string
+	for _, column := range strings.Split(value, ",") {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if column == "" {
+			continue
+		}
+		if !isCSVColumn(column) {
+			return nil, fmt.Errorf("invalid column: %s (valid columns: %s)", column, strings.Join(csvColumns, ", "))
+		}
+		columns = append(columns, column)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns specified")
+	}
+	return columns, nil
+}
+
+// columnsValue is the value of the --columns flag
+type columnsValue []string
+
+func (v *columnsValue) String() string { return strings.Join(*v, ",") }
+func (v *columnsValue) Type() string   { return "list" }
+
+func (v *columnsValue) Set(s string) error {
+	columns, err := parseColumns(s)
+	if err != nil {
+		return err
+	}
+	*v = columns
+	return nil
+}
+
+// isCSVColumn reports whether column is a supported CSV column
+func isCSVColumn(column string) bool {
+	for _, c := range csvColumns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// outputCSV outputs results in CSV format, one row per file, and writes the
+// summary and errors to a separate CSV file if opts has one. Without one,
+// errors are written to stderr, as rows of another shape would break the
+// CSV output.
+func outputCSV(groups []*engine.DuplicateGroup, errs []*fs.PathError, scanTime time.Duration, opts outputOptions) error {
+	columns := opts.Columns
+	if columns == nil {
+		columns = defaultCSVColumns
+	}
+	keep := engine.KeepPolicy(scanParams(opts.Params).Keep)
+	if err := writeCSV(os.Stdout, groups, columns, keep); err != nil {
+		return err
+	}
+
+	if opts.SummaryFile != "" {
+		return writeCSVSummary(opts.SummaryFile, groups, errs, scanTime)
+	}
+	if len(errs) > 0 {
+		writeErrors(os.Stderr, errs)
+	}
+	return nil
+}
+
+// writeCSV writes the rows of the files of duplicate groups, after a header
+// row of the columns
+func writeCSV(out io.Writer, groups []*engine.DuplicateGroup, columns []string, keep engine.KeepPolicy) error {
+	w := newCSVWriter(out)
+	if err := w.Write(columns); err != nil {
+		return err
+	}
+
+	row := make([]string, len(columns))
+	for i, group := range groups {
+		for j, column := range columns {
+			row[j] = csvValue(column, i+1, group, group.Reference, 100, keep)
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+
+		for k, dupe := range group.Duplicates {
+			for j, column := range columns {
+				row[j] = csvValue(column, i+1, group, dupe, group.Matches[k].Percentage, keep)
+			}
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// csvValue returns the value of a column for a file of a group
+func csvValue(column string, groupNum int, group *engine.DuplicateGroup, file *fs.File, percentage int, keep engine.KeepPolicy) string {
+	switch column {
+	case "group":
+		return strconv.Itoa(groupNum)
+	case "role":
+		if file == group.Reference {
+			return "reference"
+		}
+		return "duplicate"
+	case "path":
+		return file.Path
+	case "size":
+		return strconv.FormatInt(file.Size, 10)
+	case "mtime":
+		return file.ModTime.Format(time.RFC3339)
+	case "digest":
+		return hex.EncodeToString(file.Digest)
+	case "percentage":
+		return strconv.Itoa(percentage)
+	case "inode":
+		if file.Inode == 0 {
+			return ""
+		}
+		return strconv.FormatUint(file.Inode, 10)
+	case "reason":
+		return matchReason(group, file, keep)
+	default:
+		return ""
+	}
+}
+
+// matchReason describes why a file is in its group: why the reference was
+// chosen by the keep policy, or how a duplicate matches the reference
+func matchReason(group *engine.DuplicateGroup, file *fs.File, keep engine.KeepPolicy) string {
+	ref := group.Reference
+	switch {
+	case file == ref && ref.IsReference:
+		return "reference-dir"
+	case file == ref && keep != "" && keep != engine.KeepFirst:
+		return string(keep)
+	case file == ref:
+		return "first-found"
+	case file.SameInode(ref):
+		return "hard-link"
+	case file.Digest != nil && bytes.Equal(file.Digest, ref.Digest):
+		return "same-content"
+	default:
+		return "similar-name"
+	}
+}
+
+// writeCSVSummary writes the totals of a scan and the paths that could not
+// be read to a CSV file
+func writeCSVSummary(path string, groups []*engine.DuplicateGroup, errs []*fs.PathError, scanTime time.Duration) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating summary file: %w", err)
+	}
+
+	w := newCSVWriter(f)
+	rows := [][]string{
+		{"field", "value", "category", "message"},
+		{"scan_time", scanTime.String(), "", ""},
+		{"group_count", strconv.Itoa(len(groups)), "", ""},
+		{"duplicate_count", strconv.Itoa(engine.TotalDuplicateCount(groups)), "", ""},
+		{"total_size", strconv.FormatInt(engine.TotalDuplicateSize(groups), 10), "", ""},
+		{"error_count", strconv.Itoa(len(errs)), "", ""},
+	}
+	for _, pathErr := range errs {
+		rows = append(rows, []string{"error", pathErr.Path, pathErr.Category.String(), pathErr.Err.Error()})
+	}
+
+	if err := w.WriteAll(rows); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing summary file: %w", err)
+	}
+	return f.Close()
+}
+
+// csvWriter writes CSV records with the CRLF line endings of RFC 4180.
+// Unlike a csv.Writer with UseCRLF, it leaves the line breaks and carriage
+// returns in fields as they are.
+type csvWriter struct {
+	out    *bufio.Writer
+	record bytes.Buffer // Record being written, ended by csv.Writer with LF
+	csv    *csv.Writer
+	err    error // First error writing to out
+}
+
+// newCSVWriter creates a CSV writer with the CRLF line endings of RFC 4180
+func newCSVWriter(out io.Writer) *csvWriter {
+	w := &csvWriter{out: bufio.NewWriter(out)}
+	w.csv = csv.NewWriter(&w.record)
+	return w
+}
+
+// Write writes a record. Records are buffered; Flush writes them out.
+func (w *csvWriter) Write(record []string) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	w.record.Reset()
+	if err := w.csv.Write(record); err != nil {
+		return err
+	}
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		return err
+	}
+
+	line := w.record.Bytes()
+	line = append(line[:len(line)-1], '\r', '\n')
+	_, w.err = w.out.Write(line)
+	return w.err
+}
+
+// WriteAll writes records and flushes them
+func (w *csvWriter) WriteAll(records [][]string) error {
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// Flush writes the buffered records out
+func (w *csvWriter) Flush() {
+	if w.err == nil {
+		w.err = w.out.Flush()
+	}
+}
+
+// Error returns the first error writing records out
+func (w *csvWriter) Error() error {
+	return w.err
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+)
+
+func TestParseColumns(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{"path", []string{"path"}, false},
+		{"Group, PATH ,size", []string{"group", "path", "size"}, false},
+		{"path,,size,", []string{"path", "size"}, false},
+		{"path,name", nil, true},
+		{" , ", nil, true},
+		{"", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseColumns(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseColumns(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseColumns(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCSVValue(t *testing.T) {
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ref := &fs.File{Path: "/a/x", Size: 3, ModTime: mtime, Digest: []byte{0xab}, Dev: 1, Inode: 7}
+	link := &fs.File{Path: "/b/x", Size: 3, Digest: []byte{0xab}, Dev: 1, Inode: 7}
+	copied := &fs.File{Path: "/c/x", Size: 3, Digest: []byte{0xab}, Dev: 1, Inode: 8}
+	similar := &fs.File{Path: "/d/x", Size: 4}
+	group := newGroup(ref, link, copied, similar)
+
+	tests := []struct {
+		column string
+		file   *fs.File
+		want   string
+	}{
+		{"group", ref, "2"},
+		{"role", ref, "reference"},
+		{"role", copied, "duplicate"},
+		{"path", copied, "/c/x"},
+		{"size", similar, "4"},
+		{"mtime", ref, "2024-05-01T12:00:00Z"},
+		{"digest", ref, "ab"},
+		{"digest", similar, ""},
+		{"percentage", copied, "80"},
+		{"inode", ref, "7"},
+		{"inode", similar, ""},
+		{"reason", ref, "first-found"},
+		{"reason", link, "hard-link"},
+		{"reason", copied, "same-content"},
+		{"reason", similar, "similar-name"},
+	}
+	for _, tt := range tests {
+		if got := csvValue(tt.column, 2, group, tt.file, 80, engine.KeepFirst); got != tt.want {
+			t.Errorf("csvValue(%s, %s) = %q, want %q", tt.column, tt.file.Path, got, tt.want)
+		}
+	}
+}
+
+func TestMatchReasonOfReference(t *testing.T) {
+	tests := []struct {
+		reference bool
+		keep      engine.KeepPolicy
+		want      string
+	}{
+		{false, "", "first-found"},
+		{false, engine.KeepFirst, "first-found"},
+		{false, engine.KeepOldest, "oldest"},
+		{true, engine.KeepOldest, "reference-dir"},
+	}
+	for _, tt := range tests {
+		ref := &fs.File{Path: "/a", IsReference: tt.reference}
+		group := newGroup(ref, &fs.File{Path: "/b"})
+		if got := matchReason(group, ref, tt.keep); got != tt.want {
+			t.Errorf("matchReason(reference %v, keep %q) = %q, want %q", tt.reference, tt.keep, got, tt.want)
+		}
+	}
+}
+
+func TestWriteCSVSummary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "summary.csv")
+	groups := []*engine.DuplicateGroup{newGroup(&fs.File{Path: "/a", Size: 4}, &fs.File{Path: "/b", Size: 4})}
+	errs := []*fs.PathError{{Path: "/c", Category: fs.ErrorVanished, Err: errors.New("no such file")}}
+	if err := writeCSVSummary(path, groups, errs, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"field", "value", "category", "message"},
+		{"scan_time", "1s", "", ""},
+		{"group_count", "1", "", ""},
+		{"duplicate_count", "1", "", ""},
+		{"total_size", "4", "", ""},
+		{"error_count", "1", "", ""},
+		{"error", "/c", "vanished", "no such file"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %v, want %v", got, want)
+	}
+}
+
+func TestWriteCSVSpecialCharacters(t *testing.T) {
+	paths := []string{"/a/plain", "/a/car\rriage", "/a/line\nbreak", `/a/"quoted"`, "/a/comma,name", "/a/all\r\n\",\r"}
+	var files []*fs.File
+	for _, path := range paths {
+		files = append(files, &fs.File{Path: path})
+	}
+
+	var out bytes.Buffer
+	if err := writeCSV(&out, []*engine.DuplicateGroup{newGroup(files[0], files[1:]...)}, []string{"path"}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each record ends with CRLF, while the fields are left as they are
+	if !strings.HasSuffix(out.String(), "\"/a/all\r\n\"\",\r\"\r\n") {
+		t.Errorf("output doesn't end with the last path quoted and CRLF: %q", out.String())
+	}
+
+	got, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"path"}}
+	for _, path := range paths {
+		want = append(want, []string{path})
+	}
+	// csv.Reader reads a CRLF in a quoted field as LF
+	want[len(want)-1][0] = "/a/all\n\",\r"
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got rows %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/tendant/dupe-cli/internal/cli"
+	"github.com/tendant/dupe-cli/internal/results"
+	"github.com/tendant/dupe-cli/internal/units"
+)
+
+// diffCommand returns the diff command
+func diffCommand() *cli.Command {
+	format := "text"
+	return &cli.Command{
+		Name:    "diff",
+		Group:   "Results commands",
+		Usage:   []string{"diff OLD NEW [flags]"},
+		Summary: "Compare two saved results",
+		Description: `Compare results saved with 'dupe-cli scan --save FILE': groups that are new,
+groups that were resolved and groups whose files changed. Groups are paired
+by the files they share.`,
+		Flags: func(fs *cli.FlagSet) {
+			fs.Group("Output flags")
+			fs.EnumVar(&format, []string{"text", "json"}, "output", "o", "Output format")
+		},
+		Run: func(_ *cli.FlagSet, args []string) int {
+			return runDiff(args, format)
+		},
+	}
+}
+
+// runDiff compares two result files
+func runDiff(args []string, format string) int {
+	if len(args) != 2 {
+		return usageError(fmt.Errorf("diff needs two result files"), "diff")
+	}
+
+	before, err := results.Load(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitFatal
+	}
+	after, err := results.Load(args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitFatal
+	}
+
+	diff := results.Compare(before.Groups, after.Groups)
+	if format == "json" {
+		data, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return ExitFatal
+		}
+		fmt.Println(string(data))
+		return ExitOK
+	}
+
+	writeDiffText(diff, args[0], args[1], len(before.Groups), len(after.Groups))
+	return ExitOK
+}
+
+// writeDiffText prints a diff of results as text
+func writeDiffText(diff *results.Diff, oldFile, newFile string, oldCount, newCount int) {
+	fmt.Printf("Comparing %s (%d groups) with %s (%d groups)\n", oldFile, oldCount, newFile, newCount)
+	if diff.Empty() {
+		fmt.Println("\nNo differences.")
+		return
+	}
+
+	if len(diff.New) > 0 {
+		fmt.Printf("\nNew groups (%d):\n", len(diff.New))
+		for _, group := range diff.New {
+			printDiffGroup(group, "+")
+		}
+	}
+
+	if len(diff.Resolved) > 0 {
+		fmt.Printf("\nResolved groups (%d):\n", len(diff.Resolved))
+		for _, group := range diff.Resolved {
+			printDiffGroup(group, "-")
+		}
+	}
+
+	if len(diff.Changed) > 0 {
+		fmt.Printf("\nChanged groups (%d):\n", len(diff.Changed))
+		for _, change := range diff.Changed {
+			fmt.Printf("  %s (%s)\n", change.New.Reference.Path, units.FormatSize(change.New.Reference.Size))
+			for _, path := range change.Added {
+				fmt.Printf("    + %s\n", path)
+			}
+			for _, path := range change.Removed {
+				fmt.Printf("    - %s\n", path)
+			}
+		}
+	}
+
+	fmt.Printf("\nUnchanged groups: %d\n", diff.Unchanged)
+}
+
+// printDiffGroup prints the files of a new or resolved group
+func printDiffGroup(group results.Group, sign string) {
+	fmt.Printf("  %s %s (%s)\n", sign, group.Reference.Path, units.FormatSize(group.Reference.Size))
+	for _, dupe := range group.Duplicates {
+		fmt.Printf("      %s\n", dupe.Path)
+	}
+}
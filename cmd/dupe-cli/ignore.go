@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/tendant/dupe-cli/internal/cli"
+	"github.com/tendant/dupe-cli/internal/dupeguru"
+	"github.com/tendant/dupe-cli/internal/hash"
+	"github.com/tendant/dupe-cli/internal/ignore"
+)
+
+// ignoreCommand returns the ignore command
+func ignoreCommand() *cli.Command {
+	ignoreFile := ignore.DefaultPath()
+	return &cli.Command{
+		Name:  "ignore",
+		Group: "Maintenance commands",
+		Usage: []string{
+			"ignore add pair PATH PATH     Never report these two files as duplicates",
+			"ignore add digest HEX|FILE    Never report files with this content",
+			"ignore add glob PATTERN       Never report files matching this pattern",
+			"ignore list                   List the rules with their numbers",
+			"ignore remove N...            Remove rules by number",
+			"ignore import FILE            Import the pairs of a dupeGuru ignore list",
+		},
+		Summary: "Manage the ignore list",
+		Description: `Glob patterns without a path separator match file names, others match full paths.
+Digest rules apply to content scans, where the digests of files are known.`,
+		Flags: func(fs *cli.FlagSet) {
+			fs.Group("Ignore list flags")
+			fs.StringVar(&ignoreFile, "ignore-file", "", "Ignore list file")
+		},
+		Run: func(_ *cli.FlagSet, args []string) int {
+			return runIgnore(args, ignoreFile)
+		},
+	}
+}
+
+// runIgnore manages the ignore list
+func runIgnore(positional []string, ignoreFile string) int {
+	if len(positional) == 0 {
+		return usageError(fmt.Errorf("no ignore command specified"), "ignore")
+	}
+
+	list, err := ignore.Load(ignoreFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitFatal
+	}
+
+	command, args := positional[0], positional[1:]
+	switch command {
+	case "list":
+		if len(list.Rules) == 0 {
+			fmt.Printf("The ignore list %s is empty.\n", ignoreFile)
+			return ExitOK
+		}
+		for i, rule := range list.Rules {
+			fmt.Printf("%4d  %s\n", i+1, rule)
+		}
+		return ExitOK
+
+	case "add":
+		added, err := addIgnoreRule(list, args)
+		if err != nil {
+			return usageError(err, "ignore")
+		}
+		if !added {
+			fmt.Println("Already on the ignore list.")
+			return ExitOK
+		}
+
+	case "remove":
+		if len(args) == 0 {
+			return usageError(fmt.Errorf("no rule numbers specified"), "ignore")
+		}
+		// Remove from the highest number down so the other numbers stay valid
+		indexes := make([]int, 0, len(args))
+		for _, arg := range args {
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				return usageError(fmt.Errorf("invalid rule number: %s", arg), "ignore")
+			}
+			indexes = append(indexes, n-1)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(indexes)))
+		for i, index := range indexes {
+			if i > 0 && index == indexes[i-1] {
+				continue
+			}
+			if err := list.Remove(index); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return ExitFatal
+			}
+		}
+
+	case "import":
+		if len(args) != 1 {
+			return usageError(fmt.Errorf("import needs a dupeGuru ignore list file"), "ignore")
+		}
+		pairs, err := dupeguru.ReadIgnoreListFile(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return ExitFatal
+		}
+		added := 0
+		for _, pair := range pairs {
+			if list.AddPair(pair.First, pair.Second) {
+				added++
+			}
+		}
+		fmt.Printf("Imported %d of %d pairs\n", added, len(pairs))
+
+	default:
+		return usageError(fmt.Errorf("unknown ignore command: %s", command), "ignore")
+	}
+
+	if err := list.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: error saving ignore list: %v\n", err)
+		return ExitFatal
+	}
+	return ExitOK
+}
+
+// addIgnoreRule adds the rule described by the arguments of "ignore add"
+func addIgnoreRule(list *ignore.List, args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, fmt.Errorf("no rule type specified")
+	}
+
+	switch args[0] {
+	case "pair":
+		if len(args) != 3 {
+			return false, fmt.Errorf("pair rules need two paths")
+		}
+		return list.AddPair(args[1], args[2]), nil
+
+	case "digest":
+		if len(args) != 2 {
+			return false, fmt.Errorf("digest rules need a digest or a file")
+		}
+		// Take the digest of the file if the value names one
+		if _, err := os.Stat(args[1]); err == nil {
+			digest, err := hash.HashFile(args[1])
+			if err != nil {
+				return false, err
+			}
+			return list.AddDigest(digest), nil
+		}
+		digest, err := hex.DecodeString(args[1])
+		if err != nil || len(digest) == 0 {
+			return false, fmt.Errorf("invalid digest or file not found: %s", args[1])
+		}
+		return list.AddDigest(digest), nil
+
+	case "glob":
+		if len(args) != 2 {
+			return false, fmt.Errorf("glob rules need a pattern")
+		}
+		return list.AddGlob(args[1])
+
+	default:
+		return false, fmt.Errorf("unknown rule type: %s", args[0])
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tendant/dupe-cli/internal/results"
+)
+
+// incrementalOutput is the JSON output of an incremental scan: the diff
+// with the previous results, and totals of the scan
+type incrementalOutput struct {
+	ScanTime   string `json:"scan_time"`
+	Reused     int    `json:"reused_digests"` // Files whose digests were not calculated again
+	Kept       int    `json:"kept_sizes"`     // File sizes whose groups were kept, without comparing their files
+	GroupCount int    `json:"group_count"`
+	ErrorCount int    `json:"error_count"`
+	*results.Diff
+	Errors []results.Error `json:"errors,omitempty"`
+}
+
+// loadPrevious loads the results an incremental scan updates,
+// which must come from a scan of the same type. Missing results have no
+// groups, so that a first scan reports all of its groups as new.
+func loadPrevious(flags *Flags) (*results.ResultSet, error) {
+	previous, err := results.Load(flags.Incremental)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "Warning: %s doesn't exist, all groups are new\n", flags.Incremental)
+		return &results.ResultSet{Version: results.Version}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var params ScanParams
+	if len(previous.Params) > 0 {
+		if err := json.Unmarshal(previous.Params, &params); err != nil {
+			return nil, fmt.Errorf("invalid scan parameters in %s: %w", flags.Incremental, err)
+		}
+	}
+	if params.ScanType != "" && params.ScanType != flags.ScanType {
+		return nil, fmt.Errorf("%s was saved by a %s scan, not a %s scan", flags.Incremental, params.ScanType, flags.ScanType)
+	}
+	if strings.Join(params.Directories, "\x00") != strings.Join(flags.Directories, "\x00") {
+		fmt.Fprintf(os.Stderr, "Warning: %s was saved by a scan of other directories: %s\n",
+			flags.Incremental, strings.Join(params.Directories, ", "))
+	}
+	return previous, nil
+}
+
+// outputIncremental outputs what changed between the previous results and
+// those of the scan
+func outputIncremental(flags *Flags, previous, current *results.ResultSet, reused, kept int) error {
+	diff := results.Compare(previous.Groups, current.Groups)
+
+	if flags.Output.Format == "json" {
+		data, err := json.MarshalIndent(incrementalOutput{
+			ScanTime:   current.ScanTime,
+			Reused:     reused,
+			Kept:       kept,
+			GroupCount: len(current.Groups),
+			ErrorCount: len(current.Errors),
+			Diff:       diff,
+			Errors:     current.Errors,
+		}, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("\nScan completed in %s\n", current.ScanTime)
+	fmt.Printf("Reused the digests of %d unchanged files\n", reused)
+	fmt.Printf("Kept the groups of %d unchanged file sizes\n", kept)
+	if len(current.Errors) > 0 {
+		fmt.Println()
+		writeErrors(os.Stdout, current.PathErrors())
+	}
+	fmt.Println()
+	writeDiffText(diff, flags.Incremental, "this scan", len(previous.Groups), len(current.Groups))
+	return nil
+}
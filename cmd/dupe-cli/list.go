@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// loadPathLists reads the paths listed in the --from-file file and on stdin,
+// and adds them to the files to scan
+func loadPathLists(flags *Flags) error {
+	if flags.FromFile != "" {
+		f, err := os.Open(flags.FromFile)
+		if err != nil {
+			return err
+		}
+		paths, err := readPathList(f, flags.Null)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", flags.FromFile, err)
+		}
+		flags.Files = append(flags.Files, paths...)
+	}
+
+	if flags.Stdin {
+		paths, err := readPathList(os.Stdin, flags.Null)
+		if err != nil {
+			return fmt.Errorf("error reading stdin: %w", err)
+		}
+		flags.Files = append(flags.Files, paths...)
+	}
+	return nil
+}
+
+// readPathList reads a list of paths, one per line or, with null set,
+// separated by NUL characters as printed by find -print0. Empty entries are
+// skipped.
+func readPathList(r io.Reader, null bool) ([]string, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	if null {
+		sc.Split(scanNull)
+	}
+
+	var paths []string
+	for sc.Scan() {
+		path := sc.Text()
+		if !null {
+			path = strings.TrimSuffix(path, "\r")
+		}
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// scanNull is a bufio.SplitFunc that splits on NUL characters
+func scanNull(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadPathList(t *testing.T) {
+	long := strings.Repeat("d/", 50000) + "file"
+	tests := []struct {
+		name  string
+		input string
+		null  bool
+		want  []string
+	}{
+		{"lines", "a\nb c\n./d\n", false, []string{"a", "b c", "./d"}},
+		{"no final newline", "a\nb", false, []string{"a", "b"}},
+		{"CRLF", "a\r\nb\r\n", false, []string{"a", "b"}},
+		{"empty lines", "\na\n\n\nb\n", false, []string{"a", "b"}},
+		{"empty", "", false, nil},
+		{"long path", long + "\n", false, []string{long}},
+		{"NUL separated", "a\x00b\x00", true, []string{"a", "b"}},
+		{"NUL without final NUL", "a\x00b", true, []string{"a", "b"}},
+		{"NUL with newlines and CR in names", "a\nb\x00c\r\x00", true, []string{"a\nb", "c\r"}},
+		{"NUL empty entries", "\x00a\x00\x00", true, []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readPathList(strings.NewReader(tt.input), tt.null)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadPathLists(t *testing.T) {
+	dir := t.TempDir()
+	list := filepath.Join(dir, "list")
+	writeFile(t, list, "/from/file\x00/with space\x00")
+	stdin := filepath.Join(dir, "stdin")
+	writeFile(t, stdin, "/from/stdin\x00")
+
+	f, err := os.Open(stdin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	saved := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = saved }()
+
+	// Listed paths follow the files given as arguments
+	flags := &Flags{FromFile: list, Stdin: true, Null: true}
+	flags.Files = []string{"/argument"}
+	if err := loadPathLists(flags); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/argument", "/from/file", "/with space", "/from/stdin"}
+	if !reflect.DeepEqual(flags.Files, want) {
+		t.Errorf("got files %q, want %q", flags.Files, want)
+	}
+
+	missing := &Flags{FromFile: filepath.Join(dir, "missing")}
+	if err := loadPathLists(missing); err == nil {
+		t.Error("loadPathLists of a missing list succeeded")
+	}
+}
@@ -3,14 +3,30 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
-	"strconv"
+	"runtime"
 	"strings"
 	"time"
 
+	"github.com/tendant/dupe-cli/internal/cache"
+	"github.com/tendant/dupe-cli/internal/checkpoint"
+	"github.com/tendant/dupe-cli/internal/cli"
+	"github.com/tendant/dupe-cli/internal/compat"
+	"github.com/tendant/dupe-cli/internal/dirsummary"
+	"github.com/tendant/dupe-cli/internal/dupeguru"
 	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+	"github.com/tendant/dupe-cli/internal/gitignore"
+	"github.com/tendant/dupe-cli/internal/glob"
+	"github.com/tendant/dupe-cli/internal/htmlreport"
+	"github.com/tendant/dupe-cli/internal/ignore"
 	"github.com/tendant/dupe-cli/internal/matcher"
+	"github.com/tendant/dupe-cli/internal/results"
 	"github.com/tendant/dupe-cli/internal/scanner"
+	"github.com/tendant/dupe-cli/internal/sqlite"
+	"github.com/tendant/dupe-cli/internal/tmplreport"
+	"github.com/tendant/dupe-cli/internal/units"
 )
 
 // Version information
@@ -18,203 +34,481 @@ const (
 	Version = "0.1.0"
 )
 
+// Exit codes
+const (
+	ExitOK     = 0 // Scan completed without errors
+	ExitFatal  = 1 // Invalid arguments or the scan was aborted
+	ExitErrors = 2 // Scan completed, but some paths could not be read
+)
+
+// Scan parameters, saved with checkpoints so a scan can be resumed
+type ScanParams struct {
+	Directories    []string `json:"directories"`
+	Files          []string `json:"files,omitempty"`
+	Recursive      bool     `json:"recursive"`
+	ExcludePattern string   `json:"exclude_pattern,omitempty"`
+	ScanType       string   `json:"scan_type"`
+	MinMatchPct    int      `json:"min_match_percentage"`
+	Strict         bool     `json:"strict,omitempty"`
+	ReferenceDirs  []string `json:"reference_dirs,omitempty"`
+	Keep           string   `json:"keep,omitempty"`
+	MinSize        int64    `json:"min_size,omitempty"`
+	MaxSize        int64    `json:"max_size,omitempty"`
+	Newer          string   `json:"newer,omitempty"`
+	Older          string   `json:"older,omitempty"`
+	Extensions     []string `json:"extensions,omitempty"`
+	Include        []string `json:"include,omitempty"`
+	ExcludeDirs    []string `json:"exclude_dirs,omitempty"`
+	ExcludeFrom    []string `json:"exclude_from,omitempty"`
+	GitIgnore      bool     `json:"gitignore,omitempty"`
+	Symlinks       string   `json:"symlinks,omitempty"`
+	Empty          string   `json:"empty,omitempty"`
+	MaxDepth       int      `json:"max_depth,omitempty"`
+	OneFileSystem  bool     `json:"one_file_system,omitempty"`
+}
+
 // Command line flags
 type Flags struct {
-	Directories    []string
-	Recursive      bool
-	ExcludePattern string
-	ScanType       string
-	MinMatchPct    int
-	OutputFormat   string
-	Help           bool
-	Version        bool
+	ScanParams
+	Output             outputOptions
+	FromFile           string
+	Stdin              bool
+	Null               bool
+	Progress           string
+	Checkpoint         string
+	CheckpointInterval time.Duration
+	Resume             string
+	Save               string
+	Incremental        string
+	IgnoreFile         string
+	NoIgnore           bool
+	Cache              bool
+	CacheFile          string
+	Profile            string
+	Readers            int
 }
 
-// Result formats
-type ResultFormat int
+func main() {
+	os.Exit(newApp().Run(os.Args[1:]))
+}
 
-const (
-	ResultFormatText ResultFormat = iota
-	ResultFormatJSON
-	ResultFormatCSV
-)
+// newApp returns the command tree of dupe-cli
+func newApp() *cli.App {
+	app := &cli.App{
+		Name:    "dupe-cli",
+		Summary: "Dupe CLI - Duplicate File Finder",
+		Version: Version,
+		Default: "scan",
+		Commands: []*cli.Command{
+			scanCommand(),
+			watchCommand(),
+			reportCommand(),
+			filterCommand(),
+			actCommand(),
+			diffCommand(),
+			serveCommand(),
+			importCommand(),
+			ignoreCommand(),
+			cacheCommand(),
+		},
+		Footer: `Exit status:
+  0  Command completed
+  1  Invalid arguments or the command failed
+  2  Scan completed, but some paths could not be read`,
+	}
+	app.Commands = append(app.Commands, configCommand(app), versionCommand())
+	app.Configure = configure(app)
+	return app
+}
 
-func main() {
-	// Parse command line arguments
-	flags, err := parseArgs(os.Args[1:])
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		printUsage()
-		os.Exit(1)
+// usageError prints an error with a pointer to the help of a command, and
+// returns the exit code
+func usageError(err error, command string) int {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	fmt.Fprintf(os.Stderr, "Run 'dupe-cli help %s' for usage.\n", command)
+	return ExitFatal
+}
+
+// versionCommand returns the version command
+func versionCommand() *cli.Command {
+	return &cli.Command{
+		Name:    "version",
+		Group:   "Other commands",
+		Usage:   []string{"version"},
+		Summary: "Show the version",
+		Run: func(_ *cli.FlagSet, args []string) int {
+			if len(args) > 0 {
+				return usageError(fmt.Errorf("unexpected argument: %s", args[0]), "version")
+			}
+			fmt.Printf("dupe-cli version %s (%s %s/%s)\n", Version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+			return ExitOK
+		},
 	}
+}
 
-	// Show help
-	if flags.Help {
-		printUsage()
-		os.Exit(0)
+// scanCommand returns the scan command
+func scanCommand() *cli.Command {
+	flags := &Flags{
+		ScanParams: ScanParams{
+			Directories: []string{},
+			Recursive:   false,
+			ScanType:    "standard",
+			MinMatchPct: 80,
+			Keep:        string(engine.KeepFirst),
+			Symlinks:    string(fs.SymlinksSkip),
+			Empty:       string(scanner.EmptySkip),
+		},
+		Output:             outputOptions{Format: "text"},
+		Progress:           ProgressAuto,
+		CheckpointInterval: checkpoint.DefaultInterval,
+		IgnoreFile:         ignore.DefaultPath(),
+		CacheFile:          cache.DefaultPath(),
+		Readers:            scanner.DefaultReaders,
 	}
 
-	// Show version
-	if flags.Version {
-		fmt.Printf("dupe-cli version %s\n", Version)
-		os.Exit(0)
+	return &cli.Command{
+		Name:    "scan",
+		Group:   "Scanning commands",
+		Usage:   []string{"scan [flags] PATH...", "scan --from-file LIST [flags]", "scan --resume FILE [flags]"},
+		Summary: "Scan directories for duplicate files",
+		Description: `Directories and files are given as arguments, and directories with
+--directories. Lists of files, such as the output of find or git ls-files,
+are read with --from-file or --stdin. Results are printed, or saved with
+--save for the report, filter, act, diff and serve commands.`,
+		Flags: flags.define,
+		Run: func(fs *cli.FlagSet, args []string) int {
+			if len(args) > 0 && !fs.Changed("directories") {
+				// Directories given as arguments replace those of the config
+				flags.Directories = args
+			} else {
+				flags.Directories = append(flags.Directories, args...)
+			}
+			if fs.Changed("cache-file") {
+				flags.Cache = true
+			}
+			return runScanCommand(flags)
+		},
+		Examples: `  # Scan two directories using standard mode
+  dupe-cli scan /path/to/dir1 /path/to/dir2
+
+  # Scan recursively with content-based matching
+  dupe-cli scan -r -s content /path/to/dir
+
+  # Compare the files listed by find, NUL-separated
+  find /path/to/dir -name "*.jpg" -print0 | dupe-cli scan -s content --stdin -0
+
+  # Exclude certain file patterns
+  dupe-cli scan -e "*.tmp,*.log" /path/to/dir
+
+  # Output results in JSON format
+  dupe-cli scan -o json /path/to/dir
+
+  # Save the scan state, and resume it after an interruption
+  dupe-cli scan -r -s content --checkpoint scan.ckpt /path/to/dir
+  dupe-cli scan --resume scan.ckpt
+
+  # Save results, review them later and delete the duplicates
+  dupe-cli scan -r -s content --save results.json /path/to/dir
+  dupe-cli report results.json
+  dupe-cli act results.json --delete`,
 	}
+}
 
-	// Validate arguments
-	if len(flags.Directories) == 0 {
-		fmt.Fprintf(os.Stderr, "Error: No directories specified\n")
-		printUsage()
-		os.Exit(1)
+// define defines the flags of the scan command
+func (f *Flags) define(flagSet *cli.FlagSet) {
+	flagSet.Group("Scan flags")
+	flagSet.ListVar(&f.Directories, "directories", "d", "Directories to scan, comma-separated")
+	flagSet.StringVar(&f.FromFile, "from-file", "", "Scan the files listed in this file, one per line")
+	flagSet.BoolVar(&f.Stdin, "stdin", "", "Scan the files listed on stdin, one per line")
+	flagSet.BoolVar(&f.Null, "null", "0", "Paths of --from-file and --stdin lists are separated by NUL characters")
+	flagSet.BoolVar(&f.Recursive, "recursive", "r", "Scan directories recursively")
+	flagSet.IntVar(&f.MaxDepth, "max-depth", "", "Walk at most this many levels of subdirectories, 0 for no limit (implies --recursive)")
+	flagSet.BoolVar(&f.OneFileSystem, "one-file-system", "x", "Don't walk directories on other filesystems than the scanned directories")
+	flagSet.EnumVar(&f.ScanType, []string{"standard", "content"}, "scan-type", "s", "Scan type")
+	flagSet.IntVar(&f.MinMatchPct, "min-match", "m", "Minimum match percentage for fuzzy matching")
+	flagSet.StringVar(&f.ExcludePattern, "exclude", "e", "Skip files whose names match these glob patterns, comma-separated")
+	flagSet.ListVar(&f.ReferenceDirs, "reference", "", "Reference directories, comma-separated: scanned, but their files are always kept")
+	flagSet.EnumVar(&f.Keep, engine.KeepPolicies, "keep", "", "File of each group to keep as its reference")
+	flagSet.EnumVar(&f.Symlinks, fs.SymlinkPolicies, "symlinks", "", "Skip symbolic links, follow them, or skip and report them")
+	flagSet.EnumVar(&f.Empty, scanner.EmptyPolicies, "empty", "", "Skip empty files, report them separately, or compare them as duplicates")
+	flagSet.BoolVar(&f.Strict, "strict", "", "Abort on the first unreadable path instead of reporting it")
+	flagSet.IntVar(&f.Readers, "readers", "", "Number of directories to read at the same time")
+
+	f.defineFilters(flagSet)
+
+	flagSet.Group("Output flags")
+	f.Output.define(flagSet)
+	flagSet.EnumVar(&f.Progress, []string{ProgressAuto, ProgressBar, ProgressLog, ProgressNone}, "progress", "", "Progress display on stderr")
+	flagSet.StringVar(&f.Save, "save", "", "Save the results to this file for report, filter and act")
+	flagSet.StringVar(&f.Incremental, "incremental", "", "Update the groups of these saved results, comparing only the files of sizes that changed since, and output what changed (implies --cache)")
+
+	flagSet.Group("Checkpoint flags")
+	flagSet.StringVar(&f.Checkpoint, "checkpoint", "", "Periodically save the scan state to this file")
+	flagSet.DurationVar(&f.CheckpointInterval, "checkpoint-interval", "", "Time between checkpoint saves")
+	flagSet.StringVar(&f.Resume, "resume", "", "Resume the scan saved in this checkpoint file")
+
+	flagSet.Group("Cache flags")
+	flagSet.BoolVar(&f.Cache, "cache", "", "Reuse the digests of files unchanged since earlier scans")
+	flagSet.StringVar(&f.CacheFile, "cache-file", "", "Digest cache file (implies --cache)")
+
+	flagSet.Group("Ignore list flags")
+	flagSet.StringVar(&f.IgnoreFile, "ignore-file", "", "Ignore list file")
+	flagSet.BoolVar(&f.NoIgnore, "no-ignore", "", "Don't use the ignore list")
+
+	flagSet.Group("Config flags")
+	flagSet.StringVar(&f.Profile, "profile", "", "Use the settings of this profile of the config files")
+}
+
+// defineFilters defines the flags of the filters of files and directories
+func (p *ScanParams) defineFilters(flagSet *cli.FlagSet) {
+	flagSet.Group("Filter flags")
+	flagSet.SizeVar(&p.MinSize, "min-size", "", "Only scan files of at least this size (e.g. 100M)")
+	flagSet.SizeVar(&p.MaxSize, "max-size", "", "Only scan files of at most this size")
+	flagSet.TimeVar(&p.Newer, "newer", "", "Only scan files modified after this date or within this age (e.g. 2024-01-31, 1y, 30d)")
+	flagSet.TimeVar(&p.Older, "older", "", "Only scan files modified before this date or more than this age ago")
+	flagSet.ListVar(&p.Extensions, "ext", "", "Only scan files with these extensions, comma-separated")
+	flagSet.PatternsVar(&p.Include, "include", "", "Only scan files matching these patterns, comma-separated")
+	flagSet.PatternsVar(&p.ExcludeDirs, "exclude-dir", "", "Don't walk directories matching these patterns, comma-separated")
+	flagSet.ListVar(&p.ExcludeFrom, "exclude-from", "", "Skip the files and directories matched by the rules of these gitignore-style files")
+	flagSet.BoolVar(&p.GitIgnore, "gitignore", "", "Honor the .gitignore and .dupeignore files in the scanned directories")
+}
+
+// validate checks the flags of the scan command
+func (f *Flags) validate() error {
+	if f.MinMatchPct < 0 || f.MinMatchPct > 100 {
+		return fmt.Errorf("min match percentage must be between 0 and 100")
+	}
+	if f.MaxDepth < 0 {
+		return fmt.Errorf("invalid max depth: %d", f.MaxDepth)
+	}
+	if f.Readers < 1 {
+		return fmt.Errorf("invalid number of readers: %d", f.Readers)
+	}
+	if f.Null && f.FromFile == "" && !f.Stdin {
+		return fmt.Errorf("--null requires --from-file or --stdin")
+	}
+	if f.CheckpointInterval <= 0 {
+		return fmt.Errorf("invalid checkpoint interval: %s", f.CheckpointInterval)
+	}
+	if f.Incremental != "" && f.Output.Format != "text" && f.Output.Format != "json" {
+		return fmt.Errorf("--incremental requires text or json output")
 	}
+	if _, err := glob.Compile(glob.Split(f.ExcludePattern)...); err != nil {
+		return fmt.Errorf("--exclude: %w", err)
+	}
+	if _, _, err := f.filters(); err != nil {
+		return err
+	}
+	return f.Output.validate()
+}
 
-	// Validate directories
-	for _, dir := range flags.Directories {
-		info, err := os.Stat(dir)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: Directory not found: %s\n", dir)
-			os.Exit(1)
+// filters returns the filters of files and directories of the scan
+func (p ScanParams) filters() ([]fs.FileFilter, []fs.DirFilter, error) {
+	var files []fs.FileFilter
+	var dirs []fs.DirFilter
+
+	if p.MaxSize != 0 && p.MinSize > p.MaxSize {
+		return nil, nil, fmt.Errorf("--min-size is larger than --max-size")
+	}
+	if p.MinSize != 0 || p.MaxSize != 0 {
+		files = append(files, fs.SizeFilter(p.MinSize, p.MaxSize))
+	}
+
+	var newer, older time.Time
+	for _, t := range []struct {
+		value string
+		p     *time.Time
+	}{{p.Newer, &newer}, {p.Older, &older}} {
+		if t.value == "" {
+			continue
 		}
-		if !info.IsDir() {
-			fmt.Fprintf(os.Stderr, "Error: Not a directory: %s\n", dir)
-			os.Exit(1)
+		parsed, err := time.Parse(time.RFC3339, t.value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid time: %s", t.value)
 		}
+		*t.p = parsed
+	}
+	if !newer.IsZero() && !older.IsZero() && !newer.Before(older) {
+		return nil, nil, fmt.Errorf("--newer is not before --older, no file can match")
+	}
+	if !newer.IsZero() || !older.IsZero() {
+		files = append(files, fs.ModTimeFilter(newer, older))
 	}
 
-	// Run scan
-	err = runScan(flags)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	if len(p.Extensions) > 0 {
+		files = append(files, fs.ExtensionFilter(p.Extensions))
+	}
+	if len(p.Include) > 0 {
+		include, err := fs.IncludeFilter(p.Include)
+		if err != nil {
+			return nil, nil, fmt.Errorf("--include: %w", err)
+		}
+		files = append(files, include)
+	}
+	if len(p.ExcludeDirs) > 0 {
+		exclude, err := fs.ExcludeDirFilter(p.ExcludeDirs)
+		if err != nil {
+			return nil, nil, fmt.Errorf("--exclude-dir: %w", err)
+		}
+		dirs = append(dirs, exclude)
+	}
+	if len(p.ExcludeFrom) > 0 || p.GitIgnore {
+		var names []string
+		if p.GitIgnore {
+			names = gitignore.Names
+		}
+		m, err := gitignore.New(p.Directories, p.ExcludeFrom, names)
+		if err != nil {
+			return nil, nil, fmt.Errorf("--exclude-from: %w", err)
+		}
+		files = append(files, m.FileFilter())
+		dirs = append(dirs, m.DirFilter())
 	}
+	return files, dirs, nil
 }
 
-// parseArgs parses command line arguments
-func parseArgs(args []string) (*Flags, error) {
-	flags := &Flags{
-		Directories:  []string{},
-		Recursive:    false,
-		ScanType:     "standard",
-		MinMatchPct:  80,
-		OutputFormat: "text",
+// describeFilters returns the filters of the scan as shown in the banner
+func (p ScanParams) describeFilters() []string {
+	var desc []string
+	if p.MinSize != 0 {
+		desc = append(desc, "at least "+units.FormatSize(p.MinSize))
+	}
+	if p.MaxSize != 0 {
+		desc = append(desc, "at most "+units.FormatSize(p.MaxSize))
+	}
+	if p.Newer != "" {
+		desc = append(desc, "modified after "+p.Newer)
+	}
+	if p.Older != "" {
+		desc = append(desc, "modified before "+p.Older)
+	}
+	if len(p.Extensions) > 0 {
+		desc = append(desc, "extensions "+strings.Join(p.Extensions, ","))
+	}
+	if len(p.Include) > 0 {
+		desc = append(desc, "matching "+strings.Join(p.Include, ","))
+	}
+	if len(p.ExcludeDirs) > 0 {
+		desc = append(desc, "outside directories "+strings.Join(p.ExcludeDirs, ","))
+	}
+	if len(p.ExcludeFrom) > 0 {
+		desc = append(desc, "excluding the rules of "+strings.Join(p.ExcludeFrom, ","))
+	}
+	if p.GitIgnore {
+		desc = append(desc, "honoring "+strings.Join(gitignore.Names, " and ")+" files")
 	}
+	return desc
+}
 
-	for i := 0; i < len(args); i++ {
-		arg := args[i]
+// runScanCommand validates the scan flags and runs the scan
+func runScanCommand(flags *Flags) int {
+	if err := flags.validate(); err != nil {
+		return usageError(err, "scan")
+	}
 
-		switch {
-		case arg == "scan":
-			// Command, just skip
-			continue
+	// Take the scan parameters from the checkpoint when resuming
+	var resumed *checkpoint.Checkpoint
+	if flags.Resume != "" {
+		var err error
+		resumed, err = loadCheckpoint(flags)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return ExitFatal
+		}
+	}
 
-		case arg == "-h" || arg == "--help":
-			flags.Help = true
+	// Listed files are recorded in the scan parameters, so a resumed scan
+	// doesn't read the lists again
+	if err := loadPathLists(flags); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitFatal
+	}
 
-		case arg == "-v" || arg == "--version":
-			flags.Version = true
+	// A depth limit only makes sense for a recursive scan
+	if flags.MaxDepth > 0 {
+		flags.Recursive = true
+	}
 
-		case arg == "-r" || arg == "--recursive":
-			flags.Recursive = true
+	// Reference directories are scanned along with the others
+	for _, dir := range flags.ReferenceDirs {
+		if !containsString(flags.Directories, dir) {
+			flags.Directories = append(flags.Directories, dir)
+		}
+	}
 
-		case arg == "-d" || arg == "--directories":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("missing value for %s", arg)
-			}
-			i++
-			dirs := strings.Split(args[i], ",")
-			for _, dir := range dirs {
-				flags.Directories = append(flags.Directories, strings.TrimSpace(dir))
-			}
+	// Validate arguments
+	if len(flags.Directories) == 0 && len(flags.Files) == 0 {
+		return usageError(fmt.Errorf("no directories or files specified"), "scan")
+	}
 
-		case arg == "-e" || arg == "--exclude":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("missing value for %s", arg)
-			}
-			i++
-			flags.ExcludePattern = args[i]
+	// Validate directories, and scan the files given with them on their own
+	dirs := flags.Directories[:0]
+	for _, dir := range flags.Directories {
+		info, err := os.Stat(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Path not found: %s\n", dir)
+			return ExitFatal
+		}
+		if info.IsDir() {
+			dirs = append(dirs, dir)
+		} else {
+			flags.Files = append(flags.Files, dir)
+		}
+	}
+	flags.Directories = dirs
 
-		case arg == "-s" || arg == "--scan-type":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("missing value for %s", arg)
-			}
-			i++
-			flags.ScanType = strings.ToLower(args[i])
-			if flags.ScanType != "standard" && flags.ScanType != "content" {
-				return nil, fmt.Errorf("invalid scan type: %s", flags.ScanType)
-			}
+	// Run scan
+	errCount, err := runScan(flags, resumed)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitFatal
+	}
+	if errCount > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %d paths could not be read\n", errCount)
+		return ExitErrors
+	}
+	return ExitOK
+}
 
-		case arg == "-m" || arg == "--min-match":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("missing value for %s", arg)
-			}
-			i++
-			pct, err := strconv.Atoi(args[i])
-			if err != nil {
-				return nil, fmt.Errorf("invalid min match percentage: %s", args[i])
-			}
-			if pct < 0 || pct > 100 {
-				return nil, fmt.Errorf("min match percentage must be between 0 and 100")
-			}
-			flags.MinMatchPct = pct
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, elem := range list {
+		if elem == s {
+			return true
+		}
+	}
+	return false
+}
 
-		case arg == "-o" || arg == "--output":
-			if i+1 >= len(args) {
-				return nil, fmt.Errorf("missing value for %s", arg)
-			}
-			i++
-			flags.OutputFormat = strings.ToLower(args[i])
-			if flags.OutputFormat != "text" && flags.OutputFormat != "json" && flags.OutputFormat != "csv" {
-				return nil, fmt.Errorf("invalid output format: %s", flags.OutputFormat)
-			}
+// loadCheckpoint loads the checkpoint to resume and takes the scan
+// parameters from it. The checkpoint keeps being updated while resuming.
+func loadCheckpoint(flags *Flags) (*checkpoint.Checkpoint, error) {
+	if len(flags.Directories) > 0 || flags.FromFile != "" || flags.Stdin {
+		return nil, fmt.Errorf("directories and files cannot be specified when resuming a scan")
+	}
 
-		case strings.HasPrefix(arg, "-"):
-			return nil, fmt.Errorf("unknown option: %s", arg)
+	cp, err := checkpoint.Load(flags.Resume)
+	if err != nil {
+		return nil, err
+	}
 
-		default:
-			// Assume it's a directory
-			flags.Directories = append(flags.Directories, arg)
-		}
+	var params ScanParams
+	if err := json.Unmarshal(cp.Params, &params); err != nil {
+		return nil, fmt.Errorf("invalid scan parameters in checkpoint %s: %w", flags.Resume, err)
 	}
+	flags.ScanParams = params
 
-	return flags, nil
-}
+	if flags.Checkpoint == "" {
+		flags.Checkpoint = flags.Resume
+	}
 
-// printUsage prints usage information
-func printUsage() {
-	fmt.Println("Dupe CLI - Duplicate File Finder")
-	fmt.Println("")
-	fmt.Println("Usage:")
-	fmt.Println("  dupe-cli [command] [flags]")
-	fmt.Println("")
-	fmt.Println("Commands:")
-	fmt.Println("  scan        Scan directories for duplicate files")
-	fmt.Println("  help        Help about any command")
-	fmt.Println("")
-	fmt.Println("Flags:")
-	fmt.Println("  -d, --directories string   Directories to scan (comma-separated)")
-	fmt.Println("  -r, --recursive            Scan directories recursively")
-	fmt.Println("  -m, --min-match int        Minimum match percentage for fuzzy matching (default: 80)")
-	fmt.Println("  -s, --scan-type string     Scan type (standard, content) (default: \"standard\")")
-	fmt.Println("  -e, --exclude string       Exclude patterns (comma-separated)")
-	fmt.Println("  -o, --output string        Output format (text, json, csv) (default: \"text\")")
-	fmt.Println("  -h, --help                 Help for dupe-cli")
-	fmt.Println("  -v, --version              Version for dupe-cli")
-	fmt.Println("")
-	fmt.Println("Examples:")
-	fmt.Println("  # Scan two directories using standard mode")
-	fmt.Println("  dupe-cli scan -d /path/to/dir1,/path/to/dir2")
-	fmt.Println("")
-	fmt.Println("  # Scan recursively with content-based matching")
-	fmt.Println("  dupe-cli scan -d /path/to/dir -r -s content")
-	fmt.Println("")
-	fmt.Println("  # Exclude certain file patterns")
-	fmt.Println("  dupe-cli scan -d /path/to/dir -e \"*.tmp,*.log\"")
-	fmt.Println("")
-	fmt.Println("  # Output results in JSON format")
-	fmt.Println("  dupe-cli scan -d /path/to/dir -o json")
+	return cp, nil
 }
 
-// runScan runs the scan with the specified flags
-func runScan(flags *Flags) error {
+// runScan runs the scan with the specified flags and returns the number
+// of paths that could not be read. If resumed is not nil, the scan
+// continues from that checkpoint.
+func runScan(flags *Flags, resumed *checkpoint.Checkpoint) (int, error) {
 	startTime := time.Now()
 
 	// Convert scan type string to ScanType
@@ -227,7 +521,24 @@ func runScan(flags *Flags) error {
 	}
 
 	// Create scanner
-	s := scanner.NewScanner(flags.Directories, flags.ExcludePattern, flags.Recursive, scanType, flags.MinMatchPct)
+	s, err := scanner.NewScanner(flags.Directories, flags.ExcludePattern, flags.Recursive, scanType, flags.MinMatchPct)
+	if err != nil {
+		return 0, fmt.Errorf("--exclude: %w", err)
+	}
+	s.Files = flags.Files
+	s.Strict = flags.Strict
+	s.Symlinks = fs.SymlinkPolicy(flags.Symlinks)
+	s.Empty = scanner.EmptyPolicy(flags.Empty)
+	s.Readers = flags.Readers
+	s.MaxDepth = flags.MaxDepth
+	s.OneFileSystem = flags.OneFileSystem
+	s.Filters, s.DirFilters, err = flags.filters()
+	if err != nil {
+		return 0, err
+	}
+	for _, dir := range flags.ReferenceDirs {
+		s.SetReferenceDir(dir)
+	}
 
 	// Create matcher
 	matchOpts := matcher.MatchOptions{
@@ -246,46 +557,337 @@ func runScan(flags *Flags) error {
 
 	// Create engine
 	e := engine.NewEngine(s, m)
+	e.OnProgress = newProgressFunc(flags.Progress, os.Stderr)
+	e.Keep = engine.KeepPolicy(flags.Keep)
+
+	// Don't report reviewed files and pairs
+	if !flags.NoIgnore {
+		list, err := ignore.Load(flags.IgnoreFile)
+		if err != nil {
+			return 0, err
+		}
+		e.Ignore = list
+	}
+
+	// Scan parameters, recorded in checkpoints, saved results and template output
+	params, err := json.Marshal(flags.ScanParams)
+	if err != nil {
+		return 0, err
+	}
+	flags.Output.Params = params
+
+	// Save the scan state periodically
+	var writer *checkpoint.Writer
+	if flags.Checkpoint != "" {
+		writer = checkpoint.NewWriter(flags.Checkpoint, params, flags.Directories, flags.CheckpointInterval)
+		if resumed != nil {
+			s.Resume = resumed.RootStates()
+			writer.Resume(resumed)
+		}
+		s.OnDirDone = writer.DirDone
+		e.OnFileHashed = writer.FileHashed
+	}
+
+	// Update the groups of the previous results, whose digests are reused
+	// along with the cache, and record what the next update starts from
+	var previous *results.ResultSet
+	var saved *results.Digests
+	if flags.Incremental != "" {
+		previous, err = loadPrevious(flags)
+		if err != nil {
+			return 0, err
+		}
+		e.Seed = previous.Seed()
+		saved = previous.Digests()
+		flags.Cache = true
+	}
+	e.Fingerprint = flags.Save != ""
+
+	// Files given individually are scanned again when resuming, with the
+	// digests the interrupted scan calculated for them
+	if resumed != nil {
+		s.OnFile = func(file *fs.File) {
+			resumed.Apply(file)
+		}
+	}
+
+	// Reuse the digests of unchanged files, and cache the new ones
+	var digests *cache.Cache
+	reused := 0
+	if flags.Cache {
+		digests, err = cache.Load(flags.CacheFile)
+		if err != nil {
+			return 0, err
+		}
+		onFile := s.OnFile
+		s.OnFile = func(file *fs.File) {
+			if onFile != nil {
+				onFile(file)
+			}
+			if (saved != nil && saved.Apply(file)) || digests.Apply(file) {
+				reused++
+			}
+		}
+		onFileHashed := e.OnFileHashed
+		e.OnFileHashed = func(file *fs.File) {
+			digests.Store(file)
+			if onFileHashed != nil {
+				onFileHashed(file)
+			}
+		}
+	}
+
+	// Stream groups as they are found rather than when the scan is done
+	var stream *ndjsonWriter
+	if flags.Output.Format == "ndjson" {
+		stream = newNDJSONWriter(os.Stdout)
+		e.OnGroup = stream.WriteGroup
+	}
 
-	// Print scan start message
-	fmt.Printf("Scanning directories: %s\n", strings.Join(flags.Directories, ", "))
-	fmt.Printf("Scan type: %s\n", flags.ScanType)
-	if flags.Recursive {
-		fmt.Println("Recursive: yes")
+	// Print scan start message, to stderr unless the output is text so
+	// that machine-readable output stays parseable
+	banner := os.Stdout
+	if flags.Output.Format != "text" {
+		banner = os.Stderr
+	}
+	if len(flags.Directories) > 0 {
+		fmt.Fprintf(banner, "Scanning directories: %s\n", strings.Join(flags.Directories, ", "))
+	}
+	if len(flags.Files) > 0 {
+		fmt.Fprintf(banner, "Scanning files: %d listed\n", len(flags.Files))
+	}
+	fmt.Fprintf(banner, "Scan type: %s\n", flags.ScanType)
+	if flags.Recursive && flags.MaxDepth > 0 {
+		fmt.Fprintf(banner, "Recursive: yes, at most %d levels\n", flags.MaxDepth)
+	} else if flags.Recursive {
+		fmt.Fprintln(banner, "Recursive: yes")
 	} else {
-		fmt.Println("Recursive: no")
+		fmt.Fprintln(banner, "Recursive: no")
 	}
 	if flags.ExcludePattern != "" {
-		fmt.Printf("Exclude pattern: %s\n", flags.ExcludePattern)
+		fmt.Fprintf(banner, "Exclude pattern: %s\n", flags.ExcludePattern)
+	}
+	if flags.OneFileSystem {
+		fmt.Fprintln(banner, "One filesystem: yes")
+	}
+	if filters := flags.describeFilters(); len(filters) > 0 {
+		fmt.Fprintf(banner, "Filters: %s\n", strings.Join(filters, ", "))
+	}
+	if len(flags.ReferenceDirs) > 0 {
+		fmt.Fprintf(banner, "Reference directories: %s\n", strings.Join(flags.ReferenceDirs, ", "))
+	}
+	if flags.Symlinks != "" && flags.Symlinks != string(fs.SymlinksSkip) {
+		fmt.Fprintf(banner, "Symbolic links: %s\n", flags.Symlinks)
 	}
-	fmt.Printf("Minimum match percentage: %d%%\n", flags.MinMatchPct)
-	fmt.Println("Scanning...")
+	if flags.Empty != "" && flags.Empty != string(scanner.EmptySkip) {
+		fmt.Fprintf(banner, "Empty files: %s\n", flags.Empty)
+	}
+	if flags.Keep != string(engine.KeepFirst) {
+		fmt.Fprintf(banner, "Keep: %s\n", flags.Keep)
+	}
+	fmt.Fprintf(banner, "Minimum match percentage: %d%%\n", flags.MinMatchPct)
+	fmt.Fprintln(banner, "Scanning...")
 
 	// Find duplicates
 	groups, err := e.FindDuplicates()
+	if writer != nil {
+		if saveErr := writer.Close(); saveErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: error saving checkpoint: %v\n", saveErr)
+		}
+	}
+	if digests != nil {
+		if saveErr := digests.Save(); saveErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: error saving digest cache: %v\n", saveErr)
+		}
+	}
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Calculate scan time
 	scanTime := time.Since(startTime)
+	errs := e.GetErrors()
+
+	// Save results for later report, filter and act commands
+	current := results.New(groups, errs, params, scanTime)
+	current.Fingerprints = e.GetFingerprints()
+	if flags.Save != "" {
+		if err := current.Save(flags.Save); err != nil {
+			return 0, fmt.Errorf("error saving results: %w", err)
+		}
+	}
+
+	if previous != nil {
+		return len(errs), outputIncremental(flags, previous, current, reused, e.GetKeptSizes())
+	}
+
+	if stream != nil {
+		stream.Skipped, stream.Empty = s.GetSkipped(), s.GetEmpty()
+		return len(errs), stream.WriteSummary(groups, errs, scanTime)
+	}
+	flags.Output.Scanned = s.GetFiles()
+	flags.Output.Skipped = s.GetSkipped()
+	flags.Output.Empty = s.GetEmpty()
+	return len(errs), outputResults(flags.Output, groups, errs, scanTime)
+}
+
+// outputFormats are the supported output formats
+var outputFormats = []string{"text", "json", "ndjson", "csv", "dupeguru", "html", "template", "fdupes", "jdupes-json", "rdfind", "sqlite"}
+
+// outputOptions are the options for outputting results
+type outputOptions struct {
+	Format      string
+	Columns     []string          // Columns of CSV output (nil for the default columns)
+	SummaryFile string            // File to write the summary of CSV output to
+	Template    string            // Template file of template output
+	DB          string            // Database file of sqlite output
+	DirSummary  bool              // Summarize duplicates by directory in text and JSON output
+	Scanned     []*fs.File        // All scanned files, for the redundancy of directories (may be nil)
+	Skipped     []*fs.SkippedPath // Paths the scan skipped and reports (may be nil)
+	Empty       []*fs.File        // Empty files the scan reports separately (may be nil)
+	Params      json.RawMessage   // Scan parameters passed to templates (may be nil)
+}
+
+// define defines the output flags
+func (o *outputOptions) define(fs *cli.FlagSet) {
+	fs.EnumVar(&o.Format, outputFormats, "output", "o", "Output format")
+	fs.Var((*columnsValue)(&o.Columns), "columns", "", fmt.Sprintf("CSV columns, comma-separated (%s) (default %q)",
+		strings.Join(csvColumns, ", "), strings.Join(defaultCSVColumns, ",")))
+	fs.StringVar(&o.SummaryFile, "summary-file", "", "Write the CSV summary and errors to this file")
+	fs.StringVar(&o.Template, "template", "", "Template file of template output (Go text/template)")
+	fs.StringVar(&o.DB, "db", "", "Database file of sqlite output")
+	fs.BoolVar(&o.DirSummary, "dir-summary", "", "Summarize duplicates by directory (text and json output)")
+}
+
+// validate checks that the options apply to the output format
+func (o outputOptions) validate() error {
+	if o.Format != "csv" && (o.Columns != nil || o.SummaryFile != "") {
+		return fmt.Errorf("--columns and --summary-file require csv output")
+	}
+
+	// Parse the template before scanning so errors in it are reported early
+	if o.Format == "template" {
+		if o.Template == "" {
+			return fmt.Errorf("template output requires --template FILE")
+		}
+		if _, err := tmplreport.Load(o.Template); err != nil {
+			return err
+		}
+	} else if o.Template != "" {
+		return fmt.Errorf("--template requires template output")
+	}
+
+	if o.DirSummary && o.Format != "text" && o.Format != "json" {
+		return fmt.Errorf("--dir-summary requires text or json output")
+	}
+
+	if o.Format == "sqlite" && o.DB == "" {
+		return fmt.Errorf("sqlite output requires --db FILE")
+	} else if o.Format != "sqlite" && o.DB != "" {
+		return fmt.Errorf("--db requires sqlite output")
+	}
+	return nil
+}
 
-	// Output results
-	switch flags.OutputFormat {
+// outputResults outputs results in the specified format
+func outputResults(opts outputOptions, groups []*engine.DuplicateGroup, errs []*fs.PathError, scanTime time.Duration) error {
+	totalDupes := engine.TotalDuplicateCount(groups)
+	totalSize := engine.TotalDuplicateSize(groups)
+
+	var summary *dirsummary.Summary
+	if opts.DirSummary {
+		summary = dirsummary.Build(groups, opts.Scanned)
+	}
+
+	switch opts.Format {
 	case "json":
-		return outputJSON(groups, e.GetTotalDuplicateCount(), e.GetTotalDuplicateSize(), scanTime)
+		return outputJSON(groups, errs, opts, totalDupes, totalSize, scanTime, summary)
+	case "ndjson":
+		return outputNDJSON(groups, errs, opts, scanTime)
 	case "csv":
-		return outputCSV(groups, e.GetTotalDuplicateCount(), e.GetTotalDuplicateSize(), scanTime)
+		return outputCSV(groups, errs, scanTime, opts)
+	case "dupeguru":
+		return dupeguru.WriteResults(os.Stdout, groups, errs)
+	case "html":
+		return htmlreport.Write(os.Stdout, groups, errs, scanTime)
+	case "fdupes":
+		// fdupes output only has room for paths, so errors go to stderr
+		if len(errs) > 0 {
+			writeErrors(os.Stderr, errs)
+		}
+		return compat.WriteFdupes(os.Stdout, groups)
+	case "jdupes-json":
+		return compat.WriteJdupesJSON(os.Stdout, groups, errs, Version, strings.Join(os.Args, " "))
+	case "rdfind":
+		return compat.WriteRdfind(os.Stdout, groups, errs, scanParams(opts.Params).Directories)
+	case "sqlite":
+		if err := sqlite.Export(opts.DB, groups, errs, opts.Params, scanTime); err != nil {
+			return fmt.Errorf("error writing database: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %d groups to %s\n", len(groups), opts.DB)
+		return nil
+	case "template":
+		tmpl, err := tmplreport.Load(opts.Template)
+		if err != nil {
+			return err
+		}
+		return tmplreport.Write(os.Stdout, tmpl, groups, errs, opts.Params, scanTime)
 	default:
-		return outputText(groups, e.GetTotalDuplicateCount(), e.GetTotalDuplicateSize(), scanTime)
+		return outputText(groups, errs, opts, totalDupes, totalSize, scanTime, summary)
+	}
+}
+
+// writeErrors writes the list of paths that could not be read
+func writeErrors(w io.Writer, errs []*fs.PathError) {
+	fmt.Fprintf(w, "Errors (%d paths could not be read):\n", len(errs))
+	for _, pathErr := range errs {
+		fmt.Fprintf(w, "  [%s] %s: %v\n", pathErr.Category, pathErr.Path, pathErr.Err)
 	}
 }
 
-// outputText outputs results in text format
-func outputText(groups []*engine.DuplicateGroup, totalDupes int, totalSize int64, scanTime time.Duration) error {
+// scanParams returns the scan parameters recorded with results, or the
+// zero parameters if they are missing or invalid
+func scanParams(params json.RawMessage) ScanParams {
+	var p ScanParams
+	if len(params) > 0 {
+		_ = json.Unmarshal(params, &p)
+	}
+	return p
+}
+
+// outputText outputs results in text format. With a directory summary,
+// it is shown instead of the groups.
+func outputText(groups []*engine.DuplicateGroup, errs []*fs.PathError, opts outputOptions, totalDupes int, totalSize int64, scanTime time.Duration, summary *dirsummary.Summary) error {
 	fmt.Printf("\nScan completed in %s\n", scanTime)
 	fmt.Printf("Found %d duplicate groups with %d total duplicates\n", len(groups), totalDupes)
-	fmt.Printf("Total space that could be freed: %s\n", formatSize(totalSize))
+	fmt.Printf("Total space that could be freed: %s\n", units.FormatSize(totalSize))
+
+	if len(errs) > 0 {
+		fmt.Println()
+		writeErrors(os.Stdout, errs)
+	}
+
+	if len(opts.Skipped) > 0 {
+		fmt.Printf("\nSkipped (%d paths):\n", len(opts.Skipped))
+		for _, path := range opts.Skipped {
+			if path.Target != "" {
+				fmt.Printf("  [%s] %s -> %s\n", path.Type, path.Path, path.Target)
+			} else {
+				fmt.Printf("  [%s] %s\n", path.Type, path.Path)
+			}
+		}
+	}
+
+	if len(opts.Empty) > 0 {
+		fmt.Printf("\nEmpty files (%d):\n", len(opts.Empty))
+		for _, file := range opts.Empty {
+			fmt.Printf("  %s\n", file.Path)
+		}
+	}
+
+	if summary != nil {
+		return summary.WriteText(os.Stdout)
+	}
 
 	if len(groups) == 0 {
 		fmt.Println("No duplicates found.")
@@ -294,12 +896,12 @@ func outputText(groups []*engine.DuplicateGroup, totalDupes int, totalSize int64
 
 	for i, group := range groups {
 		fmt.Printf("\nGroup %d:\n", i+1)
-		fmt.Printf("  Reference: %s (%s)\n", group.Reference.Path, formatSize(group.Reference.Size))
+		fmt.Printf("  Reference: %s (%s)\n", group.Reference.Path, units.FormatSize(group.Reference.Size))
 
 		for j, dupe := range group.Duplicates {
 			match := group.Matches[j]
 			fmt.Printf("  Duplicate %d: %s (%s, %d%% match)\n",
-				j+1, dupe.Path, formatSize(dupe.Size), match.Percentage)
+				j+1, dupe.Path, units.FormatSize(dupe.Size), match.Percentage)
 		}
 	}
 
@@ -307,7 +909,7 @@ func outputText(groups []*engine.DuplicateGroup, totalDupes int, totalSize int64
 }
 
 // outputJSON outputs results in JSON format
-func outputJSON(groups []*engine.DuplicateGroup, totalDupes int, totalSize int64, scanTime time.Duration) error {
+func outputJSON(groups []*engine.DuplicateGroup, errs []*fs.PathError, opts outputOptions, totalDupes int, totalSize int64, scanTime time.Duration, summary *dirsummary.Summary) error {
 	type Match struct {
 		Path       string `json:"path"`
 		Size       int64  `json:"size"`
@@ -320,12 +922,29 @@ func outputJSON(groups []*engine.DuplicateGroup, totalDupes int, totalSize int64
 		Duplicates []Match `json:"duplicates"`
 	}
 
+	type Error struct {
+		Path     string `json:"path"`
+		Category string `json:"category"`
+		Message  string `json:"message"`
+	}
+
+	type Skipped struct {
+		Path   string `json:"path"`
+		Type   string `json:"type"`
+		Target string `json:"target,omitempty"`
+	}
+
 	type Result struct {
-		ScanTime       string  `json:"scan_time"`
-		GroupCount     int     `json:"group_count"`
-		DuplicateCount int     `json:"duplicate_count"`
-		TotalSize      int64   `json:"total_size"`
-		Groups         []Group `json:"groups"`
+		ScanTime       string              `json:"scan_time"`
+		GroupCount     int                 `json:"group_count"`
+		DuplicateCount int                 `json:"duplicate_count"`
+		TotalSize      int64               `json:"total_size"`
+		ErrorCount     int                 `json:"error_count"`
+		Groups         []Group             `json:"groups"`
+		Errors         []Error             `json:"errors"`
+		Skipped        []Skipped           `json:"skipped,omitempty"`
+		Empty          []string            `json:"empty_files,omitempty"`
+		Directories    *dirsummary.Summary `json:"directory_summary,omitempty"`
 	}
 
 	result := Result{
@@ -333,7 +952,24 @@ func outputJSON(groups []*engine.DuplicateGroup, totalDupes int, totalSize int64
 		GroupCount:     len(groups),
 		DuplicateCount: totalDupes,
 		TotalSize:      totalSize,
+		ErrorCount:     len(errs),
 		Groups:         make([]Group, 0, len(groups)),
+		Errors:         make([]Error, 0, len(errs)),
+		Directories:    summary,
+	}
+
+	for _, pathErr := range errs {
+		result.Errors = append(result.Errors, Error{
+			Path:     pathErr.Path,
+			Category: pathErr.Category.String(),
+			Message:  pathErr.Err.Error(),
+		})
+	}
+	for _, path := range opts.Skipped {
+		result.Skipped = append(result.Skipped, Skipped{Path: path.Path, Type: path.Type, Target: path.Target})
+	}
+	for _, file := range opts.Empty {
+		result.Empty = append(result.Empty, file.Path)
 	}
 
 	for _, group := range groups {
@@ -363,51 +999,3 @@ func outputJSON(groups []*engine.DuplicateGroup, totalDupes int, totalSize int64
 	fmt.Println(string(jsonData))
 	return nil
 }
-
-// outputCSV outputs results in CSV format
-func outputCSV(groups []*engine.DuplicateGroup, totalDupes int, totalSize int64, scanTime time.Duration) error {
-	// Print header
-	fmt.Println("group,type,path,size,match_percentage")
-
-	// Print summary as comments
-	fmt.Printf("# Scan completed in %s\n", scanTime)
-	fmt.Printf("# Found %d duplicate groups with %d total duplicates\n", len(groups), totalDupes)
-	fmt.Printf("# Total space that could be freed: %s\n", formatSize(totalSize))
-
-	// Print data
-	for i, group := range groups {
-		// Print reference
-		fmt.Printf("%d,reference,%s,%d,100\n", i+1, escapeCsvField(group.Reference.Path), group.Reference.Size)
-
-		// Print duplicates
-		for j, dupe := range group.Duplicates {
-			match := group.Matches[j]
-			fmt.Printf("%d,duplicate,%s,%d,%d\n", i+1, escapeCsvField(dupe.Path), dupe.Size, match.Percentage)
-		}
-	}
-
-	return nil
-}
-
-// escapeCsvField escapes a field for CSV output
-func escapeCsvField(field string) string {
-	if strings.Contains(field, ",") || strings.Contains(field, "\"") || strings.Contains(field, "\n") {
-		field = strings.ReplaceAll(field, "\"", "\"\"")
-		field = "\"" + field + "\""
-	}
-	return field
-}
-
-// formatSize formats a size in bytes to a human-readable string
-func formatSize(size int64) string {
-	const unit = 1024
-	if size < unit {
-		return fmt.Sprintf("%d B", size)
-	}
-	div, exp := int64(unit), 0
-	for n := size / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
-	}
-	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
-}
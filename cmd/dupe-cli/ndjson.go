@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+)
+
+// ndjsonMatch is a duplicate in an NDJSON group record
+type ndjsonMatch struct {
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	Percentage int    `json:"percentage"`
+}
+
+// ndjsonGroup is the record written for each duplicate group
+type ndjsonGroup struct {
+	Type       string        `json:"type"` // Always "group"
+	Reference  string        `json:"reference"`
+	RefSize    int64         `json:"reference_size"`
+	Duplicates []ndjsonMatch `json:"duplicates"`
+}
+
+// ndjsonError is a path that could not be read in the summary record
+type ndjsonError struct {
+	Path     string `json:"path"`
+	Category string `json:"category"`
+	Message  string `json:"message"`
+}
+
+// ndjsonSkipped is a path the scan skipped in the summary record
+type ndjsonSkipped struct {
+	Path   string `json:"path"`
+	Type   string `json:"type"`
+	Target string `json:"target,omitempty"`
+}
+
+// ndjsonSummary is the last record, written when the scan is complete
+type ndjsonSummary struct {
+	Type           string          `json:"type"` // Always "summary"
+	ScanTime       string          `json:"scan_time"`
+	GroupCount     int             `json:"group_count"`
+	DuplicateCount int             `json:"duplicate_count"`
+	TotalSize      int64           `json:"total_size"`
+	ErrorCount     int             `json:"error_count"`
+	Errors         []ndjsonError   `json:"errors"`
+	Skipped        []ndjsonSkipped `json:"skipped,omitempty"`
+	Empty          []string        `json:"empty_files,omitempty"`
+}
+
+// ndjsonWriter writes results as newline-delimited JSON, one record per
+// duplicate group followed by a summary record
+type ndjsonWriter struct {
+	Skipped []*fs.SkippedPath // Paths the scan skipped, listed in the summary (may be nil)
+	Empty   []*fs.File        // Empty files reported separately, listed in the summary (may be nil)
+
+	enc *json.Encoder
+	err error // First error writing a group
+}
+
+// newNDJSONWriter creates a writer of NDJSON records
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	return &ndjsonWriter{enc: json.NewEncoder(w)}
+}
+
+// WriteGroup writes the record of a duplicate group. It can be used as the
+// engine's OnGroup callback to stream groups as they are found; the first
+// error is kept and returned by WriteSummary.
+func (w *ndjsonWriter) WriteGroup(group *engine.DuplicateGroup) {
+	if w.err != nil {
+		return
+	}
+
+	record := ndjsonGroup{
+		Type:       "group",
+		Reference:  group.Reference.Path,
+		RefSize:    group.Reference.Size,
+		Duplicates: make([]ndjsonMatch, 0, len(group.Duplicates)),
+	}
+	for j, dupe := range group.Duplicates {
+		record.Duplicates = append(record.Duplicates, ndjsonMatch{
+			Path:       dupe.Path,
+			Size:       dupe.Size,
+			Percentage: group.Matches[j].Percentage,
+		})
+	}
+
+	w.err = w.enc.Encode(record)
+}
+
+// WriteSummary writes the summary record of all groups
+func (w *ndjsonWriter) WriteSummary(groups []*engine.DuplicateGroup, errs []*fs.PathError, scanTime time.Duration) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	summary := ndjsonSummary{
+		Type:           "summary",
+		ScanTime:       scanTime.String(),
+		GroupCount:     len(groups),
+		DuplicateCount: engine.TotalDuplicateCount(groups),
+		TotalSize:      engine.TotalDuplicateSize(groups),
+		ErrorCount:     len(errs),
+		Errors:         make([]ndjsonError, 0, len(errs)),
+	}
+	for _, pathErr := range errs {
+		summary.Errors = append(summary.Errors, ndjsonError{
+			Path:     pathErr.Path,
+			Category: pathErr.Category.String(),
+			Message:  pathErr.Err.Error(),
+		})
+	}
+	for _, path := range w.Skipped {
+		summary.Skipped = append(summary.Skipped, ndjsonSkipped{Path: path.Path, Type: path.Type, Target: path.Target})
+	}
+	for _, file := range w.Empty {
+		summary.Empty = append(summary.Empty, file.Path)
+	}
+
+	return w.enc.Encode(summary)
+}
+
+// outputNDJSON outputs results that are already complete in NDJSON format
+func outputNDJSON(groups []*engine.DuplicateGroup, errs []*fs.PathError, opts outputOptions, scanTime time.Duration) error {
+	w := newNDJSONWriter(os.Stdout)
+	w.Skipped, w.Empty = opts.Skipped, opts.Empty
+	for _, group := range groups {
+		w.WriteGroup(group)
+	}
+	return w.WriteSummary(groups, errs, scanTime)
+}
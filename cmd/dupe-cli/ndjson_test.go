@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+	"github.com/tendant/dupe-cli/internal/matcher"
+)
+
+// newGroup returns a group of a reference and its duplicates, which match
+// it at 100%
+func newGroup(ref *fs.File, dupes ...*fs.File) *engine.DuplicateGroup {
+	group := &engine.DuplicateGroup{Reference: ref, Duplicates: dupes}
+	for _, dupe := range dupes {
+		group.Matches = append(group.Matches, &matcher.Match{First: ref, Second: dupe, Percentage: 100})
+	}
+	return group
+}
+
+// readRecords decodes NDJSON output, one map per line
+func readRecords(t *testing.T, out []byte) []map[string]interface{} {
+	t.Helper()
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		var record map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("invalid record %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestNDJSONRecords(t *testing.T) {
+	first := newGroup(&fs.File{Path: "/a/1", Size: 10}, &fs.File{Path: "/b/1", Size: 10}, &fs.File{Path: "/c/1", Size: 10})
+	second := newGroup(&fs.File{Path: "/a/2", Size: 5}, &fs.File{Path: "/b/2", Size: 5})
+	second.Matches[0].Percentage = 90
+	groups := []*engine.DuplicateGroup{first, second}
+	errs := []*fs.PathError{{Path: "/a/3", Category: fs.ErrorPermission, Err: errors.New("permission denied")}}
+
+	var out bytes.Buffer
+	w := newNDJSONWriter(&out)
+	w.Skipped = []*fs.SkippedPath{{Path: "/a/link", Type: "symlink", Target: "/elsewhere"}}
+	w.Empty = []*fs.File{{Path: "/a/empty"}}
+	for _, group := range groups {
+		w.WriteGroup(group)
+	}
+	if err := w.WriteSummary(groups, errs, 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []map[string]interface{}{
+		{
+			"type":           "group",
+			"reference":      "/a/1",
+			"reference_size": 10.0,
+			"duplicates": []interface{}{
+				map[string]interface{}{"path": "/b/1", "size": 10.0, "percentage": 100.0},
+				map[string]interface{}{"path": "/c/1", "size": 10.0, "percentage": 100.0},
+			},
+		},
+		{
+			"type":           "group",
+			"reference":      "/a/2",
+			"reference_size": 5.0,
+			"duplicates": []interface{}{
+				map[string]interface{}{"path": "/b/2", "size": 5.0, "percentage": 90.0},
+			},
+		},
+		{
+			"type":            "summary",
+			"scan_time":       "2s",
+			"group_count":     2.0,
+			"duplicate_count": 3.0,
+			"total_size":      25.0,
+			"error_count":     1.0,
+			"errors": []interface{}{
+				map[string]interface{}{"path": "/a/3", "category": "permission", "message": "permission denied"},
+			},
+			"skipped": []interface{}{
+				map[string]interface{}{"path": "/a/link", "type": "symlink", "target": "/elsewhere"},
+			},
+			"empty_files": []interface{}{"/a/empty"},
+		},
+	}
+	if got := readRecords(t, out.Bytes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got records\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestNDJSONEmptySummary(t *testing.T) {
+	var out bytes.Buffer
+	if err := newNDJSONWriter(&out).WriteSummary(nil, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	records := readRecords(t, out.Bytes())
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+	// Errors is an empty array, not null, and the optional lists are left out
+	summary := records[0]
+	if errs, ok := summary["errors"].([]interface{}); !ok || len(errs) != 0 {
+		t.Errorf("errors = %v, want []", summary["errors"])
+	}
+	for _, key := range []string{"skipped", "empty_files"} {
+		if _, ok := summary[key]; ok {
+			t.Errorf("summary has %s", key)
+		}
+	}
+}
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, os.ErrClosed }
+
+func TestNDJSONWriteError(t *testing.T) {
+	w := newNDJSONWriter(failingWriter{})
+	w.WriteGroup(newGroup(&fs.File{Path: "/a"}, &fs.File{Path: "/b"}))
+	if err := w.WriteSummary(nil, nil, 0); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("WriteSummary error = %v, want the error writing the group", err)
+	}
+}
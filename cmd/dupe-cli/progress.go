@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/progress"
+	"github.com/tendant/dupe-cli/internal/units"
+)
+
+// Progress display modes
+const (
+	ProgressAuto = "auto"
+	ProgressBar  = "bar"
+	ProgressLog  = "log"
+	ProgressNone = "none"
+)
+
+const (
+	// progressBarWidth is the number of cells in the progress bar
+	progressBarWidth = 30
+
+	// progressLogInterval is the minimum time between two progress log lines
+	progressLogInterval = 10 * time.Second
+)
+
+// newProgressFunc returns a progress receiver for the given display mode,
+// or nil if progress should not be displayed
+func newProgressFunc(mode string, w *os.File) progress.Func {
+	if mode == ProgressAuto {
+		if isTerminal(w) {
+			mode = ProgressBar
+		} else {
+			mode = ProgressLog
+		}
+	}
+
+	switch mode {
+	case ProgressBar:
+		return progressBarFunc(w)
+	case ProgressLog:
+		return progressLogFunc(w)
+	default:
+		return nil
+	}
+}
+
+// isTerminal reports whether f is attached to a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// progressBarFunc renders progress events as a single redrawn line
+func progressBarFunc(w io.Writer) progress.Func {
+	lastWidth := 0
+	return func(event progress.Event) {
+		line := formatProgressLine(event)
+		if event.Stage != progress.StageScanning && event.Stage != progress.StageDone {
+			line = renderBar(event) + " " + line
+		}
+
+		// Pad with spaces to clear the remains of a longer previous line
+		padding := ""
+		if len(line) < lastWidth {
+			padding = strings.Repeat(" ", lastWidth-len(line))
+		}
+		lastWidth = len(line)
+
+		fmt.Fprintf(w, "\r%s%s", line, padding)
+		if event.Stage == progress.StageDone {
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// progressLogFunc writes a progress line on every stage change and
+// otherwise at most once per progressLogInterval
+func progressLogFunc(w io.Writer) progress.Func {
+	var lastLog time.Time
+	lastStage := progress.Stage(-1)
+	return func(event progress.Event) {
+		now := time.Now()
+		if event.Stage == lastStage && now.Sub(lastLog) < progressLogInterval {
+			return
+		}
+		lastStage = event.Stage
+		lastLog = now
+
+		fmt.Fprintf(w, "progress: %s\n", formatProgressLine(event))
+	}
+}
+
+// renderBar renders the completed fraction of the current stage
+func renderBar(event progress.Event) string {
+	fraction := progressFraction(event)
+	filled := int(fraction * progressBarWidth)
+	return fmt.Sprintf("[%s%s] %3d%%",
+		strings.Repeat("#", filled), strings.Repeat("-", progressBarWidth-filled), int(fraction*100))
+}
+
+// progressFraction returns the completed fraction of the current stage
+func progressFraction(event progress.Event) float64 {
+	var fraction float64
+	switch {
+	case event.Stage == progress.StageHashing && event.BytesTotal > 0:
+		fraction = float64(event.BytesHashed) / float64(event.BytesTotal)
+	case event.FilesTotal > 0:
+		fraction = float64(event.FilesDone) / float64(event.FilesTotal)
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	return fraction
+}
+
+// formatProgressLine describes a progress event in one line
+func formatProgressLine(event progress.Event) string {
+	var parts []string
+
+	switch event.Stage {
+	case progress.StageScanning:
+		parts = append(parts, fmt.Sprintf("scanning, %d files found", event.FilesFound))
+	case progress.StageHashing:
+		parts = append(parts, fmt.Sprintf("hashing %d/%d files", event.FilesDone, event.FilesTotal))
+		parts = append(parts, fmt.Sprintf("%s/%s", units.FormatSize(event.BytesHashed), units.FormatSize(event.BytesTotal)))
+	case progress.StageMatching:
+		parts = append(parts, fmt.Sprintf("matching %d/%d files", event.FilesDone, event.FilesTotal))
+	case progress.StageDone:
+		parts = append(parts, fmt.Sprintf("done, %d files found", event.FilesFound))
+	}
+
+	parts = append(parts, fmt.Sprintf("elapsed %s", event.Elapsed.Round(time.Second)))
+	if event.ETA > 0 {
+		parts = append(parts, fmt.Sprintf("ETA %s", event.ETA.Round(time.Second)))
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,469 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tendant/dupe-cli/internal/cli"
+	"github.com/tendant/dupe-cli/internal/dupeguru"
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/glob"
+	"github.com/tendant/dupe-cli/internal/results"
+	"github.com/tendant/dupe-cli/internal/units"
+)
+
+// reportCommand returns the report command
+func reportCommand() *cli.Command {
+	output := outputOptions{Format: "text"}
+	return &cli.Command{
+		Name:        "report",
+		Group:       "Results commands",
+		Usage:       []string{"report FILE [flags]"},
+		Summary:     "Output saved results",
+		Description: "Output results saved with 'dupe-cli scan --save FILE'.",
+		Flags: func(fs *cli.FlagSet) {
+			fs.Group("Output flags")
+			output.define(fs)
+		},
+		Run: func(_ *cli.FlagSet, args []string) int {
+			return runReport(args, output)
+		},
+	}
+}
+
+// runReport outputs saved results
+func runReport(args []string, output outputOptions) int {
+	file, err := resultFileArg(args)
+	if err != nil {
+		return usageError(err, "report")
+	}
+	if err := output.validate(); err != nil {
+		return usageError(err, "report")
+	}
+
+	r, err := results.Load(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitFatal
+	}
+
+	output.Params = r.Params
+	if err := outputResults(output, r.DuplicateGroups(), r.PathErrors(), r.ScanDuration()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitFatal
+	}
+	return ExitOK
+}
+
+// resultFileArg returns the result file of commands taking a single one
+func resultFileArg(args []string) (string, error) {
+	switch len(args) {
+	case 0:
+		return "", fmt.Errorf("no result file specified")
+	case 1:
+		return args[0], nil
+	default:
+		return "", fmt.Errorf("unexpected argument: %s", args[1])
+	}
+}
+
+// filterOptions are the flags of the filter command
+type filterOptions struct {
+	MinSize, MaxSize int64
+	MinMatch         int
+	Path             string
+	Save             string
+	Output           outputOptions
+}
+
+// filterCommand returns the filter command
+func filterCommand() *cli.Command {
+	opts := filterOptions{Output: outputOptions{Format: "text"}}
+	return &cli.Command{
+		Name:        "filter",
+		Group:       "Results commands",
+		Usage:       []string{"filter FILE [flags]"},
+		Summary:     "Filter saved results",
+		Description: "Filter results saved with 'dupe-cli scan --save FILE'.",
+		Flags: func(fs *cli.FlagSet) {
+			fs.Group("Filter flags")
+			fs.SizeVar(&opts.MinSize, "min-size", "", "Keep groups of files of at least this size (e.g. 100M)")
+			fs.SizeVar(&opts.MaxSize, "max-size", "", "Keep groups of files of at most this size")
+			fs.IntVar(&opts.MinMatch, "min-match", "m", "Keep duplicates with at least this match percentage")
+			fs.StringVar(&opts.Path, "path", "", "Keep groups with a file matching this glob pattern: its name, or its full path if the pattern has a /")
+
+			fs.Group("Output flags")
+			opts.Output.define(fs)
+			fs.StringVar(&opts.Save, "save", "", "Save the filtered results to this file instead of printing them")
+		},
+		Run: func(_ *cli.FlagSet, args []string) int {
+			return runFilter(args, opts)
+		},
+	}
+}
+
+// runFilter filters saved results and outputs or saves them
+func runFilter(args []string, opts filterOptions) int {
+	file, err := resultFileArg(args)
+	if err != nil {
+		return usageError(err, "filter")
+	}
+	if opts.MinMatch < 0 || opts.MinMatch > 100 {
+		return usageError(fmt.Errorf("invalid min match percentage: %d", opts.MinMatch), "filter")
+	}
+	var pathGlob *glob.PathGlob
+	if opts.Path != "" {
+		if pathGlob, err = glob.CompilePaths(opts.Path); err != nil {
+			return usageError(fmt.Errorf("invalid path pattern: %s", opts.Path), "filter")
+		}
+	}
+	if err := opts.Output.validate(); err != nil {
+		return usageError(err, "filter")
+	}
+
+	r, err := results.Load(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitFatal
+	}
+
+	// Filter duplicates by match percentage, then groups by size and path
+	filtered := *r
+	filtered.Groups = make([]results.Group, 0, len(r.Groups))
+	filtered.Fingerprints = nil // Groups are left out, so they can't be kept by an incremental scan
+	for _, group := range r.Groups {
+		group = group.FilterDuplicates(func(dupe results.File, match results.Match) bool {
+			return match.Percentage >= opts.MinMatch
+		})
+		if len(group.Duplicates) == 0 {
+			continue
+		}
+		if group.Reference.Size < opts.MinSize || (opts.MaxSize > 0 && group.Reference.Size > opts.MaxSize) {
+			continue
+		}
+		if pathGlob != nil && !groupMatchesPath(group, pathGlob) {
+			continue
+		}
+		filtered.Groups = append(filtered.Groups, group)
+	}
+
+	if opts.Save != "" {
+		if err := filtered.Save(opts.Save); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: error saving results: %v\n", err)
+			return ExitFatal
+		}
+		fmt.Printf("Saved %d of %d groups to %s\n", len(filtered.Groups), len(r.Groups), opts.Save)
+		return ExitOK
+	}
+
+	opts.Output.Params = filtered.Params
+	if err := outputResults(opts.Output, filtered.DuplicateGroups(), filtered.PathErrors(), filtered.ScanDuration()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitFatal
+	}
+	return ExitOK
+}
+
+// groupMatchesPath reports whether any file in the group matches the glob pattern
+func groupMatchesPath(group results.Group, pattern *glob.PathGlob) bool {
+	files := append([]results.File{group.Reference}, group.Duplicates...)
+	for _, file := range files {
+		if pattern.Match(file.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// actCommand returns the act command
+func actCommand() *cli.Command {
+	var moveTo string
+	var del, dryRun bool
+	return &cli.Command{
+		Name:    "act",
+		Group:   "Results commands",
+		Usage:   []string{"act FILE --delete|--move-to DIR [flags]"},
+		Summary: "Delete or move the duplicates in saved results",
+		Description: `Delete or move the duplicates in results saved with 'dupe-cli scan --save FILE'.
+References and files in reference directories are kept. Files are rechecked
+first (size, modification time and digest) and skipped if they have changed.
+Duplicates are only deleted or moved if their content is the same as their
+reference's, so that the similar names of standard scans and imported
+results can be reviewed but not acted on. Duplicates that are the same file
+as their reference, such as hard links to it, are kept.`,
+		Flags: func(fs *cli.FlagSet) {
+			fs.Group("Action flags")
+			fs.BoolVar(&del, "delete", "", "Delete duplicates")
+			fs.StringVar(&moveTo, "move-to", "", "Move duplicates below this directory, keeping their full path")
+			fs.BoolVar(&dryRun, "dry-run", "n", "Show what would be done without doing it")
+		},
+		Run: func(_ *cli.FlagSet, args []string) int {
+			return runAct(args, del, moveTo, dryRun)
+		},
+	}
+}
+
+// runAct deletes or moves the duplicates in saved results, keeping the references
+func runAct(args []string, del bool, moveTo string, dryRun bool) int {
+	file, err := resultFileArg(args)
+	if err != nil {
+		return usageError(err, "act")
+	}
+	if del == (moveTo != "") {
+		return usageError(fmt.Errorf("exactly one of --delete and --move-to must be specified"), "act")
+	}
+
+	r, err := results.Load(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitFatal
+	}
+
+	verb := "Deleted"
+	if moveTo != "" {
+		verb = "Moved"
+	}
+	if dryRun {
+		verb = "Would " + strings.ToLower(strings.TrimSuffix(verb, "d"))
+	}
+
+	acted, skipped, linked := 0, 0, 0
+	var freed int64
+	for _, group := range r.Groups {
+		// Never touch duplicates whose reference is gone or has changed
+		refDigest, err := group.Reference.Verify()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping group of %s: reference %s\n", group.Reference.Path, describeVerifyError(err))
+			skipped += len(group.Duplicates)
+			continue
+		}
+		refInfo, err := os.Stat(group.Reference.Path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping group of %s: reference %s\n", group.Reference.Path, describeVerifyError(err))
+			skipped += len(group.Duplicates)
+			continue
+		}
+
+		for _, dupe := range group.Duplicates {
+			if dupe.IsReference {
+				continue
+			}
+			digest, err := dupe.Verify()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Skipping %s: %s\n", dupe.Path, describeVerifyError(err))
+				skipped++
+				continue
+			}
+			// The reference under another path, or a hard link to it, is
+			// not a copy: removing it frees nothing, or the only copy
+			if info, err := os.Stat(dupe.Path); err == nil && os.SameFile(refInfo, info) {
+				fmt.Fprintf(os.Stderr, "Keeping %s: same file as %s\n", dupe.Path, group.Reference.Path)
+				linked++
+				continue
+			}
+			// Results of standard scans and dupeGuru imports group files by
+			// name, so only files with the content of their reference go
+			if digest != refDigest {
+				fmt.Fprintf(os.Stderr, "Skipping %s: content differs from %s\n", dupe.Path, group.Reference.Path)
+				skipped++
+				continue
+			}
+
+			if !dryRun {
+				if moveTo != "" {
+					err = moveFile(dupe.Path, moveTo)
+				} else {
+					err = os.Remove(dupe.Path)
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					skipped++
+					continue
+				}
+			}
+
+			fmt.Printf("%s %s (%s)\n", verb, dupe.Path, units.FormatSize(dupe.Size))
+			acted++
+			freed += dupe.Size
+		}
+	}
+
+	fmt.Printf("\n%s %d files, %s\n", verb, acted, units.FormatSize(freed))
+	if linked > 0 {
+		fmt.Printf("Kept %d files that are the same file as their reference\n", linked)
+	}
+	if skipped > 0 {
+		fmt.Printf("Skipped %d files\n", skipped)
+		return ExitErrors
+	}
+	return ExitOK
+}
+
+// describeVerifyError describes why a file failed verification
+func describeVerifyError(err error) string {
+	switch {
+	case errors.Is(err, results.ErrChanged):
+		return "changed since the scan"
+	case errors.Is(err, os.ErrNotExist):
+		return "no longer exists"
+	default:
+		return err.Error()
+	}
+}
+
+// moveFile moves a file below dir, keeping its absolute path so that
+// files with the same name don't collide
+func moveFile(path, dir string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	dest := filepath.Join(dir, strings.TrimPrefix(abs, filepath.VolumeName(abs)))
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(dest); err == nil {
+		return fmt.Errorf("destination already exists: %s", dest)
+	}
+
+	if err := os.Rename(path, dest); err == nil {
+		return nil
+	}
+
+	// Rename fails across filesystems, copy and remove instead
+	if err := copyFile(path, dest); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// copyFile copies a file, preserving its permissions and modification time
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dest)
+		return err
+	}
+
+	return os.Chtimes(dest, info.ModTime(), info.ModTime())
+}
+
+// importCommand returns the import command
+func importCommand() *cli.Command {
+	var ignoreList, save string
+	output := outputOptions{Format: "text"}
+	return &cli.Command{
+		Name:        "import",
+		Group:       "Results commands",
+		Usage:       []string{"import FILE [flags]"},
+		Summary:     "Import dupeGuru results",
+		Description: "Import a dupeGuru results file (.dupeguru). Files that no longer exist are dropped.",
+		Flags: func(fs *cli.FlagSet) {
+			fs.Group("Import flags")
+			fs.StringVar(&ignoreList, "ignore-list", "", "Drop the pairs in this dupeGuru ignore list (ignore_list.xml)")
+
+			fs.Group("Output flags")
+			output.define(fs)
+			fs.StringVar(&save, "save", "", "Save the imported results to this file instead of printing them")
+		},
+		Run: func(_ *cli.FlagSet, args []string) int {
+			return runImport(args, ignoreList, save, output)
+		},
+	}
+}
+
+// runImport converts a dupeGuru results file to saved results
+func runImport(args []string, ignoreList, save string, output outputOptions) int {
+	if len(args) == 0 {
+		return usageError(fmt.Errorf("no dupeGuru results file specified"), "import")
+	}
+	if len(args) > 1 {
+		return usageError(fmt.Errorf("unexpected argument: %s", args[1]), "import")
+	}
+	file := args[0]
+	if err := output.validate(); err != nil {
+		return usageError(err, "import")
+	}
+
+	groups, err := dupeguru.ReadResultsFile(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitFatal
+	}
+
+	// Drop the duplicates that were ignored in dupeGuru
+	if ignoreList != "" {
+		pairs, err := dupeguru.ReadIgnoreListFile(ignoreList)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return ExitFatal
+		}
+		groups = dropIgnoredPairs(groups, pairs)
+	}
+
+	if save != "" {
+		if err := results.New(groups, nil, nil, 0).Save(save); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: error saving results: %v\n", err)
+			return ExitFatal
+		}
+		fmt.Printf("Imported %d groups to %s\n", len(groups), save)
+		return ExitOK
+	}
+
+	if err := outputResults(output, groups, nil, 0); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitFatal
+	}
+	return ExitOK
+}
+
+// dropIgnoredPairs removes the duplicates that form an ignored pair with their
+// reference, and the groups left without duplicates
+func dropIgnoredPairs(groups []*engine.DuplicateGroup, pairs []dupeguru.IgnorePair) []*engine.DuplicateGroup {
+	ignored := make(map[dupeguru.IgnorePair]bool, len(pairs)*2)
+	for _, pair := range pairs {
+		ignored[pair] = true
+		ignored[dupeguru.IgnorePair{First: pair.Second, Second: pair.First}] = true
+	}
+
+	kept := make([]*engine.DuplicateGroup, 0, len(groups))
+	for _, group := range groups {
+		filtered := &engine.DuplicateGroup{Reference: group.Reference}
+		for j, dupe := range group.Duplicates {
+			if ignored[dupeguru.IgnorePair{First: group.Reference.Path, Second: dupe.Path}] {
+				continue
+			}
+			filtered.Duplicates = append(filtered.Duplicates, dupe)
+			filtered.Matches = append(filtered.Matches, group.Matches[j])
+		}
+		if len(filtered.Duplicates) > 0 {
+			kept = append(kept, filtered)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tendant/dupe-cli/internal/results"
+)
+
+// writeFile creates a file holding content, and its directory
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// savedFile returns the saved metadata of a file as it is now
+func savedFile(t *testing.T, path string) results.File {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return results.File{Path: path, Size: info.Size(), ModTime: info.ModTime()}
+}
+
+// saveGroup saves results with one group of the reference and duplicates,
+// and returns the path of the result file
+func saveGroup(t *testing.T, dir string, ref string, dupes ...string) string {
+	t.Helper()
+	group := results.Group{Reference: savedFile(t, ref)}
+	for _, dupe := range dupes {
+		group.Duplicates = append(group.Duplicates, savedFile(t, dupe))
+		group.Matches = append(group.Matches, results.Match{First: ref, Second: dupe, Percentage: 100})
+	}
+	r := results.New(nil, nil, nil, 0)
+	r.Groups = []results.Group{group}
+	path := filepath.Join(dir, "results.json")
+	if err := r.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// exists reports whether a file exists
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func TestActDeletesCopies(t *testing.T) {
+	dir := t.TempDir()
+	ref, dupe := filepath.Join(dir, "a", "file"), filepath.Join(dir, "b", "file")
+	writeFile(t, ref, "content")
+	writeFile(t, dupe, "content")
+	res := saveGroup(t, dir, ref, dupe)
+
+	if code := runAct([]string{res}, true, "", true); code != ExitOK {
+		t.Fatalf("dry run exit code %d, want %d", code, ExitOK)
+	}
+	if !exists(dupe) {
+		t.Fatal("dry run deleted the duplicate")
+	}
+
+	if code := runAct([]string{res}, true, "", false); code != ExitOK {
+		t.Fatalf("exit code %d, want %d", code, ExitOK)
+	}
+	if !exists(ref) {
+		t.Error("reference was deleted")
+	}
+	if exists(dupe) {
+		t.Error("duplicate was not deleted")
+	}
+}
+
+func TestActMovesCopies(t *testing.T) {
+	dir := t.TempDir()
+	ref, dupe := filepath.Join(dir, "a", "file"), filepath.Join(dir, "b", "file")
+	writeFile(t, ref, "content")
+	writeFile(t, dupe, "content")
+	res := saveGroup(t, dir, ref, dupe)
+	trash := filepath.Join(dir, "trash")
+
+	if code := runAct([]string{res}, false, trash, false); code != ExitOK {
+		t.Fatalf("exit code %d, want %d", code, ExitOK)
+	}
+	if exists(dupe) || !exists(filepath.Join(trash, dupe)) {
+		t.Errorf("duplicate was not moved below %s", trash)
+	}
+	if !exists(ref) {
+		t.Error("reference was moved")
+	}
+}
+
+func TestActKeepsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	ref, dupe, other := filepath.Join(dir, "ref"), filepath.Join(dir, "dupe"), filepath.Join(dir, "other")
+	writeFile(t, ref, "content")
+	writeFile(t, dupe, "content")
+	writeFile(t, other, "different")
+	res := saveGroup(t, dir, ref, dupe, other)
+
+	// The duplicate changes after the scan
+	writeFile(t, dupe, "changed content")
+
+	if code := runAct([]string{res}, true, "", false); code != ExitErrors {
+		t.Fatalf("exit code %d, want %d", code, ExitErrors)
+	}
+	if !exists(dupe) {
+		t.Error("changed duplicate was deleted")
+	}
+	if !exists(other) {
+		t.Error("duplicate with different content was deleted")
+	}
+}
+
+func TestActKeepsSameFile(t *testing.T) {
+	dir := t.TempDir()
+	ref := filepath.Join(dir, "ref")
+	writeFile(t, ref, "content")
+	link := filepath.Join(dir, "link")
+	if err := os.Link(ref, link); err != nil {
+		t.Skipf("cannot create hard links: %v", err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	tests := []struct {
+		name string
+		dupe string
+	}{
+		{"same path spelled differently", "./ref"},
+		{"relative path", "ref"},
+		{"hard link", link},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := saveGroup(t, t.TempDir(), ref, tt.dupe)
+			if code := runAct([]string{res}, true, "", false); code != ExitOK {
+				t.Fatalf("exit code %d, want %d", code, ExitOK)
+			}
+			if !exists(ref) || !exists(tt.dupe) {
+				t.Errorf("the same file as the reference was deleted")
+			}
+		})
+	}
+}
+
+func TestFilterPath(t *testing.T) {
+	dir := t.TempDir()
+	paths := map[string]string{}
+	for _, name := range []string{"photos/a.jpg", "backup/a.jpg", "docs/b.txt", "backup/b.txt"} {
+		paths[name] = filepath.Join(dir, filepath.FromSlash(name))
+		writeFile(t, paths[name], name)
+	}
+	r := results.New(nil, nil, nil, 0)
+	r.Groups = []results.Group{
+		{Reference: savedFile(t, paths["photos/a.jpg"]), Duplicates: []results.File{savedFile(t, paths["backup/a.jpg"])}},
+		{Reference: savedFile(t, paths["docs/b.txt"]), Duplicates: []results.File{savedFile(t, paths["backup/b.txt"])}},
+	}
+	res := filepath.Join(dir, "results.json")
+	if err := r.Save(res); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		pattern string
+		want    []string // References of the groups kept
+	}{
+		{"*.jpg", []string{paths["photos/a.jpg"]}},
+		{"b.*", []string{paths["docs/b.txt"]}},
+		{"**/docs/*", []string{paths["docs/b.txt"]}},
+		{"**/backup/*", []string{paths["photos/a.jpg"], paths["docs/b.txt"]}},
+		{"docs", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			saved := filepath.Join(t.TempDir(), "filtered.json")
+			opts := filterOptions{Path: tt.pattern, Save: saved, Output: outputOptions{Format: "text"}}
+			if code := runFilter([]string{res}, opts); code != ExitOK {
+				t.Fatalf("exit code %d, want %d", code, ExitOK)
+			}
+			filtered, err := results.Load(saved)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, group := range filtered.Groups {
+				got = append(got, group.Reference.Path)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("kept groups of %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
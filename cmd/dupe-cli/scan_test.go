@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// runApp runs the app with args in dir, without config files and with its
+// output discarded, and returns the exit code
+func runApp(t *testing.T, dir string, args ...string) int {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("HOME", dir)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = devNull, devNull
+	defer func() { os.Stdout, os.Stderr = stdout, stderr }()
+
+	return newApp().Run(args)
+}
+
+func TestScanExitCode(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "tree", "a"), "content")
+	writeFile(t, filepath.Join(dir, "tree", "b"), "content")
+	// The listed file is missing, so it can't be read
+	list := filepath.Join(dir, "list")
+	writeFile(t, list, filepath.Join(dir, "missing")+"\n")
+	// Following the link loops
+	loop := filepath.Join(dir, "loop", "self")
+	if err := os.MkdirAll(filepath.Dir(loop), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("self", loop); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+
+	tree, loopDir := filepath.Join(dir, "tree"), filepath.Join(dir, "loop")
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"all read", []string{"scan", "-r", "-s", "content", tree}, ExitOK},
+		{"missing listed file", []string{"scan", "-s", "content", "--from-file", list, tree}, ExitErrors},
+		{"link loop", []string{"scan", "-r", "--symlinks", "follow", tree, loopDir}, ExitErrors},
+		{"strict, missing listed file", []string{"scan", "--strict", "--from-file", list, tree}, ExitFatal},
+		{"strict, link loop", []string{"scan", "-r", "--strict", "--symlinks", "follow", tree, loopDir}, ExitFatal},
+		{"missing directory", []string{"scan", filepath.Join(dir, "missing")}, ExitFatal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runApp(t, dir, tt.args...); got != tt.want {
+				t.Errorf("exit code %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
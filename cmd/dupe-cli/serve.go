@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/tendant/dupe-cli/internal/cli"
+	"github.com/tendant/dupe-cli/internal/htmlreport"
+	"github.com/tendant/dupe-cli/internal/results"
+)
+
+// serveCommand returns the serve command
+func serveCommand() *cli.Command {
+	addr := "localhost:8080"
+	return &cli.Command{
+		Name:    "serve",
+		Group:   "Results commands",
+		Usage:   []string{"serve FILE [flags]"},
+		Summary: "Serve the HTML report of saved results",
+		Description: `Serve the HTML report of results saved with 'dupe-cli scan --save FILE'.
+The file is read again for each request, so the report follows later saves.
+The saved results themselves are served at /results.json.`,
+		Flags: func(fs *cli.FlagSet) {
+			fs.Group("Server flags")
+			fs.StringVar(&addr, "addr", "", "Address to listen on")
+		},
+		Run: func(_ *cli.FlagSet, args []string) int {
+			return runServe(args, addr)
+		},
+	}
+}
+
+// runServe serves the HTML report of a result file until interrupted
+func runServe(args []string, addr string) int {
+	file, err := resultFileArg(args)
+	if err != nil {
+		return usageError(err, "serve")
+	}
+	if _, err := results.Load(file); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitFatal
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" {
+			http.NotFound(w, req)
+			return
+		}
+		r, err := results.Load(file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := htmlreport.Write(w, r.DuplicateGroups(), r.PathErrors(), r.ScanDuration()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	})
+	mux.HandleFunc("/results.json", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		http.ServeFile(w, req, file)
+	})
+
+	fmt.Fprintf(os.Stderr, "Serving the report of %s at http://%s/\n", file, addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitFatal
+	}
+	return ExitOK
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/cli"
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+	"github.com/tendant/dupe-cli/internal/ignore"
+	"github.com/tendant/dupe-cli/internal/scanner"
+	"github.com/tendant/dupe-cli/internal/units"
+	"github.com/tendant/dupe-cli/internal/watch"
+)
+
+// watchFlags are the flags of the watch command
+type watchFlags struct {
+	ScanParams
+	Log        bool
+	Settle     time.Duration
+	Readers    int
+	IgnoreFile string
+	NoIgnore   bool
+	Profile    string
+}
+
+// watchCommand returns the watch command
+func watchCommand() *cli.Command {
+	flags := &watchFlags{
+		ScanParams: ScanParams{
+			Directories: []string{},
+			Symlinks:    string(fs.SymlinksSkip),
+			Empty:       string(scanner.EmptySkip),
+		},
+		Settle:     watch.DefaultSettle,
+		Readers:    scanner.DefaultReaders,
+		IgnoreFile: ignore.DefaultPath(),
+	}
+
+	return &cli.Command{
+		Name:    "watch",
+		Group:   "Scanning commands",
+		Usage:   []string{"watch [flags] DIR..."},
+		Summary: "Watch directories and report duplicates as they appear",
+		Description: `The directories are scanned by content, and their duplicate groups printed.
+The directories are then watched, and files that become duplicates of others,
+or stop being duplicates, are reported as they are created, modified, moved
+or deleted, without scanning again. Events are printed as NDJSON records, or
+as log lines with --log, until the command is interrupted.
+
+Watching uses inotify, and is only supported on Linux.`,
+		Flags: flags.define,
+		Run: func(fs *cli.FlagSet, args []string) int {
+			if len(args) > 0 && !fs.Changed("directories") {
+				flags.Directories = args
+			} else {
+				flags.Directories = append(flags.Directories, args...)
+			}
+			return runWatch(flags)
+		},
+		Examples: `  # Report the duplicates uploaded to a directory tree
+  dupe-cli watch -r /srv/uploads
+
+  # Log them instead, ignoring small files
+  dupe-cli watch -r --log --min-size 1M /srv/uploads`,
+	}
+}
+
+// define defines the flags of the watch command
+func (f *watchFlags) define(flagSet *cli.FlagSet) {
+	flagSet.Group("Watch flags")
+	flagSet.ListVar(&f.Directories, "directories", "d", "Directories to watch, comma-separated")
+	flagSet.BoolVar(&f.Recursive, "recursive", "r", "Watch directories recursively")
+	flagSet.BoolVar(&f.OneFileSystem, "one-file-system", "x", "Don't walk directories on other filesystems than the watched directories")
+	flagSet.StringVar(&f.ExcludePattern, "exclude", "e", "Skip files whose names match these glob patterns, comma-separated")
+	flagSet.EnumVar(&f.Symlinks, fs.SymlinkPolicies, "symlinks", "", "Skip symbolic links, follow them, or skip and report them")
+	flagSet.EnumVar(&f.Empty, scanner.EmptyPolicies, "empty", "", "Skip empty files, or compare them as duplicates (report skips them)")
+	flagSet.DurationVar(&f.Settle, "settle", "", "Time a file must go unchanged before it is checked, unless its writer closes it")
+	flagSet.IntVar(&f.Readers, "readers", "", "Number of directories to read at the same time")
+	flagSet.BoolVar(&f.Log, "log", "", "Print events as log lines instead of NDJSON records")
+
+	f.defineFilters(flagSet)
+
+	flagSet.Group("Ignore list flags")
+	flagSet.StringVar(&f.IgnoreFile, "ignore-file", "", "Ignore list file")
+	flagSet.BoolVar(&f.NoIgnore, "no-ignore", "", "Don't use the ignore list")
+
+	flagSet.Group("Config flags")
+	flagSet.StringVar(&f.Profile, "profile", "", "Use the settings of this profile of the config files")
+}
+
+// runWatch scans the directories, then reports the changes of their
+// duplicates until interrupted
+func runWatch(flags *watchFlags) int {
+	if flags.Settle < 0 {
+		return usageError(fmt.Errorf("invalid settle time: %s", flags.Settle), "watch")
+	}
+	if flags.Readers < 1 {
+		return usageError(fmt.Errorf("invalid number of readers: %d", flags.Readers), "watch")
+	}
+	if len(flags.Directories) == 0 {
+		return usageError(fmt.Errorf("no directories specified"), "watch")
+	}
+	for _, dir := range flags.Directories {
+		info, err := os.Stat(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Directory not found: %s\n", dir)
+			return ExitFatal
+		}
+		if !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "Error: Not a directory: %s\n", dir)
+			return ExitFatal
+		}
+	}
+
+	s, err := scanner.NewScanner(flags.Directories, flags.ExcludePattern, flags.Recursive, scanner.ScanTypeContent, 100)
+	if err != nil {
+		return usageError(fmt.Errorf("--exclude: %w", err), "watch")
+	}
+	s.Symlinks = fs.SymlinkPolicy(flags.Symlinks)
+	s.Empty = scanner.EmptyPolicy(flags.Empty)
+	s.Readers = flags.Readers
+	s.OneFileSystem = flags.OneFileSystem
+	s.Filters, s.DirFilters, err = flags.filters()
+	if err != nil {
+		return usageError(err, "watch")
+	}
+
+	w := watch.NewWatcher(s)
+	w.Settle = flags.Settle
+	if !flags.NoIgnore {
+		list, err := ignore.Load(flags.IgnoreFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return ExitFatal
+		}
+		w.Ignore = list
+	}
+
+	var out eventWriter = newNDJSONEventWriter(os.Stdout)
+	if flags.Log {
+		out = &logEventWriter{w: os.Stdout}
+	}
+	w.OnEvent = out.WriteEvent
+	w.OnError = out.WriteError
+
+	fmt.Fprintf(os.Stderr, "Scanning directories: %s\n", strings.Join(flags.Directories, ", "))
+	startTime := time.Now()
+	groups, err := w.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitFatal
+	}
+	out.WriteGroups(groups, time.Since(startTime))
+	fmt.Fprintf(os.Stderr, "Watching %s for changes, press Ctrl-C to stop\n", strings.Join(flags.Directories, ", "))
+
+	// Stop watching when interrupted
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupt
+		w.Close()
+	}()
+
+	if err := w.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return ExitFatal
+	}
+	return ExitOK
+}
+
+// eventWriter writes the initial duplicate groups and the events of a watch
+type eventWriter interface {
+	WriteGroups(groups []*engine.DuplicateGroup, scanTime time.Duration)
+	WriteEvent(event watch.Event)
+	WriteError(pathErr *fs.PathError)
+}
+
+// ndjsonEvent is the record written for each event
+type ndjsonEvent struct {
+	Type  string   `json:"type"` // "duplicate" or "resolved"
+	Time  string   `json:"time"`
+	Path  string   `json:"path"`
+	Size  int64    `json:"size"`
+	Files []string `json:"files"`
+}
+
+// ndjsonWatchError is the record written for each path that could not be read
+type ndjsonWatchError struct {
+	Type string `json:"type"` // Always "error"
+	ndjsonError
+}
+
+// ndjsonWatching is the record written once the initial scan is done
+type ndjsonWatching struct {
+	Type           string `json:"type"` // Always "watching"
+	ScanTime       string `json:"scan_time"`
+	GroupCount     int    `json:"group_count"`
+	DuplicateCount int    `json:"duplicate_count"`
+	TotalSize      int64  `json:"total_size"`
+}
+
+// ndjsonEventWriter writes the groups and events of a watch as NDJSON:
+// a record per group, a watching record, then a record per event
+type ndjsonEventWriter struct {
+	groups *ndjsonWriter
+	enc    *json.Encoder
+}
+
+// newNDJSONEventWriter creates a writer of NDJSON watch records
+func newNDJSONEventWriter(w io.Writer) *ndjsonEventWriter {
+	return &ndjsonEventWriter{groups: newNDJSONWriter(w), enc: json.NewEncoder(w)}
+}
+
+// WriteGroups writes the records of the initial groups, and the watching record
+func (w *ndjsonEventWriter) WriteGroups(groups []*engine.DuplicateGroup, scanTime time.Duration) {
+	for _, group := range groups {
+		w.groups.WriteGroup(group)
+	}
+	w.write(ndjsonWatching{
+		Type:           "watching",
+		ScanTime:       scanTime.String(),
+		GroupCount:     len(groups),
+		DuplicateCount: engine.TotalDuplicateCount(groups),
+		TotalSize:      engine.TotalDuplicateSize(groups),
+	})
+}
+
+// WriteEvent writes the record of an event
+func (w *ndjsonEventWriter) WriteEvent(event watch.Event) {
+	w.write(ndjsonEvent{
+		Type:  string(event.Type),
+		Time:  event.Time.Format(time.RFC3339),
+		Path:  event.Path,
+		Size:  event.Size,
+		Files: event.Files,
+	})
+}
+
+// WriteError writes the record of a path that could not be read
+func (w *ndjsonEventWriter) WriteError(pathErr *fs.PathError) {
+	w.write(ndjsonWatchError{
+		Type: "error",
+		ndjsonError: ndjsonError{
+			Path:     pathErr.Path,
+			Category: pathErr.Category.String(),
+			Message:  pathErr.Err.Error(),
+		},
+	})
+}
+
+// write writes a record. The watch goes on if stdout fails, so errors
+// are reported on stderr.
+func (w *ndjsonEventWriter) write(record interface{}) {
+	if err := w.enc.Encode(record); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: error writing event: %v\n", err)
+	}
+}
+
+// logEventWriter writes the groups and events of a watch as log lines
+type logEventWriter struct {
+	w io.Writer
+}
+
+// WriteGroups writes a line per initial group, and a summary line
+func (l *logEventWriter) WriteGroups(groups []*engine.DuplicateGroup, scanTime time.Duration) {
+	now := time.Now().Format(time.RFC3339)
+	for _, group := range groups {
+		var dupes []string
+		for _, dupe := range group.Duplicates {
+			dupes = append(dupes, dupe.Path)
+		}
+		fmt.Fprintf(l.w, "%s group %s (%s): %s\n", now, group.Reference.Path,
+			units.FormatSize(group.Reference.Size), strings.Join(dupes, ", "))
+	}
+	fmt.Fprintf(l.w, "%s watching: %d duplicate groups with %d duplicates (%s), scanned in %s\n", now,
+		len(groups), engine.TotalDuplicateCount(groups), units.FormatSize(engine.TotalDuplicateSize(groups)), scanTime)
+}
+
+// WriteEvent writes the line of an event
+func (l *logEventWriter) WriteEvent(event watch.Event) {
+	verb := "duplicate of"
+	if event.Type == watch.EventResolved {
+		verb = "no longer a duplicate of"
+	}
+	fmt.Fprintf(l.w, "%s %s %s (%s): %s %s\n", event.Time.Format(time.RFC3339), event.Type, event.Path,
+		units.FormatSize(event.Size), verb, strings.Join(event.Files, ", "))
+}
+
+// WriteError writes the line of a path that could not be read
+func (l *logEventWriter) WriteError(pathErr *fs.PathError) {
+	fmt.Fprintf(l.w, "%s error %s: %v\n", time.Now().Format(time.RFC3339), pathErr.Path, pathErr.Err)
+}
@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/fs"
+)
+
+// Version is the version of the cache file format
+const Version = 1
+
+// Entry is the cached digests of a file, valid while the file keeps its
+// size, modification time and inode
+type Entry struct {
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"mtime"`
+	Dev        uint64    `json:"dev,omitempty"`
+	Inode      uint64    `json:"inode,omitempty"`
+	Digest     string    `json:"digest,omitempty"`      // Hex-encoded full digest
+	DigestPart string    `json:"digest_part,omitempty"` // Hex-encoded partial digest
+}
+
+// Cache is a persistent cache of file digests, keyed by absolute path, so
+// that unchanged files are not hashed again by later scans
+type Cache struct {
+	Entries map[string]*Entry
+
+	path  string // Path of the cache file
+	cwd   string // Working directory, to make relative scan paths absolute
+	dirty bool   // Whether entries changed since the cache was loaded
+	mu    sync.Mutex
+}
+
+// cacheFile is the on-disk format of the cache
+type cacheFile struct {
+	Version int               `json:"version"`
+	Entries map[string]*Entry `json:"entries"`
+}
+
+// DefaultPath returns the default location of the cache file
+func DefaultPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ".dupe-cli-cache.json"
+	}
+	return filepath.Join(dir, "dupe-cli", "digests.json")
+}
+
+// Load reads a cache file. A missing file is an empty cache.
+func Load(path string) (*Cache, error) {
+	c := &Cache{path: path, Entries: make(map[string]*Entry)}
+	c.cwd, _ = os.Getwd()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file cacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid cache %s: %w", path, err)
+	}
+	if file.Version != Version {
+		return nil, fmt.Errorf("unsupported cache version %d in %s", file.Version, path)
+	}
+	if file.Entries != nil {
+		c.Entries = file.Entries
+	}
+	return c, nil
+}
+
+// Path returns the path of the cache file
+func (c *Cache) Path() string {
+	return c.path
+}
+
+// Len returns the number of cached files
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.Entries)
+}
+
+// Apply sets the digests of a file from the cache if the file is unchanged
+// since they were cached, and reports whether it did
+func (c *Cache) Apply(file *fs.File) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.Entries[c.abs(file.Path)]
+	if entry == nil || !entry.matches(file) {
+		return false
+	}
+
+	if digest, err := hex.DecodeString(entry.Digest); err == nil && len(digest) > 0 {
+		file.Digest = digest
+	}
+	if digest, err := hex.DecodeString(entry.DigestPart); err == nil && len(digest) > 0 {
+		file.DigestPart = digest
+	}
+	return file.Digest != nil || file.DigestPart != nil
+}
+
+// Store caches the digests of a file. It has the signature of
+// engine.Engine.OnFileHashed.
+func (c *Cache) Store(file *fs.File) {
+	if file.Digest == nil && file.DigestPart == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := c.abs(file.Path)
+	entry := c.Entries[path]
+	if entry == nil || !entry.matches(file) {
+		entry = &Entry{Size: file.Size, ModTime: file.ModTime, Dev: file.Dev, Inode: file.Inode}
+		c.Entries[path] = entry
+	}
+	if file.Digest != nil {
+		entry.Digest = hex.EncodeToString(file.Digest)
+	}
+	if file.DigestPart != nil {
+		entry.DigestPart = hex.EncodeToString(file.DigestPart)
+	}
+	c.dirty = true
+}
+
+// matches reports whether a file is unchanged since the entry was cached
+func (e *Entry) matches(file *fs.File) bool {
+	if e.Size != file.Size || !e.ModTime.Equal(file.ModTime) {
+		return false
+	}
+	// A file replaced by another one with the same size and time
+	if e.Inode != 0 && file.Inode != 0 && (e.Dev != file.Dev || e.Inode != file.Inode) {
+		return false
+	}
+	return true
+}
+
+// Prune removes the entries of files that no longer exist or have changed,
+// and returns the number of entries removed
+func (c *Cache) Prune() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for path, entry := range c.Entries {
+		file, err := fs.NewFile(path)
+		if err != nil || !entry.matches(file) {
+			delete(c.Entries, path)
+			removed++
+		}
+	}
+	if removed > 0 {
+		c.dirty = true
+	}
+	return removed
+}
+
+// Clear removes all entries
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Entries = make(map[string]*Entry)
+	c.dirty = true
+}
+
+// Save writes the cache file if entries changed since it was loaded
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(cacheFile{Version: Version, Entries: c.Entries})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so an interrupted save keeps the old cache
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// abs makes a path absolute against the working directory the cache was loaded in
+func (c *Cache) abs(path string) string {
+	if filepath.IsAbs(path) || c.cwd == "" {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(c.cwd, path)
+}
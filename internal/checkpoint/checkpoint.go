@@ -0,0 +1,467 @@
+package checkpoint
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/fs"
+	"github.com/tendant/dupe-cli/internal/scanner"
+)
+
+// Version is the version of the checkpoint file format
+const Version = 2
+
+// DefaultInterval is the default minimum time between two saves of a checkpoint
+const DefaultInterval = time.Minute
+
+// bufferSize is the size of the buffer of the records appended to a checkpoint
+const bufferSize = 64 * 1024
+
+// A checkpoint file is a journal: a header line, followed by one line per
+// record appended as the scan goes. Nothing written is ever rewritten, so
+// saving costs the same at the end of a long scan as at its start.
+
+// Header is the first line of a checkpoint file
+type Header struct {
+	Version int             `json:"version"`
+	Params  json.RawMessage `json:"params"` // Parameters of the scan, as defined by the caller
+	Roots   []string        `json:"roots"`  // Scanned directories
+}
+
+// Record is a line of a checkpoint file after the header: directories
+// whose whole subtree has been walked, with what was found directly in
+// them, or digests calculated by the scan
+type Record struct {
+	Root    int       `json:"root"` // Index of the scanned directory of Dirs
+	Dirs    []string  `json:"dirs,omitempty"`
+	Files   []File    `json:"files,omitempty"`
+	Errors  []Error   `json:"errors,omitempty"`
+	Skipped []Skipped `json:"skipped,omitempty"`
+	Digests []File    `json:"digests,omitempty"` // Files whose digests were calculated
+}
+
+// Checkpoint is the saved state of a scan
+type Checkpoint struct {
+	Params json.RawMessage // Parameters of the scan, as defined by the caller
+	Roots  []Root          // Walk progress, indexed like the scanned directories
+
+	// Digests calculated for files not found by the walks, such as files
+	// given individually, by path
+	digests map[string]File
+}
+
+// Root is the walk progress of one scanned directory
+type Root struct {
+	Path       string
+	WalkedDirs []string
+	Files      []File
+	Errors     []Error
+	Skipped    []Skipped
+}
+
+// File is a file found by the walk, with the digests calculated so far
+type File struct {
+	Path        string    `json:"path"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mtime"`
+	IsReference bool      `json:"is_reference,omitempty"`
+	Digest      []byte    `json:"digest,omitempty"`
+	DigestPart  []byte    `json:"digest_part,omitempty"`
+	Dev         uint64    `json:"dev,omitempty"`
+	Inode       uint64    `json:"inode,omitempty"`
+	Symlink     bool      `json:"symlink,omitempty"`
+}
+
+// Error is an error for a path that was skipped by the walk
+type Error struct {
+	Path     string `json:"path"`
+	Category string `json:"category"`
+	Message  string `json:"message"`
+}
+
+// Skipped is a path that was skipped by the walk and is reported, such as
+// a special file
+type Skipped struct {
+	Path   string `json:"path"`
+	Type   string `json:"type"`
+	Target string `json:"target,omitempty"`
+}
+
+// Load reads a checkpoint file. A last record cut short by an interruption
+// is left out.
+func Load(path string) (*Checkpoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	line, err := r.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	var header Header
+	if err := json.Unmarshal(line, &header); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint file %s: %w", path, err)
+	}
+	if header.Version != Version {
+		return nil, fmt.Errorf("unsupported checkpoint version %d in %s", header.Version, path)
+	}
+
+	cp := &Checkpoint{
+		Params:  header.Params,
+		Roots:   make([]Root, len(header.Roots)),
+		digests: make(map[string]File),
+	}
+	for i, dir := range header.Roots {
+		cp.Roots[i].Path = dir
+	}
+
+	for n := 2; err == nil; n++ {
+		line, err = r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if err == io.EOF {
+			// Without its newline, the record was not written completely
+			break
+		}
+
+		var rec Record
+		if jsonErr := json.Unmarshal(line, &rec); jsonErr != nil {
+			return nil, fmt.Errorf("invalid checkpoint file %s, line %d: %w", path, n, jsonErr)
+		}
+		cp.add(rec)
+	}
+
+	// Digests were recorded after the files they belong to were found
+	for i := range cp.Roots {
+		for j := range cp.Roots[i].Files {
+			if file := &cp.Roots[i].Files[j]; cp.applyDigests(file) {
+				delete(cp.digests, file.Path)
+			}
+		}
+	}
+	return cp, nil
+}
+
+// add adds a record to the checkpoint
+func (c *Checkpoint) add(rec Record) {
+	for _, f := range rec.Digests {
+		c.digests[f.Path] = f
+	}
+	if len(rec.Dirs) == 0 || rec.Root < 0 || rec.Root >= len(c.Roots) {
+		return
+	}
+	root := &c.Roots[rec.Root]
+	root.WalkedDirs = append(root.WalkedDirs, rec.Dirs...)
+	root.Files = append(root.Files, rec.Files...)
+	root.Errors = append(root.Errors, rec.Errors...)
+	root.Skipped = append(root.Skipped, rec.Skipped...)
+}
+
+// applyDigests sets the digests recorded for a file, if it has the same
+// size and modification time as when they were calculated
+func (c *Checkpoint) applyDigests(file *File) bool {
+	saved, ok := c.digests[file.Path]
+	if !ok || saved.Size != file.Size || !saved.ModTime.Equal(file.ModTime) {
+		return false
+	}
+	if saved.Digest != nil {
+		file.Digest = saved.Digest
+	}
+	if saved.DigestPart != nil {
+		file.DigestPart = saved.DigestPart
+	}
+	return true
+}
+
+// Apply sets the digests the checkpointed scan calculated for a file given
+// individually rather than found by a walk, if it is unchanged since, and
+// reports whether it did
+func (c *Checkpoint) Apply(file *fs.File) bool {
+	f := File{Path: file.Path, Size: file.Size, ModTime: file.ModTime}
+	if !c.applyDigests(&f) {
+		return false
+	}
+	if f.Digest != nil {
+		file.Digest = f.Digest
+	}
+	if f.DigestPart != nil {
+		file.DigestPart = f.DigestPart
+	}
+	return true
+}
+
+// RootStates converts the checkpoint to the scanner's resume state
+func (c *Checkpoint) RootStates() []*scanner.RootState {
+	states := make([]*scanner.RootState, len(c.Roots))
+	for i, root := range c.Roots {
+		state := &scanner.RootState{
+			WalkedDirs: root.WalkedDirs,
+			Files:      make([]*fs.File, 0, len(root.Files)),
+			Errors:     make([]*fs.PathError, 0, len(root.Errors)),
+			Skipped:    make([]*fs.SkippedPath, 0, len(root.Skipped)),
+		}
+
+		for _, f := range root.Files {
+			state.Files = append(state.Files, &fs.File{
+				Path:        f.Path,
+				Name:        filepath.Base(f.Path),
+				Size:        f.Size,
+				ModTime:     f.ModTime,
+				IsReference: f.IsReference,
+				Digest:      f.Digest,
+				DigestPart:  f.DigestPart,
+				Dev:         f.Dev,
+				Inode:       f.Inode,
+				Symlink:     f.Symlink,
+			})
+		}
+
+		for _, e := range root.Errors {
+			state.Errors = append(state.Errors, &fs.PathError{
+				Path:     e.Path,
+				Category: fs.ParseErrorCategory(e.Category),
+				Err:      errors.New(e.Message),
+			})
+		}
+
+		for _, s := range root.Skipped {
+			state.Skipped = append(state.Skipped, &fs.SkippedPath{Path: s.Path, Type: s.Type, Target: s.Target})
+		}
+
+		states[i] = state
+	}
+	return states
+}
+
+// Writer appends the progress of a scan to a checkpoint file, and
+// periodically flushes it to disk
+type Writer struct {
+	path     string          // Path of the checkpoint file
+	params   json.RawMessage // Parameters of the scan
+	dirs     []string        // Scanned directories
+	interval time.Duration   // Minimum time between two periodic saves
+	mu       sync.Mutex      // Mutex for thread safety
+	lastSave time.Time       // Time of the last save
+	resumed  *Checkpoint     // Previous scan, written when the file is created
+	file     *os.File        // Checkpoint file, once created
+	buf      *bufio.Writer   // Records not written to the file yet
+	err      error           // First error writing the file
+}
+
+// NewWriter creates a new Writer for a scan of the given directories. The
+// checkpoint file is created with the first record.
+func NewWriter(path string, params json.RawMessage, dirs []string, interval time.Duration) *Writer {
+	return &Writer{
+		path:     path,
+		params:   params,
+		dirs:     dirs,
+		interval: interval,
+		lastSave: time.Now(),
+	}
+}
+
+// Resume seeds the writer with the progress of a previous scan, which is
+// written once when the checkpoint file is created. It must be called
+// before the scan starts.
+func (w *Writer) Resume(cp *Checkpoint) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.resumed = cp
+}
+
+// DirDone records a directory whose whole subtree has been walked.
+// It has the signature of scanner.Scanner.OnDirDone.
+func (w *Writer) DirDone(root int, dir string, files []*fs.File, errs []*fs.PathError, skipped []*fs.SkippedPath) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if root >= len(w.dirs) {
+		return
+	}
+	w.write(newRecord(root, []string{dir}, files, errs, skipped))
+	w.maybeSave()
+}
+
+// FileHashed records a newly calculated digest.
+// It has the signature of engine.Engine.OnFileHashed.
+func (w *Writer) FileHashed(file *fs.File) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.write(Record{Root: -1, Digests: []File{newFile(file)}})
+	w.maybeSave()
+}
+
+// Close writes the records not written yet and closes the checkpoint file.
+// It returns the first error met writing the file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.open()
+	w.flush()
+	if w.file != nil {
+		if err := w.file.Close(); err != nil && w.err == nil {
+			w.err = err
+		}
+		w.file = nil
+	}
+	return w.err
+}
+
+// maybeSave flushes the records to disk if the interval has passed since
+// the last save. Errors are reported by Close at the end of the scan.
+func (w *Writer) maybeSave() {
+	if time.Since(w.lastSave) < w.interval {
+		return
+	}
+	w.flush()
+}
+
+// flush writes the buffered records and syncs the file
+func (w *Writer) flush() {
+	w.lastSave = time.Now()
+	if w.buf == nil || w.err != nil {
+		return
+	}
+	if err := w.buf.Flush(); err != nil {
+		w.err = err
+		return
+	}
+	if err := w.file.Sync(); err != nil {
+		w.err = err
+	}
+}
+
+// write appends a record, creating the file first if needed
+func (w *Writer) write(rec Record) {
+	w.open()
+	if w.err != nil {
+		return
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		w.err = err
+		return
+	}
+	data = append(data, '\n')
+	if _, err := w.buf.Write(data); err != nil {
+		w.err = err
+	}
+}
+
+// open creates the checkpoint file with its header and the progress of the
+// resumed scan. The file is written aside and renamed, so an interruption
+// while creating it leaves the previous checkpoint intact.
+func (w *Writer) open() {
+	if w.file != nil || w.err != nil {
+		return
+	}
+
+	var data bytes.Buffer
+	enc := json.NewEncoder(&data)
+	if err := enc.Encode(Header{Version: Version, Params: w.params, Roots: w.dirs}); err != nil {
+		w.err = err
+		return
+	}
+	for _, rec := range w.resumed.records() {
+		if rec.Root >= len(w.dirs) {
+			continue
+		}
+		if err := enc.Encode(rec); err != nil {
+			w.err = err
+			return
+		}
+	}
+	w.resumed = nil
+
+	tmpPath := w.path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		w.err = err
+		return
+	}
+	if _, err := file.Write(data.Bytes()); err != nil {
+		file.Close()
+		w.err = err
+		return
+	}
+	if err := os.Rename(tmpPath, w.path); err != nil {
+		file.Close()
+		w.err = err
+		return
+	}
+	w.file = file
+	w.buf = bufio.NewWriterSize(file, bufferSize)
+}
+
+// records returns the records that hold the progress of the checkpoint
+// (none if it is nil)
+func (c *Checkpoint) records() []Record {
+	if c == nil {
+		return nil
+	}
+	var recs []Record
+	for i, root := range c.Roots {
+		if len(root.WalkedDirs) > 0 {
+			recs = append(recs, Record{Root: i, Dirs: root.WalkedDirs, Files: root.Files, Errors: root.Errors, Skipped: root.Skipped})
+		}
+	}
+	if len(c.digests) > 0 {
+		rec := Record{Root: -1}
+		for _, f := range c.digests {
+			rec.Digests = append(rec.Digests, f)
+		}
+		sort.Slice(rec.Digests, func(i, j int) bool { return rec.Digests[i].Path < rec.Digests[j].Path })
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+// newRecord returns the record of walked directories of a scanned directory
+func newRecord(root int, dirs []string, files []*fs.File, errs []*fs.PathError, skipped []*fs.SkippedPath) Record {
+	rec := Record{Root: root, Dirs: dirs}
+	for _, f := range files {
+		rec.Files = append(rec.Files, newFile(f))
+	}
+	for _, e := range errs {
+		rec.Errors = append(rec.Errors, Error{
+			Path:     e.Path,
+			Category: e.Category.String(),
+			Message:  e.Err.Error(),
+		})
+	}
+	for _, s := range skipped {
+		rec.Skipped = append(rec.Skipped, Skipped{Path: s.Path, Type: s.Type, Target: s.Target})
+	}
+	return rec
+}
+
+// newFile converts a scanned file to its saved form
+func newFile(f *fs.File) File {
+	return File{
+		Path:        f.Path,
+		Size:        f.Size,
+		ModTime:     f.ModTime,
+		IsReference: f.IsReference,
+		Digest:      f.Digest,
+		DigestPart:  f.DigestPart,
+		Dev:         f.Dev,
+		Inode:       f.Inode,
+		Symlink:     f.Symlink,
+	}
+}
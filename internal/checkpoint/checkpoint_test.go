@@ -0,0 +1,154 @@
+package checkpoint
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+	"github.com/tendant/dupe-cli/internal/matcher"
+	"github.com/tendant/dupe-cli/internal/scanner"
+)
+
+// writeTree creates the files under root, with their contents
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		full := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// scan runs a content scan of dir and the listed files, saving a checkpoint
+// to path and resuming cp if it is not nil. It returns the groups, as the
+// paths of their files, and the number of files hashed.
+func scan(t *testing.T, dir string, listed []string, path string, cp *Checkpoint) ([]string, int) {
+	t.Helper()
+	s, err := scanner.NewScanner([]string{dir}, "", true, scanner.ScanTypeContent, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Files = listed
+	s.Empty = scanner.EmptyDuplicates
+	e := engine.NewEngine(s, matcher.NewMatcher(matcher.MatchOptions{Type: matcher.MatchTypeExact}))
+
+	w := NewWriter(path, []byte(`{}`), s.Directories, time.Hour)
+	if cp != nil {
+		s.Resume = cp.RootStates()
+		w.Resume(cp)
+		s.OnFile = func(file *fs.File) { cp.Apply(file) }
+	}
+	hashed := 0
+	s.OnDirDone = w.DirDone
+	e.OnFileHashed = func(file *fs.File) {
+		hashed++
+		w.FileHashed(file)
+	}
+
+	groups, err := e.FindDuplicates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, group := range groups {
+		paths := []string{group.Reference.Path}
+		for _, dupe := range group.Duplicates {
+			paths = append(paths, dupe.Path)
+		}
+		got = append(got, strings.Join(paths, " "))
+	}
+	return got, hashed
+}
+
+func TestResumeMatchesUninterruptedScan(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"dir/a/one":      "first",
+		"dir/a/two":      "second",
+		"dir/b/one":      "first",
+		"dir/b/c/one":    "first",
+		"dir/b/c/two":    "second",
+		"dir/d/three":    "third",
+		"dir/d/e/f/four": "fourth",
+		"dir/empty":      "",
+		"listed/one":     "first",
+		"listed/four":    "fourth",
+		"listed/five":    "fifth",
+	})
+	dir := filepath.Join(root, "dir")
+	listed := []string{filepath.Join(root, "listed", "one"), filepath.Join(root, "listed", "four"), filepath.Join(root, "listed", "five")}
+
+	full := filepath.Join(root, "full.ckpt")
+	want, _ := scan(t, dir, listed, full, nil)
+	if len(want) != 3 {
+		t.Fatalf("uninterrupted scan found %d groups, want 3: %q", len(want), want)
+	}
+	data, err := os.ReadFile(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	lines = lines[:len(lines)-1] // The file ends with a newline
+
+	// Interrupt the scan after each record, and in the middle of the next one
+	for n := 1; n <= len(lines); n++ {
+		cut := bytes.Join(lines[:n], nil)
+		if n < len(lines) {
+			cut = append(cut, lines[n][:len(lines[n])/2]...)
+		}
+		path := filepath.Join(root, "cut.ckpt")
+		if err := os.WriteFile(path, cut, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cp, err := Load(path)
+		if err != nil {
+			t.Fatalf("after %d lines: %v", n, err)
+		}
+		got, hashed := scan(t, dir, listed, path, cp)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("after %d lines: resumed scan found %q, want %q", n, got, want)
+		}
+		if n == len(lines) && hashed != 0 {
+			t.Errorf("resuming a finished scan hashed %d files again", hashed)
+		}
+
+		// The checkpoint of the resumed scan resumes to the same results
+		cp, err = Load(path)
+		if err != nil {
+			t.Fatalf("after %d lines, resumed checkpoint: %v", n, err)
+		}
+		got, hashed = scan(t, dir, listed, path, cp)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("after %d lines, resumed twice: found %q, want %q", n, got, want)
+		}
+		if hashed != 0 {
+			t.Errorf("after %d lines, resumed twice: hashed %d files again", n, hashed)
+		}
+	}
+}
+
+func TestLoadRejectsInvalidRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.ckpt")
+	data := `{"version":2,"params":{},"roots":["dir"]}` + "\n" + "not json\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Load returned %v, want an error for line 2", err)
+	}
+}
@@ -0,0 +1,205 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ExitUsage is the exit code for invalid command lines
+const ExitUsage = 1
+
+// Command is a command of an App
+type Command struct {
+	Name        string   // Name used on the command line
+	Group       string   // Heading the command is listed under in the usage page
+	Usage       []string // Synopses of the command line, without the program name
+	Summary     string   // One line description
+	Description string   // Longer description shown in the help of the command (may be empty)
+	Examples    string   // Examples shown in the help of the command (may be empty)
+
+	// Flags defines the flags of the command (may be nil)
+	Flags func(fs *FlagSet)
+
+	// Run runs the command with its positional arguments and returns the
+	// exit code. The flags are parsed into the variables they were defined
+	// with before Run is called.
+	Run func(fs *FlagSet, args []string) int
+}
+
+// App is a program made of commands
+type App struct {
+	Name     string     // Program name
+	Summary  string     // One line description
+	Version  string     // Version shown by --version
+	Commands []*Command // Commands in the order they are listed
+	Default  string     // Command run when the arguments start with a flag (may be empty)
+	Footer   string     // Text shown at the end of the usage page (may be empty)
+	Stdout   io.Writer  // Writer for help output (os.Stdout if nil)
+	Stderr   io.Writer  // Writer for errors (os.Stderr if nil)
+
+	// Configure is called after the command line of a command is parsed and
+	// before the command runs, to set the flags that were not given on the
+	// command line from other sources (may be nil)
+	Configure func(cmd *Command, fs *FlagSet) error
+}
+
+// Run runs the command named by the first argument and returns its exit code
+func (a *App) Run(args []string) int {
+	if len(args) == 0 {
+		a.PrintUsage(a.stderr())
+		return ExitUsage
+	}
+
+	name := args[0]
+	switch name {
+	case "help", "-h", "--help":
+		if name == "help" && len(args) > 1 {
+			cmd := a.Lookup(args[1])
+			if cmd == nil {
+				return a.usageError(fmt.Errorf("unknown command: %s", args[1]), nil)
+			}
+			a.PrintCommandUsage(a.stdout(), cmd)
+			return 0
+		}
+		a.PrintUsage(a.stdout())
+		return 0
+
+	case "-v", "--version":
+		fmt.Fprintf(a.stdout(), "%s version %s\n", a.Name, a.Version)
+		return 0
+	}
+
+	cmd := a.Lookup(name)
+	if cmd == nil && strings.HasPrefix(name, "-") && a.Default != "" {
+		cmd, args = a.Lookup(a.Default), append([]string{a.Default}, args...)
+	}
+	if cmd == nil {
+		return a.usageError(fmt.Errorf("unknown command: %s", name), nil)
+	}
+
+	var help bool
+	fs := a.flagSet(cmd, &help)
+	positional, err := fs.Parse(args[1:])
+	if err != nil {
+		return a.usageError(err, cmd)
+	}
+	if help {
+		a.PrintCommandUsage(a.stdout(), cmd)
+		return 0
+	}
+	if a.Configure != nil {
+		if err := a.Configure(cmd, fs); err != nil {
+			fmt.Fprintf(a.stderr(), "Error: %v\n", err)
+			return ExitUsage
+		}
+	}
+	return cmd.Run(fs, positional)
+}
+
+// Lookup returns the command with a name, or nil
+func (a *App) Lookup(name string) *Command {
+	for _, cmd := range a.Commands {
+		if cmd.Name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// flagSet returns a new flag set with the flags of a command and the help flag
+func (a *App) flagSet(cmd *Command, help *bool) *FlagSet {
+	fs := NewFlagSet()
+	if cmd.Flags != nil {
+		cmd.Flags(fs)
+	}
+	fs.Group("Global flags")
+	fs.BoolVar(help, "help", "h", "Show help for "+cmd.Name)
+	return fs
+}
+
+// usageError prints an error with a pointer to the help of a command (or of
+// the app if cmd is nil), and returns the exit code for invalid command lines
+func (a *App) usageError(err error, cmd *Command) int {
+	fmt.Fprintf(a.stderr(), "Error: %v\n", err)
+	if cmd != nil {
+		fmt.Fprintf(a.stderr(), "Run '%s help %s' for usage.\n", a.Name, cmd.Name)
+	} else {
+		fmt.Fprintf(a.stderr(), "Run '%s help' for usage.\n", a.Name)
+	}
+	return ExitUsage
+}
+
+// PrintUsage writes the usage page of the app: its commands grouped under
+// their headings
+func (a *App) PrintUsage(w io.Writer) {
+	fmt.Fprintf(w, "%s\n\n", a.Summary)
+	fmt.Fprintf(w, "Usage:\n  %s COMMAND [flags] [arguments]\n", a.Name)
+
+	var groups []string
+	byGroup := make(map[string][]*Command)
+	width := 0
+	for _, cmd := range a.Commands {
+		if _, ok := byGroup[cmd.Group]; !ok {
+			groups = append(groups, cmd.Group)
+		}
+		byGroup[cmd.Group] = append(byGroup[cmd.Group], cmd)
+		if len(cmd.Name) > width {
+			width = len(cmd.Name)
+		}
+	}
+
+	for _, group := range groups {
+		fmt.Fprintf(w, "\n%s:\n", group)
+		for _, cmd := range byGroup[group] {
+			fmt.Fprintf(w, "  %-*s  %s\n", width, cmd.Name, cmd.Summary)
+		}
+	}
+
+	fmt.Fprintf(w, "\nGlobal flags:\n")
+	fmt.Fprintf(w, "  -h, --help     Show help\n")
+	fmt.Fprintf(w, "  -v, --version  Show the version\n")
+
+	if a.Default != "" {
+		fmt.Fprintf(w, "\nArguments starting with a flag run '%s %s'.\n", a.Name, a.Default)
+	}
+	fmt.Fprintf(w, "Run '%s help COMMAND' for the flags of a command.\n", a.Name)
+	if a.Footer != "" {
+		fmt.Fprintf(w, "\n%s\n", strings.TrimRight(a.Footer, "\n"))
+	}
+}
+
+// PrintCommandUsage writes the help of a command, with its flags grouped
+// under their headings
+func (a *App) PrintCommandUsage(w io.Writer, cmd *Command) {
+	fmt.Fprintf(w, "%s\n\nUsage:\n", cmd.Summary)
+	for _, usage := range cmd.Usage {
+		fmt.Fprintf(w, "  %s %s\n", a.Name, usage)
+	}
+	if cmd.Description != "" {
+		fmt.Fprintf(w, "\n%s\n", strings.TrimRight(cmd.Description, "\n"))
+	}
+
+	var help bool
+	fmt.Fprintln(w)
+	a.flagSet(cmd, &help).PrintDefaults(w)
+
+	if cmd.Examples != "" {
+		fmt.Fprintf(w, "\nExamples:\n%s\n", strings.TrimRight(cmd.Examples, "\n"))
+	}
+}
+
+func (a *App) stdout() io.Writer {
+	if a.Stdout != nil {
+		return a.Stdout
+	}
+	return os.Stdout
+}
+
+func (a *App) stderr() io.Writer {
+	if a.Stderr != nil {
+		return a.Stderr
+	}
+	return os.Stderr
+}
@@ -0,0 +1,463 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/glob"
+	"github.com/tendant/dupe-cli/internal/units"
+)
+
+// Value is the value of a flag
+type Value interface {
+	String() string   // Current value, shown as the default in usage
+	Set(string) error // Parses and sets the value
+	Type() string     // Name of the value in usage, empty for boolean flags
+}
+
+// Flag is a command line flag
+type Flag struct {
+	Name    string // Long name, used as --name
+	Short   string // Single letter name, used as -s (may be empty)
+	Usage   string // Description in the usage page
+	Group   string // Heading the flag is listed under
+	Default string // Default value shown in usage (empty to show none)
+	Value   Value
+}
+
+// FlagSet is a set of flags of a command
+type FlagSet struct {
+	flags   []*Flag
+	byName  map[string]*Flag
+	changed map[string]bool
+	group   string
+}
+
+// NewFlagSet creates an empty flag set
+func NewFlagSet() *FlagSet {
+	return &FlagSet{
+		byName:  make(map[string]*Flag),
+		changed: make(map[string]bool),
+		group:   "Flags",
+	}
+}
+
+// Group sets the heading of the flags defined next
+func (f *FlagSet) Group(name string) {
+	f.group = name
+}
+
+// Var defines a flag with a custom value
+func (f *FlagSet) Var(value Value, name, short, usage string) *Flag {
+	if _, ok := f.byName[name]; ok {
+		panic("cli: flag redefined: " + name)
+	}
+	if _, ok := f.byName[short]; ok && short != "" {
+		panic("cli: flag redefined: " + short)
+	}
+
+	flag := &Flag{
+		Name:    name,
+		Short:   short,
+		Usage:   usage,
+		Group:   f.group,
+		Default: value.String(),
+		Value:   value,
+	}
+	if isZero(flag.Default) {
+		flag.Default = ""
+	}
+
+	f.flags = append(f.flags, flag)
+	f.byName[name] = flag
+	if short != "" {
+		f.byName[short] = flag
+	}
+	return flag
+}
+
+// isZero reports whether a default value is not worth showing
+func isZero(s string) bool {
+	switch s {
+	case "", "0", "false", "0s", "[]":
+		return true
+	default:
+		return false
+	}
+}
+
+// StringVar defines a string flag, with the current value of p as default
+func (f *FlagSet) StringVar(p *string, name, short, usage string) {
+	f.Var((*stringValue)(p), name, short, usage)
+}
+
+// BoolVar defines a boolean flag
+func (f *FlagSet) BoolVar(p *bool, name, short, usage string) {
+	f.Var((*boolValue)(p), name, short, usage)
+}
+
+// IntVar defines an integer flag, with the current value of p as default
+func (f *FlagSet) IntVar(p *int, name, short, usage string) {
+	f.Var((*intValue)(p), name, short, usage)
+}
+
+// DurationVar defines a duration flag (e.g. 30s, 5m), with the current
+// value of p as default
+func (f *FlagSet) DurationVar(p *time.Duration, name, short, usage string) {
+	f.Var((*durationValue)(p), name, short, usage)
+}
+
+// SizeVar defines a size flag (e.g. 100M, 1.5G), with the current value of
+// p as default
+func (f *FlagSet) SizeVar(p *int64, name, short, usage string) {
+	f.Var((*sizeValue)(p), name, short, usage)
+}
+
+// TimeVar defines a time flag, given as a date, a date and time or an age
+// before now (see units.ParseTime). The time is stored in RFC 3339, so that
+// an age is resolved once, when the flag is set.
+func (f *FlagSet) TimeVar(p *string, name, short, usage string) {
+	f.Var((*timeValue)(p), name, short, usage)
+}
+
+// ListVar defines a list flag. Values are comma-separated and the flag can
+// be repeated, adding to the list.
+func (f *FlagSet) ListVar(p *[]string, name, short, usage string) {
+	f.Var(&listValue{p: p}, name, short, usage)
+}
+
+// PatternsVar defines a list flag of glob patterns. Like a list flag, it
+// takes comma-separated values and can be repeated, but commas in braces
+// and character classes don't separate patterns, and patterns are checked.
+func (f *FlagSet) PatternsVar(p *[]string, name, short, usage string) {
+	f.Var(&patternsValue{p: p}, name, short, usage)
+}
+
+// EnumVar defines a string flag that takes one of choices, compared without
+// case. The choices are listed in usage.
+func (f *FlagSet) EnumVar(p *string, choices []string, name, short, usage string) {
+	usage = fmt.Sprintf("%s (%s)", usage, strings.Join(choices, ", "))
+	f.Var(&enumValue{p: p, choices: choices}, name, short, usage)
+}
+
+// Lookup returns the flag with a long or short name, or nil
+func (f *FlagSet) Lookup(name string) *Flag {
+	return f.byName[name]
+}
+
+// Changed reports whether a flag was set on the command line
+func (f *FlagSet) Changed(name string) bool {
+	flag := f.byName[name]
+	return flag != nil && f.changed[flag.Name]
+}
+
+// Flags returns the flags in the order they were defined
+func (f *FlagSet) Flags() []*Flag {
+	return f.flags
+}
+
+// Parse parses flags from args and returns the positional arguments. Flags
+// and positional arguments can be mixed; all arguments after "--" are
+// positional. Flag values are given as --name=value, --name value,
+// -s value or -svalue, and boolean short flags can be combined (-rn).
+func (f *FlagSet) Parse(args []string) ([]string, error) {
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case arg == "--":
+			return append(positional, args[i+1:]...), nil
+
+		case strings.HasPrefix(arg, "--"):
+			name, value := arg[2:], ""
+			hasValue := false
+			if eq := strings.IndexByte(name, '='); eq >= 0 {
+				name, value, hasValue = name[:eq], name[eq+1:], true
+			}
+
+			flag := f.byName[name]
+			if flag == nil || len(name) < 2 {
+				return nil, fmt.Errorf("unknown flag: --%s", name)
+			}
+			if !hasValue && flag.Value.Type() != "" {
+				if i+1 >= len(args) {
+					return nil, fmt.Errorf("missing value for --%s", name)
+				}
+				i++
+				value, hasValue = args[i], true
+			}
+			if err := f.set(flag, "--"+name, value, hasValue); err != nil {
+				return nil, err
+			}
+
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			// One or more short flags, the last of which may take a value
+			shorts := arg[1:]
+			for len(shorts) > 0 {
+				name := shorts[:1]
+				shorts = shorts[1:]
+
+				flag := f.byName[name]
+				if flag == nil || flag.Short != name {
+					return nil, fmt.Errorf("unknown flag: -%s", name)
+				}
+				if flag.Value.Type() == "" {
+					if err := f.set(flag, "-"+name, "", false); err != nil {
+						return nil, err
+					}
+					continue
+				}
+
+				value := strings.TrimPrefix(shorts, "=")
+				if shorts == "" {
+					if i+1 >= len(args) {
+						return nil, fmt.Errorf("missing value for -%s", name)
+					}
+					i++
+					value = args[i]
+				}
+				if err := f.set(flag, "-"+name, value, true); err != nil {
+					return nil, err
+				}
+				shorts = ""
+			}
+
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	return positional, nil
+}
+
+// set sets the value of a flag given as name on the command line
+func (f *FlagSet) set(flag *Flag, name, value string, hasValue bool) error {
+	if !hasValue {
+		value = "true"
+	}
+	if err := flag.Value.Set(value); err != nil {
+		return fmt.Errorf("invalid value %q for %s: %v", value, name, err)
+	}
+	f.changed[flag.Name] = true
+	return nil
+}
+
+// Set sets the value of a flag by its long name, as if it was given on the
+// command line
+func (f *FlagSet) Set(name, value string) error {
+	flag := f.byName[name]
+	if flag == nil {
+		return fmt.Errorf("unknown flag: --%s", name)
+	}
+	return f.set(flag, "--"+name, value, true)
+}
+
+// SetDefault sets the value of a flag by its long name from a source other
+// than the command line, such as a config file. Changed keeps reporting
+// whether the flag was set on the command line.
+func (f *FlagSet) SetDefault(name, value string) error {
+	flag := f.byName[name]
+	if flag == nil || flag.Name != name {
+		return fmt.Errorf("unknown flag: %s", name)
+	}
+	if err := flag.Value.Set(value); err != nil {
+		return fmt.Errorf("invalid value %q for %s: %v", value, name, err)
+	}
+	return nil
+}
+
+// PrintDefaults writes the flags, grouped under their headings in the order
+// the groups were first used
+func (f *FlagSet) PrintDefaults(w io.Writer) {
+	var groups []string
+	byGroup := make(map[string][]*Flag)
+	for _, flag := range f.flags {
+		if _, ok := byGroup[flag.Group]; !ok {
+			groups = append(groups, flag.Group)
+		}
+		byGroup[flag.Group] = append(byGroup[flag.Group], flag)
+	}
+
+	// Align the descriptions of all groups
+	width := 0
+	for _, flag := range f.flags {
+		if n := len(flagSynopsis(flag)); n > width {
+			width = n
+		}
+	}
+
+	for i, group := range groups {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s:\n", group)
+		for _, flag := range byGroup[group] {
+			usage := flag.Usage
+			if flag.Default != "" {
+				usage += fmt.Sprintf(" (default %s)", quoteDefault(flag))
+			}
+			fmt.Fprintf(w, "  %-*s  %s\n", width, flagSynopsis(flag), usage)
+		}
+	}
+}
+
+// flagSynopsis returns the names and value type of a flag, as in
+// "-o, --output string"
+func flagSynopsis(flag *Flag) string {
+	s := "    --" + flag.Name
+	if flag.Short != "" {
+		s = "-" + flag.Short + ", --" + flag.Name
+	}
+	if typ := flag.Value.Type(); typ != "" {
+		s += " " + typ
+	}
+	return s
+}
+
+// quoteDefault returns the default value of a flag as shown in usage
+func quoteDefault(flag *Flag) string {
+	if flag.Value.Type() == "string" {
+		return strconv.Quote(flag.Default)
+	}
+	return flag.Default
+}
+
+// stringValue is the value of a string flag
+type stringValue string
+
+func (v *stringValue) String() string     { return string(*v) }
+func (v *stringValue) Set(s string) error { *v = stringValue(s); return nil }
+func (v *stringValue) Type() string       { return "string" }
+
+// boolValue is the value of a boolean flag
+type boolValue bool
+
+func (v *boolValue) String() string { return strconv.FormatBool(bool(*v)) }
+func (v *boolValue) Type() string   { return "" }
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("not a boolean")
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+// intValue is the value of an integer flag
+type intValue int
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+func (v *intValue) Type() string   { return "int" }
+
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("not an integer")
+	}
+	*v = intValue(n)
+	return nil
+}
+
+// durationValue is the value of a duration flag
+type durationValue time.Duration
+
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+func (v *durationValue) Type() string   { return "duration" }
+
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("not a duration")
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+// sizeValue is the value of a size flag
+type sizeValue int64
+
+func (v *sizeValue) String() string { return strconv.FormatInt(int64(*v), 10) }
+func (v *sizeValue) Type() string   { return "size" }
+
+func (v *sizeValue) Set(s string) error {
+	size, err := units.ParseSize(s)
+	if err != nil {
+		return err
+	}
+	*v = sizeValue(size)
+	return nil
+}
+
+// timeValue is the value of a time flag
+type timeValue string
+
+func (v *timeValue) String() string { return string(*v) }
+func (v *timeValue) Type() string   { return "time" }
+
+func (v *timeValue) Set(s string) error {
+	t, err := units.ParseTime(s, time.Now())
+	if err != nil {
+		return err
+	}
+	*v = timeValue(t.Format(time.RFC3339))
+	return nil
+}
+
+// listValue is the value of a list flag
+type listValue struct {
+	p *[]string
+}
+
+func (v *listValue) String() string { return strings.Join(*v.p, ",") }
+func (v *listValue) Type() string   { return "list" }
+
+func (v *listValue) Set(s string) error {
+	for _, elem := range strings.Split(s, ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			*v.p = append(*v.p, elem)
+		}
+	}
+	return nil
+}
+
+// patternsValue is the value of a glob patterns flag
+type patternsValue struct {
+	p *[]string
+}
+
+func (v *patternsValue) String() string { return strings.Join(*v.p, ",") }
+func (v *patternsValue) Type() string   { return "patterns" }
+
+func (v *patternsValue) Set(s string) error {
+	patterns := glob.Split(s)
+	if _, err := glob.Compile(patterns...); err != nil {
+		return err
+	}
+	*v.p = append(*v.p, patterns...)
+	return nil
+}
+
+// enumValue is the value of a flag that takes one of a set of strings
+type enumValue struct {
+	p       *string
+	choices []string
+}
+
+func (v *enumValue) String() string { return *v.p }
+func (v *enumValue) Type() string   { return "string" }
+
+func (v *enumValue) Set(s string) error {
+	for _, choice := range v.choices {
+		if strings.EqualFold(s, choice) {
+			*v.p = choice
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of %s", strings.Join(v.choices, ", "))
+}
@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testFlags are the values of the flags of newTestFlagSet
+type testFlags struct {
+	Recursive bool
+	DryRun    bool
+	Output    string
+	Readers   int
+	MinSize   int64
+	Dirs      []string
+	Exclude   []string
+}
+
+// newTestFlagSet creates a flag set with a flag of each kind
+func newTestFlagSet(v *testFlags) *FlagSet {
+	f := NewFlagSet()
+	f.BoolVar(&v.Recursive, "recursive", "r", "Recursive")
+	f.BoolVar(&v.DryRun, "dry-run", "n", "Dry run")
+	f.StringVar(&v.Output, "output", "o", "Output format")
+	f.IntVar(&v.Readers, "readers", "", "Readers")
+	f.SizeVar(&v.MinSize, "min-size", "", "Minimum size")
+	f.ListVar(&v.Dirs, "directories", "d", "Directories")
+	f.PatternsVar(&v.Exclude, "exclude", "e", "Exclude patterns")
+	return f
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		want       testFlags
+		positional []string
+	}{
+		{
+			name: "long flags with separate values",
+			args: []string{"--output", "json", "--readers", "4"},
+			want: testFlags{Output: "json", Readers: 4},
+		},
+		{
+			name: "long flags with = values",
+			args: []string{"--output=json", "--readers=4", "--min-size=1K"},
+			want: testFlags{Output: "json", Readers: 4, MinSize: 1024},
+		},
+		{
+			name: "empty = value",
+			args: []string{"--output="},
+			want: testFlags{Output: ""},
+		},
+		{
+			name: "= in a value",
+			args: []string{"--output=a=b"},
+			want: testFlags{Output: "a=b"},
+		},
+		{
+			name: "boolean with = value",
+			args: []string{"--recursive=true", "--dry-run=false"},
+			want: testFlags{Recursive: true},
+		},
+		{
+			name: "grouped short flags",
+			args: []string{"-rn"},
+			want: testFlags{Recursive: true, DryRun: true},
+		},
+		{
+			name: "grouped short flags ending with one taking the next argument",
+			args: []string{"-rno", "json"},
+			want: testFlags{Recursive: true, DryRun: true, Output: "json"},
+		},
+		{
+			name: "grouped short flags ending with an attached value",
+			args: []string{"-rojson"},
+			want: testFlags{Recursive: true, Output: "json"},
+		},
+		{
+			name: "short flag with = value",
+			args: []string{"-o=json"},
+			want: testFlags{Output: "json"},
+		},
+		{
+			name: "repeated and comma-separated lists",
+			args: []string{"-d", "a,b", "--directories=c", "-e", "*.{jpg,png},*.tmp"},
+			want: testFlags{Dirs: []string{"a", "b", "c"}, Exclude: []string{"*.{jpg,png}", "*.tmp"}},
+		},
+		{
+			name:       "flags mixed with positional arguments",
+			args:       []string{"dir1", "-r", "dir2", "--output", "csv", "dir3"},
+			want:       testFlags{Recursive: true, Output: "csv"},
+			positional: []string{"dir1", "dir2", "dir3"},
+		},
+		{
+			name:       "arguments after -- are positional",
+			args:       []string{"-r", "--", "-n", "--output=json"},
+			want:       testFlags{Recursive: true},
+			positional: []string{"-n", "--output=json"},
+		},
+		{
+			name:       "single dash is positional",
+			args:       []string{"-"},
+			positional: []string{"-"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got testFlags
+			positional, err := newTestFlagSet(&got).Parse(tt.args)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.args, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) set %+v, want %+v", tt.args, got, tt.want)
+			}
+			if !reflect.DeepEqual(positional, tt.positional) {
+				t.Errorf("Parse(%q) = %q, want %q", tt.args, positional, tt.positional)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"unknown long flag", []string{"--unknown"}},
+		{"unknown short flag", []string{"-x"}},
+		{"unknown short flag in a group", []string{"-rx"}},
+		{"short name given as a long flag", []string{"--r"}},
+		{"missing value", []string{"--output"}},
+		{"missing value of a short flag", []string{"-rno"}},
+		{"invalid integer", []string{"--readers=many"}},
+		{"invalid boolean", []string{"--recursive=maybe"}},
+		{"invalid size", []string{"--min-size", "big"}},
+		{"invalid pattern", []string{"--exclude=[abc"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v testFlags
+			if _, err := newTestFlagSet(&v).Parse(tt.args); err == nil {
+				t.Errorf("Parse(%q) succeeded, want an error", tt.args)
+			}
+		})
+	}
+}
+
+func TestChanged(t *testing.T) {
+	var v testFlags
+	f := newTestFlagSet(&v)
+	if err := f.SetDefault("output", "json"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Parse([]string{"-r", "--readers=2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]bool{"recursive": true, "readers": true, "output": false, "dry-run": false} {
+		if got := f.Changed(name); got != want {
+			t.Errorf("Changed(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if v.Output != "json" {
+		t.Errorf("output = %q, want the default json", v.Output)
+	}
+}
@@ -0,0 +1,164 @@
+package compat
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+)
+
+// WriteFdupes writes duplicate groups like fdupes: the paths of each group
+// on their own lines, the reference first, with a blank line after each group
+func WriteFdupes(w io.Writer, groups []*engine.DuplicateGroup) error {
+	bw := bufio.NewWriter(w)
+	for _, group := range groups {
+		fmt.Fprintln(bw, group.Reference.Path)
+		for _, dupe := range group.Duplicates {
+			fmt.Fprintln(bw, dupe.Path)
+		}
+		fmt.Fprintln(bw)
+	}
+	return bw.Flush()
+}
+
+// jdupesOutput is the root of jdupes' JSON output (jdupes -j)
+type jdupesOutput struct {
+	Version        string           `json:"jdupesVersion"`
+	VersionDate    string           `json:"jdupesVersionDate"`
+	CommandLine    string           `json:"commandLine"`
+	ExtensionFlags string           `json:"extensionFlags"`
+	MatchSets      []jdupesMatchSet `json:"matchSets"`
+	Errors         []jdupesError    `json:"errors,omitempty"` // Not written by jdupes
+}
+
+// jdupesMatchSet is a set of duplicate files in jdupes' JSON output
+type jdupesMatchSet struct {
+	FileSize int64        `json:"fileSize"`
+	FileList []jdupesFile `json:"fileList"`
+}
+
+// jdupesFile is a file of a match set in jdupes' JSON output
+type jdupesFile struct {
+	FilePath string `json:"filePath"`
+}
+
+// jdupesError is a path that could not be read, in an errors list that
+// dupe-cli adds to jdupes' JSON output
+type jdupesError struct {
+	Path     string `json:"path"`
+	Category string `json:"category"`
+	Message  string `json:"message"`
+}
+
+// WriteJdupesJSON writes duplicate groups in jdupes' JSON format, with the
+// paths that could not be read in an additional errors list. The version
+// fields name dupe-cli, as the output wasn't made by jdupes.
+func WriteJdupesJSON(w io.Writer, groups []*engine.DuplicateGroup, errs []*fs.PathError, version, commandLine string) error {
+	out := jdupesOutput{
+		Version:     "dupe-cli " + version,
+		CommandLine: commandLine,
+		MatchSets:   make([]jdupesMatchSet, 0, len(groups)),
+	}
+
+	for _, group := range groups {
+		set := jdupesMatchSet{
+			FileSize: group.Reference.Size,
+			FileList: make([]jdupesFile, 0, len(group.Duplicates)+1),
+		}
+		set.FileList = append(set.FileList, jdupesFile{FilePath: group.Reference.Path})
+		for _, dupe := range group.Duplicates {
+			set.FileList = append(set.FileList, jdupesFile{FilePath: dupe.Path})
+		}
+		out.MatchSets = append(out.MatchSets, set)
+	}
+
+	for _, pathErr := range errs {
+		out.Errors = append(out.Errors, jdupesError{
+			Path:     pathErr.Path,
+			Category: pathErr.Category.String(),
+			Message:  pathErr.Err.Error(),
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// Duplicate types of rdfind's results file
+const (
+	rdfindFirstOccurrence = "DUPTYPE_FIRST_OCCURRENCE"
+	rdfindWithinSameTree  = "DUPTYPE_WITHIN_SAME_TREE"
+	rdfindOutsideTree     = "DUPTYPE_OUTSIDE_TREE"
+)
+
+// WriteRdfind writes duplicate groups like rdfind's results.txt. Roots are
+// the scanned directories in command line order, which give the depth and
+// priority of files and tell whether a duplicate is in the same tree as
+// its reference. Each file has its own id, numbered in output order, and
+// the files of a group share its number in the group column. The paths
+// that could not be read are listed in comment lines at the end.
+func WriteRdfind(w io.Writer, groups []*engine.DuplicateGroup, errs []*fs.PathError, roots []string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# Automatically generated")
+	fmt.Fprintln(bw, "# duptype id group depth size device inode priority name")
+
+	id := 0
+	for i, group := range groups {
+		refRoot := rootIndex(group.Reference.Path, roots)
+		id++
+		writeRdfindLine(bw, rdfindFirstOccurrence, id, i+1, group.Reference, roots, refRoot)
+
+		for _, dupe := range group.Duplicates {
+			root := rootIndex(dupe.Path, roots)
+			duptype := rdfindOutsideTree
+			if root == refRoot {
+				duptype = rdfindWithinSameTree
+			}
+			id++
+			writeRdfindLine(bw, duptype, id, i+1, dupe, roots, root)
+		}
+	}
+
+	for _, pathErr := range errs {
+		fmt.Fprintf(bw, "# error %s %s: %s\n", pathErr.Category, oneLine(pathErr.Path), oneLine(pathErr.Err.Error()))
+	}
+
+	fmt.Fprintln(bw, "# end of file")
+	return bw.Flush()
+}
+
+// writeRdfindLine writes the line of a file in rdfind's results file
+func writeRdfindLine(w io.Writer, duptype string, id, group int, file *fs.File, roots []string, root int) {
+	depth := 0
+	if root >= 0 {
+		rel, err := filepath.Rel(filepath.Clean(roots[root]), filepath.Clean(file.Path))
+		if err == nil {
+			depth = strings.Count(filepath.ToSlash(rel), "/") + 1
+		}
+	}
+	fmt.Fprintf(w, "%s %d %d %d %d %d %d %d %s\n",
+		duptype, id, group, depth, file.Size, file.Dev, file.Inode, root+1, file.Path)
+}
+
+// rootIndex returns the index of the first root a path is in, or -1
+func rootIndex(path string, roots []string) int {
+	for i, root := range roots {
+		root = filepath.Clean(root)
+		if fs.IsWithin(path, root) {
+			return i
+		}
+	}
+	return -1
+}
+
+// oneLine replaces the line breaks of s with spaces, so that it fits in a
+// comment line
+func oneLine(s string) string {
+	return strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(s)
+}
@@ -0,0 +1,108 @@
+package compat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+)
+
+// testGroups returns two groups, the second with a duplicate in another
+// scanned directory
+func testGroups() []*engine.DuplicateGroup {
+	return []*engine.DuplicateGroup{
+		{
+			Reference:  &fs.File{Path: "/a/x", Size: 3, Dev: 1, Inode: 10},
+			Duplicates: []*fs.File{{Path: "/a/sub/x", Size: 3, Dev: 1, Inode: 11}},
+		},
+		{
+			Reference:  &fs.File{Path: "/a/y", Size: 5, Dev: 1, Inode: 12},
+			Duplicates: []*fs.File{{Path: "/b/y", Size: 5, Dev: 2, Inode: 20}, {Path: "/c/y", Size: 5}},
+		},
+	}
+}
+
+// testErrors returns a path error with a line break in its message
+func testErrors() []*fs.PathError {
+	return []*fs.PathError{{Path: "/a/z", Category: fs.ErrorPermission, Err: errors.New("denied\nby policy")}}
+}
+
+func TestWriteFdupes(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteFdupes(&out, testGroups()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "/a/x\n/a/sub/x\n\n/a/y\n/b/y\n/c/y\n\n"
+	if got := out.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteJdupesJSON(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteJdupesJSON(&out, testGroups(), testErrors(), "1.0", "dupe-cli -o jdupes /a /b"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got jdupesOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := jdupesOutput{
+		Version:     "dupe-cli 1.0",
+		CommandLine: "dupe-cli -o jdupes /a /b",
+		MatchSets: []jdupesMatchSet{
+			{FileSize: 3, FileList: []jdupesFile{{"/a/x"}, {"/a/sub/x"}}},
+			{FileSize: 5, FileList: []jdupesFile{{"/a/y"}, {"/b/y"}, {"/c/y"}}},
+		},
+		Errors: []jdupesError{{Path: "/a/z", Category: "permission", Message: "denied\nby policy"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestWriteJdupesJSONWithoutGroups(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteJdupesJSON(&out, nil, nil, "1.0", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Like jdupes, an empty matchSets list rather than null, and no errors
+	var got map[string]any
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if sets, ok := got["matchSets"].([]any); !ok || len(sets) != 0 {
+		t.Errorf("matchSets = %v, want []", got["matchSets"])
+	}
+	if _, ok := got["errors"]; ok {
+		t.Error("output has errors")
+	}
+}
+
+func TestWriteRdfind(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteRdfind(&out, testGroups(), testErrors(), []string{"/a/", "/b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# Automatically generated
+# duptype id group depth size device inode priority name
+DUPTYPE_FIRST_OCCURRENCE 1 1 1 3 1 10 1 /a/x
+DUPTYPE_WITHIN_SAME_TREE 2 1 2 3 1 11 1 /a/sub/x
+DUPTYPE_FIRST_OCCURRENCE 3 2 1 5 1 12 1 /a/y
+DUPTYPE_OUTSIDE_TREE 4 2 1 5 2 20 2 /b/y
+DUPTYPE_OUTSIDE_TREE 5 2 0 5 0 0 0 /c/y
+# error permission /a/z: denied by policy
+# end of file
+`
+	if got := out.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
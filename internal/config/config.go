@@ -0,0 +1,158 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LocalFile is the name of the config file read from the working directory
+const LocalFile = ".dupe-cli"
+
+// EnvPrefix is the prefix of the environment variables of settings
+const EnvPrefix = "DUPE_CLI_"
+
+// Setting is the value of a setting and where it comes from
+type Setting struct {
+	Value  string
+	Source string // File and line, or environment variable, the value comes from
+}
+
+// Config is the settings of config files: default values of flags, by
+// their long names, and named profiles of settings
+type Config struct {
+	Files    []string // Config files that were read, in order
+	Defaults map[string]Setting
+	Profiles map[string]map[string]Setting
+}
+
+// DefaultPaths returns the config files read by default, in increasing
+// order of precedence: the user's config file, then the one in the
+// working directory
+func DefaultPaths() []string {
+	var paths []string
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "dupe-cli", "config"))
+	}
+	return append(paths, LocalFile)
+}
+
+// Load reads config files, the settings of later files overriding those of
+// earlier ones. Missing files are skipped.
+func Load(paths ...string) (*Config, error) {
+	c := &Config{
+		Defaults: make(map[string]Setting),
+		Profiles: make(map[string]map[string]Setting),
+	}
+	for _, path := range paths {
+		if err := c.load(path); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		c.Files = append(c.Files, path)
+	}
+	return c, nil
+}
+
+// load reads a config file. Settings before the first section are default
+// flag values, and settings in a [profile NAME] section belong to that
+// profile. Lines starting with # or ; are comments.
+func (c *Config) load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	section, profile := c.Defaults, ""
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		source := fmt.Sprintf("%s:%d", path, n)
+
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+			continue
+
+		case line[0] == '[':
+			if !strings.HasSuffix(line, "]") {
+				return fmt.Errorf("%s: invalid section header: %s", source, line)
+			}
+			fields := strings.Fields(line[1 : len(line)-1])
+			if len(fields) != 2 || fields[0] != "profile" {
+				return fmt.Errorf("%s: invalid section %s, expected [profile NAME]", source, line)
+			}
+			profile = fields[1]
+			if c.Profiles[profile] == nil {
+				c.Profiles[profile] = make(map[string]Setting)
+			}
+			section = c.Profiles[profile]
+
+		default:
+			eq := strings.IndexByte(line, '=')
+			if eq < 0 {
+				return fmt.Errorf("%s: expected key = value", source)
+			}
+			key := strings.TrimSpace(line[:eq])
+			if key == "" {
+				return fmt.Errorf("%s: missing key", source)
+			}
+			if key == "profile" && profile != "" {
+				return fmt.Errorf("%s: profile %s cannot select another profile", source, profile)
+			}
+			section[key] = Setting{Value: parseValue(strings.TrimSpace(line[eq+1:])), Source: source}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return nil
+}
+
+// parseValue removes the quotes around a value and expands a leading ~/ to
+// the home directory
+func parseValue(value string) string {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+	}
+	if strings.HasPrefix(value, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			value = filepath.Join(home, value[2:])
+		}
+	}
+	return value
+}
+
+// Profile returns the settings of a profile
+func (c *Config) Profile(name string) (map[string]Setting, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		if len(c.Profiles) == 0 {
+			return nil, fmt.Errorf("unknown profile %q: no profiles are defined", name)
+		}
+		return nil, fmt.Errorf("unknown profile %q (profiles: %s)", name, strings.Join(c.ProfileNames(), ", "))
+	}
+	return profile, nil
+}
+
+// ProfileNames returns the names of the profiles, sorted
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EnvName returns the environment variable of a setting, such as
+// DUPE_CLI_SCAN_TYPE for scan-type
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
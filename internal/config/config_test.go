@@ -0,0 +1,124 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes a config file in dir and returns its path
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// values returns the values of settings, without their sources
+func values(settings map[string]Setting) map[string]string {
+	m := make(map[string]string)
+	for key, setting := range settings {
+		m[key] = setting.Value
+	}
+	return m
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	user := writeConfig(t, dir, "user", `# user settings
+recursive = true
+progress = log
+keep = oldest
+
+[profile photos]
+scan-type = content
+exclude = "*.xmp,Thumbs.db"
+`)
+	local := writeConfig(t, dir, "local", `; local settings override the user's
+keep = newest
+
+[profile photos]
+reference = ~/backup
+
+[profile music]
+scan-type=tag
+`)
+
+	c, err := Load(user, filepath.Join(dir, "missing"), local)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{user, local}; !reflect.DeepEqual(c.Files, want) {
+		t.Errorf("got files %v, want %v", c.Files, want)
+	}
+	if want := map[string]string{"recursive": "true", "progress": "log", "keep": "newest"}; !reflect.DeepEqual(values(c.Defaults), want) {
+		t.Errorf("got defaults %v, want %v", values(c.Defaults), want)
+	}
+	if got, want := c.Defaults["keep"].Source, local+":2"; got != want {
+		t.Errorf("got source %s, want %s", got, want)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"scan-type": "content", "exclude": "*.xmp,Thumbs.db", "reference": filepath.Join(home, "backup")}
+	if got := values(c.Profiles["photos"]); !reflect.DeepEqual(got, want) {
+		t.Errorf("got profile photos %v, want %v", got, want)
+	}
+	if got, want := c.ProfileNames(), []string{"music", "photos"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got profile names %v, want %v", got, want)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"unclosed section", "[profile photos\n", ":1: invalid section header"},
+		{"other section", "[photos]\n", ":1: invalid section"},
+		{"no value", "recursive = true\nrecursive\n", ":2: expected key = value"},
+		{"no key", "= true\n", ":1: missing key"},
+		{"nested profile", "[profile a]\nprofile = b\n", ":2: profile a cannot select another profile"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, t.TempDir(), "config", tt.content)
+			_, err := Load(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProfile(t *testing.T) {
+	c, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Profile("photos"); err == nil || !strings.Contains(err.Error(), "no profiles are defined") {
+		t.Errorf("got error %v without profiles", err)
+	}
+
+	c.Profiles["music"] = map[string]Setting{"scan-type": {Value: "tag"}}
+	if _, err := c.Profile("photos"); err == nil || !strings.Contains(err.Error(), "profiles: music") {
+		t.Errorf("got error %v for an unknown profile", err)
+	}
+	if profile, err := c.Profile("music"); err != nil || profile["scan-type"].Value != "tag" {
+		t.Errorf("got profile %v, %v", profile, err)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if got, want := EnvName("scan-type"), "DUPE_CLI_SCAN_TYPE"; got != want {
+		t.Errorf("EnvName(scan-type) = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,216 @@
+package dirsummary
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+	"github.com/tendant/dupe-cli/internal/units"
+)
+
+// MaxTextPairs is the number of directory pairs shown in the text summary
+const MaxTextPairs = 10
+
+// Dir is a directory holding duplicates, with the totals of its subtree
+type Dir struct {
+	Path           string  `json:"path"`
+	DuplicateFiles int     `json:"duplicate_files"`              // Duplicates in the directory and below
+	DuplicateBytes int64   `json:"duplicate_bytes"`              // Size of the duplicates in the directory and below
+	TotalBytes     int64   `json:"total_bytes,omitempty"`        // Size of all scanned files in the directory and below (0 if unknown)
+	Redundancy     float64 `json:"redundancy_percent,omitempty"` // Percentage of TotalBytes that is duplicates
+	Children       []*Dir  `json:"children,omitempty"`           // Subdirectories holding duplicates, most duplicate bytes first
+}
+
+// Pair is a pair of directories sharing content, the directories of a
+// reference and of its duplicates, whichever holds the reference. FirstDir
+// sorts before SecondDir, or they are the same directory.
+type Pair struct {
+	FirstDir  string `json:"first_dir"`
+	SecondDir string `json:"second_dir"`
+	Files     int    `json:"files"` // Duplicates in one directory of a reference in the other
+	Bytes     int64  `json:"bytes"` // Size of the duplicates
+}
+
+// Summary aggregates duplicate groups by directory
+type Summary struct {
+	Tree  []*Dir `json:"tree"`  // Top directories holding duplicates
+	Pairs []Pair `json:"pairs"` // Directory pairs, most shared bytes first
+}
+
+// Build aggregates duplicate groups by directory. Scanned are all files of
+// the scan, which give the redundancy of directories; it may be nil if they
+// are unknown, as for saved results.
+func Build(groups []*engine.DuplicateGroup, scanned []*fs.File) *Summary {
+	dirs := make(map[string]*Dir)
+	pairs := make(map[[2]string]*Pair)
+
+	for _, group := range groups {
+		refDir := filepath.Dir(group.Reference.Path)
+		for _, dupe := range group.Duplicates {
+			dupeDir := filepath.Dir(dupe.Path)
+			for _, path := range ancestors(dupeDir) {
+				dir := dirs[path]
+				if dir == nil {
+					dir = &Dir{Path: path}
+					dirs[path] = dir
+				}
+				dir.DuplicateFiles++
+				dir.DuplicateBytes += dupe.Size
+			}
+
+			// A pair is the same whichever of its directories holds the
+			// reference, which depends on the keep policy
+			key := [2]string{refDir, dupeDir}
+			if key[1] < key[0] {
+				key[0], key[1] = key[1], key[0]
+			}
+			pair := pairs[key]
+			if pair == nil {
+				pair = &Pair{FirstDir: key[0], SecondDir: key[1]}
+				pairs[key] = pair
+			}
+			pair.Files++
+			pair.Bytes += dupe.Size
+		}
+	}
+
+	// Total the scanned bytes of the directories holding duplicates
+	for _, file := range scanned {
+		for _, path := range ancestors(filepath.Dir(file.Path)) {
+			if dir := dirs[path]; dir != nil {
+				dir.TotalBytes += file.Size
+			}
+		}
+	}
+
+	s := &Summary{
+		Tree:  buildTree(dirs),
+		Pairs: make([]Pair, 0, len(pairs)),
+	}
+	for _, pair := range pairs {
+		s.Pairs = append(s.Pairs, *pair)
+	}
+	sort.Slice(s.Pairs, func(i, j int) bool {
+		a, b := s.Pairs[i], s.Pairs[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		if a.FirstDir != b.FirstDir {
+			return a.FirstDir < b.FirstDir
+		}
+		return a.SecondDir < b.SecondDir
+	})
+
+	return s
+}
+
+// buildTree links directories to their parents and returns the top
+// directories. The directories above the first one of each tree with more
+// than one subdirectory or duplicates of its own are left out.
+func buildTree(dirs map[string]*Dir) []*Dir {
+	var roots []*Dir
+	for path, dir := range dirs {
+		if dir.TotalBytes > 0 {
+			dir.Redundancy = float64(dir.DuplicateBytes) * 100 / float64(dir.TotalBytes)
+		}
+
+		parent := dirs[filepath.Dir(path)]
+		if parent == nil || parent == dir {
+			roots = append(roots, dir)
+		} else {
+			parent.Children = append(parent.Children, dir)
+		}
+	}
+
+	for _, dir := range dirs {
+		sortDirs(dir.Children)
+	}
+	sortDirs(roots)
+
+	for i, root := range roots {
+		for len(root.Children) == 1 && ownFiles(root) == 0 {
+			root = root.Children[0]
+		}
+		roots[i] = root
+	}
+	return roots
+}
+
+// ownFiles returns the number of duplicates directly in a directory
+func ownFiles(dir *Dir) int {
+	n := dir.DuplicateFiles
+	for _, child := range dir.Children {
+		n -= child.DuplicateFiles
+	}
+	return n
+}
+
+// sortDirs sorts directories by duplicate bytes, most first
+func sortDirs(dirs []*Dir) {
+	sort.Slice(dirs, func(i, j int) bool {
+		if dirs[i].DuplicateBytes != dirs[j].DuplicateBytes {
+			return dirs[i].DuplicateBytes > dirs[j].DuplicateBytes
+		}
+		return dirs[i].Path < dirs[j].Path
+	})
+}
+
+// ancestors returns a directory and all directories above it
+func ancestors(dir string) []string {
+	paths := []string{dir}
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return paths
+		}
+		paths = append(paths, parent)
+		dir = parent
+	}
+}
+
+// WriteText writes the summary as a tree of directories followed by the
+// directory pairs sharing the most content
+func (s *Summary) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("\nDuplicates by directory:\n")
+	if len(s.Tree) == 0 {
+		b.WriteString("  No duplicates found.\n")
+	}
+	for _, dir := range s.Tree {
+		writeDir(&b, dir, dir.Path, 1)
+	}
+
+	if len(s.Pairs) > 0 {
+		fmt.Fprintf(&b, "\nDirectories sharing the most content:\n")
+		for i, pair := range s.Pairs {
+			if i == MaxTextPairs {
+				fmt.Fprintf(&b, "  ... and %d more pairs\n", len(s.Pairs)-MaxTextPairs)
+				break
+			}
+			fmt.Fprintf(&b, "  %10s  %5d files  %s <-> %s\n",
+				units.FormatSize(pair.Bytes), pair.Files, pair.FirstDir, pair.SecondDir)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeDir writes a directory of the tree and its subdirectories
+func writeDir(b *strings.Builder, dir *Dir, name string, depth int) {
+	redundancy := ""
+	if dir.TotalBytes > 0 {
+		redundancy = fmt.Sprintf(", %.1f%% redundant", dir.Redundancy)
+	}
+	fmt.Fprintf(b, "%s%s  %s (%d files%s)\n",
+		strings.Repeat("  ", depth), name, units.FormatSize(dir.DuplicateBytes), dir.DuplicateFiles, redundancy)
+
+	for _, child := range dir.Children {
+		writeDir(b, child, filepath.Base(child.Path), depth+1)
+	}
+}
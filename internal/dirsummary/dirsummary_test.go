@@ -0,0 +1,115 @@
+package dirsummary
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+)
+
+// group creates a duplicate group of files of the given size, the first
+// path being the reference
+func group(size int64, paths ...string) *engine.DuplicateGroup {
+	g := &engine.DuplicateGroup{}
+	for i, path := range paths {
+		file := &fs.File{Path: path, Size: size}
+		if i == 0 {
+			g.Reference = file
+		} else {
+			g.Duplicates = append(g.Duplicates, file)
+		}
+	}
+	return g
+}
+
+func TestBuildPairs(t *testing.T) {
+	tests := []struct {
+		name   string
+		groups []*engine.DuplicateGroup
+		want   []Pair
+	}{
+		{
+			name:   "one pair",
+			groups: []*engine.DuplicateGroup{group(10, "/a/x", "/b/x")},
+			want:   []Pair{{FirstDir: "/a", SecondDir: "/b", Files: 1, Bytes: 10}},
+		},
+		{
+			// Whichever directory holds the reference, the pair is the same
+			name: "references on both sides",
+			groups: []*engine.DuplicateGroup{
+				group(10, "/a/x", "/b/x"),
+				group(20, "/b/y", "/a/y"),
+			},
+			want: []Pair{{FirstDir: "/a", SecondDir: "/b", Files: 2, Bytes: 30}},
+		},
+		{
+			name: "most shared bytes first",
+			groups: []*engine.DuplicateGroup{
+				group(5, "/c/x", "/a/x"),
+				group(10, "/a/y", "/b/y", "/a/z"),
+			},
+			want: []Pair{
+				{FirstDir: "/a", SecondDir: "/a", Files: 1, Bytes: 10},
+				{FirstDir: "/a", SecondDir: "/b", Files: 1, Bytes: 10},
+				{FirstDir: "/a", SecondDir: "/c", Files: 1, Bytes: 5},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Build(tt.groups, nil).Pairs; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pairs = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildTree(t *testing.T) {
+	groups := []*engine.DuplicateGroup{
+		group(100, "/data/photos/a.jpg", "/data/backup/2019/a.jpg", "/data/backup/2020/a.jpg"),
+		group(50, "/data/photos/b.jpg", "/data/backup/2019/b.jpg"),
+	}
+	scanned := []*fs.File{
+		{Path: "/data/photos/a.jpg", Size: 100},
+		{Path: "/data/photos/b.jpg", Size: 50},
+		{Path: "/data/backup/2019/a.jpg", Size: 100},
+		{Path: "/data/backup/2019/b.jpg", Size: 50},
+		{Path: "/data/backup/2019/c.jpg", Size: 50},
+		{Path: "/data/backup/2020/a.jpg", Size: 100},
+	}
+
+	s := Build(groups, scanned)
+
+	// The directories above the first with more than one subdirectory are left out
+	if len(s.Tree) != 1 || s.Tree[0].Path != "/data/backup" {
+		t.Fatalf("tree roots = %+v, want /data/backup", s.Tree)
+	}
+	backup := s.Tree[0]
+	if backup.DuplicateFiles != 3 || backup.DuplicateBytes != 250 || backup.TotalBytes != 300 {
+		t.Errorf("/data/backup = %+v, want 3 files, 250 duplicate bytes of 300", backup)
+	}
+
+	var children []string
+	for _, child := range backup.Children {
+		children = append(children, child.Path)
+	}
+	if want := []string{"/data/backup/2019", "/data/backup/2020"}; !reflect.DeepEqual(children, want) {
+		t.Errorf("children = %q, want %q", children, want)
+	}
+	if got := backup.Children[0].Redundancy; got != 75 {
+		t.Errorf("redundancy of 2019 = %v, want 75", got)
+	}
+
+	var b strings.Builder
+	if err := s.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"/data/backup  250 B (3 files, 83.3% redundant)", "    2019  150 B (2 files, 75.0% redundant)", "/data/backup/2019 <-> /data/photos"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("text summary doesn't contain %q:\n%s", want, b.String())
+		}
+	}
+}
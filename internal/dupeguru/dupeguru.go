@@ -0,0 +1,247 @@
+package dupeguru
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+	"github.com/tendant/dupe-cli/internal/matcher"
+)
+
+// resultsXML is the root of a dupeGuru results file
+type resultsXML struct {
+	XMLName xml.Name   `xml:"results"`
+	Groups  []groupXML `xml:"group"`
+	Errors  []errorXML `xml:"error"`
+}
+
+// groupXML is a duplicate group in a dupeGuru results file.
+// Its first file is the reference.
+type groupXML struct {
+	Files   []fileXML  `xml:"file"`
+	Matches []matchXML `xml:"match"`
+}
+
+// fileXML is a file in a dupeGuru results file
+type fileXML struct {
+	Path   string `xml:"path,attr"`
+	Words  string `xml:"words,attr"`
+	IsRef  string `xml:"is_ref,attr"`
+	Marked string `xml:"marked,attr"`
+}
+
+// matchXML is a match between two files of a group, given by their index
+type matchXML struct {
+	First      string `xml:"first,attr"`
+	Second     string `xml:"second,attr"`
+	Percentage string `xml:"percentage,attr"`
+}
+
+// errorXML is a path that could not be read by the scan. dupeGuru only
+// reads the groups of a results file, and ignores these elements.
+type errorXML struct {
+	Path     string `xml:"path,attr"`
+	Category string `xml:"category,attr"`
+	Message  string `xml:"message,attr"`
+}
+
+// ignoreListXML is the root of a dupeGuru ignore list file
+type ignoreListXML struct {
+	XMLName xml.Name        `xml:"ignore_list"`
+	Files   []ignoreFileXML `xml:"file"`
+}
+
+// ignoreFileXML is a file that is ignored together with each of its children
+type ignoreFileXML struct {
+	Path  string          `xml:"path,attr"`
+	Files []ignoreFileXML `xml:"file"`
+}
+
+// IgnorePair is a pair of files that dupeGuru was told not to report as duplicates
+type IgnorePair struct {
+	First  string
+	Second string
+}
+
+// WriteResults writes duplicate groups in dupeGuru's results format, followed
+// by the paths that could not be read
+func WriteResults(w io.Writer, groups []*engine.DuplicateGroup, errs []*fs.PathError) error {
+	doc := resultsXML{Groups: make([]groupXML, 0, len(groups))}
+
+	for _, group := range groups {
+		files := append([]*fs.File{group.Reference}, group.Duplicates...)
+		g := groupXML{
+			Files:   make([]fileXML, 0, len(files)),
+			Matches: make([]matchXML, 0, len(group.Matches)),
+		}
+
+		for _, file := range files {
+			g.Files = append(g.Files, fileXML{
+				Path:   file.Path,
+				Words:  strings.Join(file.ExtractWords(), ","),
+				IsRef:  yesNo(file.IsReference),
+				Marked: "n",
+			})
+		}
+
+		// Duplicates[j] is file j+1 and was matched against the reference
+		for j, match := range group.Matches {
+			g.Matches = append(g.Matches, matchXML{
+				First:      "0",
+				Second:     strconv.Itoa(j + 1),
+				Percentage: strconv.Itoa(match.Percentage),
+			})
+		}
+
+		doc.Groups = append(doc.Groups, g)
+	}
+
+	for _, pathErr := range errs {
+		doc.Errors = append(doc.Errors, errorXML{
+			Path:     pathErr.Path,
+			Category: pathErr.Category.String(),
+			Message:  pathErr.Err.Error(),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ReadResults reads duplicate groups from a dupeGuru results file. Like dupeGuru,
+// files that no longer exist are dropped, as are groups left with a single file.
+// Files marked as references come first in their group and the first of them
+// becomes the group's reference.
+func ReadResults(r io.Reader) ([]*engine.DuplicateGroup, error) {
+	var doc resultsXML
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid dupeGuru results file: %w", err)
+	}
+
+	groups := make([]*engine.DuplicateGroup, 0, len(doc.Groups))
+	for _, g := range doc.Groups {
+		// Load the files that still exist, keeping their index for the matches
+		files := make([]*fs.File, len(g.Files))
+		var refs, others []*fs.File
+		for i, f := range g.Files {
+			file, err := fs.NewFile(f.Path)
+			if err != nil {
+				continue
+			}
+			file.IsReference = f.IsRef == "y"
+			files[i] = file
+			if file.IsReference {
+				refs = append(refs, file)
+			} else {
+				others = append(others, file)
+			}
+		}
+
+		ordered := append(refs, others...)
+		if len(ordered) < 2 {
+			continue
+		}
+
+		group := &engine.DuplicateGroup{
+			Reference:  ordered[0],
+			Duplicates: ordered[1:],
+			Matches:    make([]*matcher.Match, 0, len(ordered)-1),
+		}
+		for _, dupe := range group.Duplicates {
+			group.Matches = append(group.Matches, &matcher.Match{
+				First:      group.Reference,
+				Second:     dupe,
+				Percentage: matchPercentage(g.Matches, files, group.Reference, dupe),
+			})
+		}
+
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
+
+// matchPercentage returns the percentage of the match between ref and dupe.
+// If the file has no match with ref, its best match in the group is used,
+// as dupeGuru only records the matches it found.
+func matchPercentage(matches []matchXML, files []*fs.File, ref, dupe *fs.File) int {
+	best := 0
+	for _, m := range matches {
+		first, err1 := strconv.Atoi(m.First)
+		second, err2 := strconv.Atoi(m.Second)
+		percentage, err3 := strconv.Atoi(m.Percentage)
+		if err1 != nil || err2 != nil || err3 != nil ||
+			first < 0 || first >= len(files) || second < 0 || second >= len(files) {
+			continue
+		}
+
+		a, b := files[first], files[second]
+		if (a == ref && b == dupe) || (a == dupe && b == ref) {
+			return percentage
+		}
+		if (a == dupe || b == dupe) && percentage > best {
+			best = percentage
+		}
+	}
+	return best
+}
+
+// ReadIgnoreList reads the pairs of files from a dupeGuru ignore list file
+func ReadIgnoreList(r io.Reader) ([]IgnorePair, error) {
+	var doc ignoreListXML
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid dupeGuru ignore list: %w", err)
+	}
+
+	var pairs []IgnorePair
+	for _, first := range doc.Files {
+		for _, second := range first.Files {
+			if first.Path == "" || second.Path == "" {
+				continue
+			}
+			pairs = append(pairs, IgnorePair{First: first.Path, Second: second.Path})
+		}
+	}
+	return pairs, nil
+}
+
+// ReadResultsFile reads duplicate groups from a dupeGuru results file
+func ReadResultsFile(path string) ([]*engine.DuplicateGroup, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadResults(f)
+}
+
+// ReadIgnoreListFile reads the pairs of files from a dupeGuru ignore list file
+func ReadIgnoreListFile(path string) ([]IgnorePair, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadIgnoreList(f)
+}
+
+// yesNo converts a boolean to dupeGuru's "y"/"n" attribute values
+func yesNo(b bool) string {
+	if b {
+		return "y"
+	}
+	return "n"
+}
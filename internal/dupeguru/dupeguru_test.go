@@ -0,0 +1,145 @@
+package dupeguru
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+	"github.com/tendant/dupe-cli/internal/matcher"
+)
+
+// newGroup creates the files of a group in dir, the first being the
+// reference, with the match percentages of the duplicates
+func newGroup(t *testing.T, dir string, names []string, percentages ...int) *engine.DuplicateGroup {
+	t.Helper()
+	var files []*fs.File
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		file, err := fs.NewFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	group := &engine.DuplicateGroup{Reference: files[0], Duplicates: files[1:]}
+	for i, dupe := range group.Duplicates {
+		group.Matches = append(group.Matches, &matcher.Match{First: files[0], Second: dupe, Percentage: percentages[i]})
+	}
+	return group
+}
+
+// summarize returns the paths of the files of groups, the reference first,
+// with their match percentages
+func summarize(groups []*engine.DuplicateGroup) [][]string {
+	var out [][]string
+	for _, group := range groups {
+		paths := []string{group.Reference.Path}
+		for i, dupe := range group.Duplicates {
+			paths = append(paths, fmt.Sprintf("%s %d%%", dupe.Path, group.Matches[i].Percentage))
+		}
+		out = append(out, paths)
+	}
+	return out
+}
+
+func TestResultsRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	groups := []*engine.DuplicateGroup{
+		newGroup(t, dir, []string{"a.jpg", "a copy.jpg", "a (1).jpg"}, 100, 90),
+		newGroup(t, dir, []string{"b & <c>.txt", "b & <c> copy.txt"}, 80),
+	}
+	errs := []*fs.PathError{fs.NewPathError(filepath.Join(dir, "locked"), os.ErrPermission)}
+
+	var buf bytes.Buffer
+	if err := WriteResults(&buf, groups, errs); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `category="permission"`) {
+		t.Errorf("errors are not written:\n%s", buf.String())
+	}
+
+	read, err := ReadResults(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := summarize(read), summarize(groups); !reflect.DeepEqual(got, want) {
+		t.Errorf("read groups %q, want %q", got, want)
+	}
+}
+
+func TestReadResults(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b", "c", "d"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	results := `<?xml version="1.0" encoding="utf-8"?>
+<results>
+  <group>
+    <file path="` + path("a") + `" words="a" is_ref="n" marked="n"/>
+    <file path="` + path("b") + `" words="b" is_ref="y" marked="n"/>
+    <file path="` + path("c") + `" words="c" is_ref="n" marked="n"/>
+    <match first="1" second="0" percentage="95"/>
+    <match first="0" second="2" percentage="70"/>
+  </group>
+  <group>
+    <file path="` + path("d") + `" words="d" is_ref="n" marked="n"/>
+    <file path="` + path("gone") + `" words="gone" is_ref="n" marked="n"/>
+    <match first="0" second="1" percentage="100"/>
+  </group>
+</results>`
+
+	groups, err := ReadResults(strings.NewReader(results))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The file marked as a reference comes first, a file without a match
+	// with it gets its best match, and a group left with one file is dropped
+	want := [][]string{{path("b"), path("a") + " 95%", path("c") + " 70%"}}
+	if got := summarize(groups); !reflect.DeepEqual(got, want) {
+		t.Errorf("groups %q, want %q", got, want)
+	}
+	if !groups[0].Reference.IsReference {
+		t.Error("reference is not marked as a reference")
+	}
+
+	if _, err := ReadResults(strings.NewReader("<results><group>")); err == nil {
+		t.Error("truncated results were read without an error")
+	}
+}
+
+func TestReadIgnoreList(t *testing.T) {
+	list := `<?xml version="1.0" encoding="utf-8"?>
+<ignore_list>
+  <file path="/a">
+    <file path="/b"/>
+    <file path="/c"/>
+  </file>
+  <file path="/d">
+    <file path=""/>
+  </file>
+</ignore_list>`
+
+	pairs, err := ReadIgnoreList(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []IgnorePair{{First: "/a", Second: "/b"}, {First: "/a", Second: "/c"}}
+	if !reflect.DeepEqual(pairs, want) {
+		t.Errorf("pairs %v, want %v", pairs, want)
+	}
+}
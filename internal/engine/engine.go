@@ -4,12 +4,19 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/tendant/dupe-cli/internal/fs"
+	"github.com/tendant/dupe-cli/internal/hash"
+	"github.com/tendant/dupe-cli/internal/ignore"
 	"github.com/tendant/dupe-cli/internal/matcher"
+	"github.com/tendant/dupe-cli/internal/progress"
 	"github.com/tendant/dupe-cli/internal/scanner"
 )
 
+// DefaultProgressInterval is the minimum time between two progress events
+const DefaultProgressInterval = 100 * time.Millisecond
+
 // DuplicateGroup represents a group of duplicate files
 type DuplicateGroup struct {
 	Reference  *fs.File         // Reference file (original)
@@ -19,18 +26,31 @@ type DuplicateGroup struct {
 
 // Engine is responsible for finding duplicates
 type Engine struct {
-	Scanner *scanner.Scanner
-	Matcher *matcher.Matcher
-	groups  []*DuplicateGroup
-	mu      sync.Mutex
+	Scanner          *scanner.Scanner
+	Matcher          *matcher.Matcher
+	OnProgress       progress.Func         // Receives progress events during FindDuplicates (may be nil)
+	OnFileHashed     func(*fs.File)        // Called after a digest of a file was calculated (may be nil)
+	OnGroup          func(*DuplicateGroup) // Called as soon as a duplicate group is confirmed (may be nil)
+	Ignore           *ignore.List          // Files and pairs not to report as duplicates (may be nil)
+	Keep             KeepPolicy            // Which file of a group is its reference (KeepFirst if empty)
+	ProgressInterval time.Duration         // Minimum time between two progress events
+	Seed             *Seed                 // Groups of a previous scan to update rather than find again (may be nil)
+	Fingerprint      bool                  // Whether to record fingerprints of the compared files, for a later Seed
+	groups           []*DuplicateGroup
+	errors           []*fs.PathError
+	fingerprints     map[int64]string
+	kept             int
+	tracker          *progress.Tracker
+	mu               sync.Mutex
 }
 
 // NewEngine creates a new Engine instance
 func NewEngine(scanner *scanner.Scanner, matcher *matcher.Matcher) *Engine {
 	return &Engine{
-		Scanner: scanner,
-		Matcher: matcher,
-		groups:  make([]*DuplicateGroup, 0),
+		Scanner:          scanner,
+		Matcher:          matcher,
+		ProgressInterval: DefaultProgressInterval,
+		groups:           make([]*DuplicateGroup, 0),
 	}
 }
 
@@ -39,8 +59,20 @@ func (e *Engine) FindDuplicates() ([]*DuplicateGroup, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	// Scan directories
+	e.tracker = progress.NewTracker(e.OnProgress, e.ProgressInterval)
+	defer func() { e.tracker = nil }()
+
+	// Scan directories, reporting each discovered file
+	e.tracker.SetStage(progress.StageScanning, 0, 0)
+	onFile := e.Scanner.OnFile
+	e.Scanner.OnFile = func(file *fs.File) {
+		e.tracker.FileFound()
+		if onFile != nil {
+			onFile(file)
+		}
+	}
 	_, err := e.Scanner.Scan()
+	e.Scanner.OnFile = onFile
 	if err != nil {
 		return nil, fmt.Errorf("scan error: %w", err)
 	}
@@ -48,63 +80,194 @@ func (e *Engine) FindDuplicates() ([]*DuplicateGroup, error) {
 	// Get potential duplicates (files with same size)
 	potentialDupes := e.Scanner.GetPotentialDuplicates()
 
+	// Fingerprint the files of each size, when they are recorded or
+	// compared with those of the previous scan
+	fingerprints := make([]string, len(potentialDupes))
+	if e.Fingerprint || e.Seed != nil {
+		settings := e.settings()
+		for i, files := range potentialDupes {
+			fingerprints[i] = fingerprint(settings, files)
+		}
+	}
+
+	// Only the files of the sizes that changed since the previous scan are
+	// compared; the groups of the others are kept
+	previous := e.seedGroups()
+	unchanged := make([]bool, len(potentialDupes))
+	compared := make([][]*fs.File, 0, len(potentialDupes))
+	for i, files := range potentialDupes {
+		if e.Seed != nil && e.Seed.Fingerprints[files[0].Size] == fingerprints[i] {
+			unchanged[i] = true
+		} else {
+			compared = append(compared, files)
+		}
+	}
+
 	// Process each group of potential duplicates
 	e.groups = make([]*DuplicateGroup, 0)
+	e.errors = nil
+	e.fingerprints = make(map[int64]string)
+	e.kept = 0
+	e.startMatchStage(compared)
 
 	// Use a more sophisticated approach for grouping duplicates
-	for _, files := range potentialDupes {
-		e.processFileGroup(files)
+	for i, files := range potentialDupes {
+		size := files[0].Size
+		errorCount := len(e.errors)
+		if unchanged[i] {
+			if err := e.keepGroups(files, previous[size]); err != nil {
+				return nil, err
+			}
+			e.kept++
+		} else {
+			err := e.processFileGroup(files)
+			e.tracker.FilesDone(len(files))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// Files that could not be hashed are compared again by the next scan
+		if e.Fingerprint && len(e.errors) == errorCount {
+			e.fingerprints[size] = fingerprints[i]
+		}
 	}
+	e.tracker.Finish()
 
-	// Sort groups by number of duplicates (descending)
-	sort.Slice(e.groups, func(i, j int) bool {
+	// Sort groups by number of duplicates (descending), keeping the order
+	// groups were found in for ties so results are reproducible
+	sort.SliceStable(e.groups, func(i, j int) bool {
 		return len(e.groups[i].Duplicates) > len(e.groups[j].Duplicates)
 	})
 
 	return e.groups, nil
 }
 
+// startMatchStage reports the start of the hashing or matching stage
+func (e *Engine) startMatchStage(potentialDupes [][]*fs.File) {
+	fileCount := 0
+	var byteCount int64
+	for _, files := range potentialDupes {
+		fileCount += len(files)
+		for _, file := range files {
+			byteCount += expectedHashBytes(file)
+		}
+	}
+
+	if e.Matcher.Options.Type == matcher.MatchTypeExact {
+		e.tracker.SetStage(progress.StageHashing, fileCount, byteCount)
+	} else {
+		e.tracker.SetStage(progress.StageMatching, fileCount, 0)
+	}
+}
+
+// expectedHashBytes returns the number of bytes the first hashing pass reads from a file
+func expectedHashBytes(file *fs.File) int64 {
+	if file.Size >= 3*1024*1024 { // 3MB
+		return hash.PartialSize
+	}
+	return file.Size
+}
+
+// getDigest returns the full or partial digest of a file and reports the bytes read
+func (e *Engine) getDigest(file *fs.File, partial bool) ([]byte, error) {
+	if partial {
+		if file.DigestPart != nil {
+			return file.DigestPart, nil
+		}
+		digest, err := file.GetPartialDigest()
+		if err == nil {
+			e.fileHashed(file, hash.PartialSize)
+		}
+		return digest, err
+	}
+
+	if file.Digest != nil {
+		return file.Digest, nil
+	}
+	digest, err := file.GetDigest()
+	if err == nil {
+		e.fileHashed(file, file.Size)
+	}
+	return digest, err
+}
+
+// fileHashed reports a newly calculated digest
+func (e *Engine) fileHashed(file *fs.File, bytes int64) {
+	e.tracker.BytesHashed(bytes)
+	if e.OnFileHashed != nil {
+		e.OnFileHashed(file)
+	}
+}
+
 // processFileGroup processes a group of files with the same size
-func (e *Engine) processFileGroup(files []*fs.File) {
+func (e *Engine) processFileGroup(files []*fs.File) error {
+	// Drop files on the ignore list before hashing or comparing them
+	if e.Ignore != nil {
+		remaining := make([]*fs.File, 0, len(files))
+		for _, file := range files {
+			if !e.Ignore.IgnoresFile(file) {
+				remaining = append(remaining, file)
+			}
+		}
+		files = remaining
+	}
+
 	// Skip if less than 2 files
 	if len(files) < 2 {
-		return
+		return nil
 	}
 
+	// Order files so that the one to keep of each group comes first
+	files = e.Keep.order(files)
+
 	// For exact matching, we can optimize by first grouping by hash
 	if e.Matcher.Options.Type == matcher.MatchTypeExact {
-		e.processExactMatches(files)
-	} else {
-		e.processFuzzyMatches(files)
+		return e.processExactMatches(files)
+	}
+	e.processFuzzyMatches(files)
+	return nil
+}
+
+// handleHashError records a hashing error, returning it only in strict mode
+func (e *Engine) handleHashError(file *fs.File, err error) error {
+	if e.Scanner.Strict {
+		return fmt.Errorf("hash error for %s: %w", file.Path, err)
 	}
+	e.errors = append(e.errors, fs.NewPathError(file.Path, err))
+	return nil
 }
 
 // processExactMatches processes files using exact matching (hash-based)
-func (e *Engine) processExactMatches(files []*fs.File) {
-	// Group files by hash
+func (e *Engine) processExactMatches(files []*fs.File) error {
+	// Group files by hash, remembering the order hashes were first seen in
 	filesByHash := make(map[string][]*fs.File)
+	var hashOrder []string
 
 	for _, file := range files {
 		// Get hash (partial for large files, full for small files)
 		var hash []byte
 		var err error
 
-		if file.Size >= 3*1024*1024 { // 3MB
-			hash, err = file.GetPartialDigest()
-		} else {
-			hash, err = file.GetDigest()
-		}
+		hash, err = e.getDigest(file, file.Size >= 3*1024*1024) // 3MB
 
 		if err != nil {
+			if err := e.handleHashError(file, err); err != nil {
+				return err
+			}
 			continue
 		}
 
 		hashStr := string(hash)
+		if filesByHash[hashStr] == nil {
+			hashOrder = append(hashOrder, hashStr)
+		}
 		filesByHash[hashStr] = append(filesByHash[hashStr], file)
 	}
 
 	// Process each hash group
-	for _, hashGroup := range filesByHash {
+	for _, hashStr := range hashOrder {
+		hashGroup := filesByHash[hashStr]
 		if len(hashGroup) < 2 {
 			continue
 		}
@@ -112,19 +275,28 @@ func (e *Engine) processExactMatches(files []*fs.File) {
 		// For files with the same partial hash, verify with full hash
 		if hashGroup[0].Size >= 3*1024*1024 {
 			filesByFullHash := make(map[string][]*fs.File)
+			var fullHashOrder []string
+			e.tracker.AddBytesTotal(int64(len(hashGroup)) * hashGroup[0].Size)
 
 			for _, file := range hashGroup {
-				hash, err := file.GetDigest()
+				hash, err := e.getDigest(file, false)
 				if err != nil {
+					if err := e.handleHashError(file, err); err != nil {
+						return err
+					}
 					continue
 				}
 
-				hashStr := string(hash)
-				filesByFullHash[hashStr] = append(filesByFullHash[hashStr], file)
+				fullHashStr := string(hash)
+				if filesByFullHash[fullHashStr] == nil {
+					fullHashOrder = append(fullHashOrder, fullHashStr)
+				}
+				filesByFullHash[fullHashStr] = append(filesByFullHash[fullHashStr], file)
 			}
 
 			// Create groups for each full hash match
-			for _, fullHashGroup := range filesByFullHash {
+			for _, fullHashStr := range fullHashOrder {
+				fullHashGroup := filesByFullHash[fullHashStr]
 				if len(fullHashGroup) < 2 {
 					continue
 				}
@@ -136,6 +308,8 @@ func (e *Engine) processExactMatches(files []*fs.File) {
 			e.createDuplicateGroup(hashGroup)
 		}
 	}
+
+	return nil
 }
 
 // processFuzzyMatches processes files using fuzzy matching (filename-based)
@@ -156,7 +330,7 @@ func (e *Engine) processFuzzyMatches(files []*fs.File) {
 		duplicates := make([]*fs.File, 0)
 
 		for _, otherFile := range files {
-			if file == otherFile || processed[otherFile] {
+			if file == otherFile || processed[otherFile] || e.Ignore.IgnoresPair(file, otherFile) {
 				continue
 			}
 
@@ -172,38 +346,67 @@ func (e *Engine) processFuzzyMatches(files []*fs.File) {
 		if len(duplicates) > 0 {
 			processed[file] = true
 
-			group := &DuplicateGroup{
+			e.addGroup(&DuplicateGroup{
 				Reference:  file,
 				Duplicates: duplicates,
 				Matches:    matches,
-			}
-
-			e.groups = append(e.groups, group)
+			})
 		}
 	}
 }
 
 // createDuplicateGroup creates a duplicate group from a list of files
 func (e *Engine) createDuplicateGroup(files []*fs.File) {
-	// Use the first file as reference
-	reference := files[0]
-	duplicates := files[1:]
-
-	// Create matches
-	matches := make([]*matcher.Match, 0, len(duplicates))
-	for _, dupe := range duplicates {
-		match := e.Matcher.Match(reference, dupe)
-		matches = append(matches, match)
+	// Drop files whose content is on the ignore list
+	remaining := make([]*fs.File, 0, len(files))
+	for _, file := range files {
+		if !e.Ignore.IgnoresFile(file) {
+			remaining = append(remaining, file)
+		}
 	}
 
-	// Create group
-	group := &DuplicateGroup{
-		Reference:  reference,
-		Duplicates: duplicates,
-		Matches:    matches,
+	for len(remaining) >= 2 {
+		// Use the first file as reference
+		reference := remaining[0]
+
+		// Files ignored as a pair with the reference are grouped separately
+		duplicates := make([]*fs.File, 0, len(remaining)-1)
+		var rest []*fs.File
+		for _, file := range remaining[1:] {
+			if e.Ignore.IgnoresPair(reference, file) {
+				rest = append(rest, file)
+			} else {
+				duplicates = append(duplicates, file)
+			}
+		}
+		remaining = rest
+
+		if len(duplicates) == 0 {
+			continue
+		}
+
+		// Create matches
+		matches := make([]*matcher.Match, 0, len(duplicates))
+		for _, dupe := range duplicates {
+			match := e.Matcher.Match(reference, dupe)
+			matches = append(matches, match)
+		}
+
+		// Create group
+		e.addGroup(&DuplicateGroup{
+			Reference:  reference,
+			Duplicates: duplicates,
+			Matches:    matches,
+		})
 	}
+}
 
+// addGroup records a confirmed duplicate group and reports it
+func (e *Engine) addGroup(group *DuplicateGroup) {
 	e.groups = append(e.groups, group)
+	if e.OnGroup != nil {
+		e.OnGroup(group)
+	}
 }
 
 // GetGroups returns the duplicate groups
@@ -213,25 +416,64 @@ func (e *Engine) GetGroups() []*DuplicateGroup {
 	return e.groups
 }
 
+// GetFingerprints returns the fingerprint of the files of each size the
+// last scan compared, if Fingerprint is set
+func (e *Engine) GetFingerprints() map[int64]string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.fingerprints
+}
+
+// GetKeptSizes returns the number of file sizes whose groups the last scan
+// kept from the seed
+func (e *Engine) GetKeptSizes() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.kept
+}
+
+// GetErrors returns the errors for paths that were skipped while
+// scanning or hashing, sorted by path
+func (e *Engine) GetErrors() []*fs.PathError {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	errs := make([]*fs.PathError, 0, len(e.errors))
+	errs = append(errs, e.Scanner.GetErrors()...)
+	errs = append(errs, e.errors...)
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Path < errs[j].Path
+	})
+	return errs
+}
+
 // GetTotalDuplicateCount returns the total number of duplicate files
 func (e *Engine) GetTotalDuplicateCount() int {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-
-	count := 0
-	for _, group := range e.groups {
-		count += len(group.Duplicates)
-	}
-	return count
+	return TotalDuplicateCount(e.groups)
 }
 
 // GetTotalDuplicateSize returns the total size of duplicate files
 func (e *Engine) GetTotalDuplicateSize() int64 {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	return TotalDuplicateSize(e.groups)
+}
+
+// TotalDuplicateCount returns the total number of duplicate files in groups
+func TotalDuplicateCount(groups []*DuplicateGroup) int {
+	count := 0
+	for _, group := range groups {
+		count += len(group.Duplicates)
+	}
+	return count
+}
 
+// TotalDuplicateSize returns the total size of duplicate files in groups
+func TotalDuplicateSize(groups []*DuplicateGroup) int64 {
 	var size int64
-	for _, group := range e.groups {
+	for _, group := range groups {
 		for _, dupe := range group.Duplicates {
 			size += dupe.Size
 		}
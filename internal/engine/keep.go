@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"sort"
+
+	"github.com/tendant/dupe-cli/internal/fs"
+)
+
+// KeepPolicy decides which file of a duplicate group is its reference, the
+// file that is kept. Files in reference directories always come first.
+type KeepPolicy string
+
+const (
+	KeepFirst        KeepPolicy = "first"         // First file found by the scan
+	KeepOldest       KeepPolicy = "oldest"        // Earliest modification time
+	KeepNewest       KeepPolicy = "newest"        // Latest modification time
+	KeepShortestPath KeepPolicy = "shortest-path" // Shortest path
+	KeepLongestPath  KeepPolicy = "longest-path"  // Longest path
+)
+
+// KeepPolicies are the names of the keep policies
+var KeepPolicies = []string{
+	string(KeepFirst), string(KeepOldest), string(KeepNewest), string(KeepShortestPath), string(KeepLongestPath),
+}
+
+// order returns a copy of files in the order they become references: files
+// in reference directories first, then by the policy, keeping the scan
+// order for ties
+func (p KeepPolicy) order(files []*fs.File) []*fs.File {
+	ordered := append([]*fs.File(nil), files...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if a.IsReference != b.IsReference {
+			return a.IsReference
+		}
+
+		switch p {
+		case KeepOldest:
+			return a.ModTime.Before(b.ModTime)
+		case KeepNewest:
+			return a.ModTime.After(b.ModTime)
+		case KeepShortestPath:
+			return len(a.Path) < len(b.Path)
+		case KeepLongestPath:
+			return len(a.Path) > len(b.Path)
+		default:
+			return false
+		}
+	})
+	return ordered
+}
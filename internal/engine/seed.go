@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/tendant/dupe-cli/internal/fs"
+)
+
+// Seed is the outcome of a previous scan, whose groups an incremental scan
+// updates rather than finding them all again
+type Seed struct {
+	Fingerprints map[int64]string  // Fingerprint of the files of each size the previous scan compared
+	Groups       []*DuplicateGroup // Groups found by the previous scan
+}
+
+// settings returns what decides how files of the same size are grouped,
+// besides the files themselves
+func (e *Engine) settings() string {
+	s := fmt.Sprintf("%+v\x00%s\x00", e.Matcher.Options, e.Keep)
+	if e.Ignore != nil {
+		for _, rule := range e.Ignore.Rules {
+			s += fmt.Sprintf("%s\x00%q\x00%s\x00%s\x00", rule.Type, rule.Paths, rule.Digest, rule.Pattern)
+		}
+	}
+	return s
+}
+
+// fingerprint returns a fingerprint of files of the same size: their paths,
+// modification times and inodes, and the settings they are grouped with.
+// Files with the fingerprint they had in a previous scan form the same groups.
+func fingerprint(settings string, files []*fs.File) string {
+	entries := make([]string, 0, len(files))
+	for _, file := range files {
+		entries = append(entries, fmt.Sprintf("%s\x00%d\x00%d\x00%d\x00%t\x00",
+			file.Path, file.ModTime.UnixNano(), file.Dev, file.Inode, file.IsReference))
+	}
+	sort.Strings(entries)
+
+	h := sha256.New()
+	h.Write([]byte(settings))
+	for _, entry := range entries {
+		h.Write([]byte(entry))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// seedGroups returns the groups of the seed by file size
+func (e *Engine) seedGroups() map[int64][]*DuplicateGroup {
+	bySize := make(map[int64][]*DuplicateGroup)
+	if e.Seed != nil {
+		for _, group := range e.Seed.Groups {
+			size := group.Reference.Size
+			bySize[size] = append(bySize[size], group)
+		}
+	}
+	return bySize
+}
+
+// keepGroups groups files of a size that are unchanged since the previous
+// scan. Only the files of its groups are compared again: the others had no
+// duplicates, and still don't.
+func (e *Engine) keepGroups(files []*fs.File, previous []*DuplicateGroup) error {
+	grouped := make(map[string]bool)
+	for _, group := range previous {
+		grouped[group.Reference.Path] = true
+		for _, dupe := range group.Duplicates {
+			grouped[dupe.Path] = true
+		}
+	}
+
+	members := make([]*fs.File, 0, len(grouped))
+	for _, file := range files {
+		if grouped[file.Path] {
+			members = append(members, file)
+		}
+	}
+	return e.processFileGroup(members)
+}
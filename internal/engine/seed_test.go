@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/fs"
+	"github.com/tendant/dupe-cli/internal/matcher"
+	"github.com/tendant/dupe-cli/internal/scanner"
+)
+
+// writeFile writes a file in root with a modification time, so that a
+// rewrite is seen as a change
+func writeFile(t *testing.T, root, name, content string, modTime time.Time) {
+	t.Helper()
+	path := filepath.Join(root, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// findDuplicates runs a content scan of root, updating the groups of seed
+// if it isn't nil, and returns the engine and the files it hashed
+func findDuplicates(t *testing.T, root string, seed *Seed) (*Engine, []string) {
+	t.Helper()
+	s, err := scanner.NewScanner([]string{root}, "", false, scanner.ScanTypeContent, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngine(s, matcher.NewMatcher(matcher.MatchOptions{Type: matcher.MatchTypeExact}))
+	e.Fingerprint = true
+	e.Seed = seed
+
+	var hashed []string
+	e.OnFileHashed = func(file *fs.File) {
+		hashed = append(hashed, filepath.Base(file.Path))
+	}
+	if _, err := e.FindDuplicates(); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(hashed)
+	return e, hashed
+}
+
+// groupNames returns the sorted base names of the files of each group
+func groupNames(groups []*DuplicateGroup) [][]string {
+	var names [][]string
+	for _, group := range groups {
+		files := []string{filepath.Base(group.Reference.Path)}
+		for _, dupe := range group.Duplicates {
+			files = append(files, filepath.Base(dupe.Path))
+		}
+		sort.Strings(files)
+		names = append(names, files)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i][0] < names[j][0] })
+	return names
+}
+
+func TestSeedKeepsUnchangedGroups(t *testing.T) {
+	root := t.TempDir()
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	writeFile(t, root, "a1", "aaaa", modTime)
+	writeFile(t, root, "a2", "aaaa", modTime)
+	writeFile(t, root, "b1", "bbbbbb", modTime)
+	writeFile(t, root, "b2", "bbbbbb", modTime)
+	writeFile(t, root, "c1", "cccccccc", modTime)
+	writeFile(t, root, "c2", "CCCCCCCC", modTime)
+
+	first, _ := findDuplicates(t, root, nil)
+	want := [][]string{{"a1", "a2"}, {"b1", "b2"}}
+	if got := groupNames(first.GetGroups()); !reflect.DeepEqual(got, want) {
+		t.Fatalf("first scan: got groups %q, want %q", got, want)
+	}
+	seed := &Seed{Fingerprints: first.GetFingerprints(), Groups: first.GetGroups()}
+
+	// Unchanged, the groups are kept and only their files are hashed again
+	again, hashed := findDuplicates(t, root, seed)
+	if got := groupNames(again.GetGroups()); !reflect.DeepEqual(got, want) {
+		t.Errorf("unchanged: got groups %q, want %q", got, want)
+	}
+	if got := again.GetKeptSizes(); got != 3 {
+		t.Errorf("unchanged: kept %d sizes, want 3", got)
+	}
+	for _, name := range hashed {
+		if name == "c1" || name == "c2" {
+			t.Errorf("unchanged: hashed %s, which had no duplicates", name)
+		}
+	}
+
+	// A changed file resolves its group, a new one joins another, and the
+	// files of the sizes that didn't change keep their groups
+	writeFile(t, root, "a2", "AAAA", modTime.Add(time.Hour))
+	writeFile(t, root, "c2", "cccccccc", modTime.Add(time.Hour))
+	updated, _ := findDuplicates(t, root, seed)
+	want = [][]string{{"b1", "b2"}, {"c1", "c2"}}
+	if got := groupNames(updated.GetGroups()); !reflect.DeepEqual(got, want) {
+		t.Errorf("changed: got groups %q, want %q", got, want)
+	}
+	if got := updated.GetKeptSizes(); got != 1 {
+		t.Errorf("changed: kept %d sizes, want 1", got)
+	}
+}
+
+func TestSeedWithOtherSettings(t *testing.T) {
+	root := t.TempDir()
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	writeFile(t, root, "a1", "aaaa", modTime)
+	writeFile(t, root, "a2", "aaaa", modTime)
+
+	first, _ := findDuplicates(t, root, nil)
+	seed := &Seed{Fingerprints: first.GetFingerprints(), Groups: first.GetGroups()}
+
+	// Groups found with another keep policy are found again
+	s, err := scanner.NewScanner([]string{root}, "", false, scanner.ScanTypeContent, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngine(s, matcher.NewMatcher(matcher.MatchOptions{Type: matcher.MatchTypeExact}))
+	e.Seed = seed
+	e.Keep = KeepLongestPath
+	if _, err := e.FindDuplicates(); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.GetKeptSizes(); got != 0 {
+		t.Errorf("kept %d sizes, want 0", got)
+	}
+	if got := len(e.GetGroups()); got != 1 {
+		t.Errorf("got %d groups, want 1", got)
+	}
+}
@@ -1,19 +1,34 @@
 package fs
 
 import (
-	"io/fs"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strings"
+
+	"github.com/tendant/dupe-cli/internal/glob"
 )
 
 // Directory represents a directory in the filesystem
 type Directory struct {
-	Path        string       // Full path to the directory
-	Name        string       // Directory name without path
-	IsReference bool         // Whether this is a reference directory
-	ExcludePattern *regexp.Regexp // Pattern to exclude files
+	Path           string          // Full path to the directory
+	Name           string          // Directory name without path
+	IsReference    bool            // Whether this is a reference directory
+	ExcludePattern *glob.Glob      // Patterns of the names of files to exclude
+	OnFile         func(*File)     // Called for each file found by ScanFiles (may be nil)
+	Strict         bool            // Whether to abort ScanFiles on the first error
+	Errors         []*PathError    // Errors for paths that were skipped by ScanFiles
+	Walked         map[string]bool // Directories whose subtree was walked before and is skipped by ScanFiles
+	Filters        []FileFilter    // Filters a file must pass to be found by ScanFiles
+	DirFilters     []DirFilter     // Filters a subdirectory must pass to be walked by ScanFiles
+	Symlinks       SymlinkPolicy   // What ScanFiles does with symbolic links (skips them if empty)
+	Skipped        []*SkippedPath  // Paths that were skipped by ScanFiles and are reported
+	Readers        int             // Number of directories ScanFiles reads at the same time (1 if 0)
+	MaxDepth       int             // Levels of subdirectories ScanFiles walks when recursive (no limit if 0)
+	OneFileSystem  bool            // Whether ScanFiles skips directories on other filesystems than the directory
+
+	// OnDirDone is called by ScanFiles when a directory and its whole subtree
+	// have been walked, with the files, errors and reported skipped paths
+	// found directly in it (may be nil)
+	OnDirDone func(dir string, files []*File, errs []*PathError, skipped []*SkippedPath)
 }
 
 // NewDirectory creates a new Directory instance from a directory path
@@ -35,97 +50,45 @@ func NewDirectory(path string) (*Directory, error) {
 	return dir, nil
 }
 
-// SetExcludePattern sets the pattern to exclude files
+// SetExcludePattern sets the comma-separated glob patterns of the names of
+// files to exclude
 func (d *Directory) SetExcludePattern(pattern string) error {
-	if pattern == "" {
+	patterns := glob.Split(pattern)
+	if len(patterns) == 0 {
 		d.ExcludePattern = nil
 		return nil
 	}
 
-	// Convert glob patterns to regex
-	regexStr := strings.Replace(pattern, ".", "\\.", -1)
-	regexStr = strings.Replace(regexStr, "*", ".*", -1)
-	regexStr = strings.Replace(regexStr, "?", ".", -1)
-	regexStr = "^(" + strings.Replace(regexStr, ",", "|", -1) + ")$"
-	
-	regex, err := regexp.Compile(regexStr)
+	g, err := glob.Compile(patterns...)
 	if err != nil {
 		return err
 	}
-	
-	d.ExcludePattern = regex
+
+	d.ExcludePattern = g
 	return nil
 }
 
-// ScanFiles scans the directory for files and returns them
+// ScanFiles scans the directory for files and returns them, in the order
+// of a depth-first walk with entries sorted by name, whatever Readers is.
+// Paths that cannot be read are recorded in Errors and skipped,
+// unless Strict is set, in which case the first error is returned.
+// Symbolic links are handled according to Symlinks; when they are followed,
+// directories already walked are not walked again, which also breaks cycles.
 func (d *Directory) ScanFiles(recursive bool) ([]*File, error) {
-	var files []*File
-
-	err := filepath.WalkDir(d.Path, func(path string, entry fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Skip directories unless we're at the root
-		if entry.IsDir() {
-			if path != d.Path && !recursive {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		// Check if file matches exclude pattern
-		if d.ExcludePattern != nil && d.ExcludePattern.MatchString(entry.Name()) {
-			return nil
-		}
-
-		// Get file info
-		info, err := entry.Info()
-		if err != nil {
-			return nil
-		}
-
-		// Create file object
-		file := NewFileFromFileInfo(path, info)
-		file.IsReference = d.IsReference
-
-		// Add file to collection
-		files = append(files, file)
-
-		return nil
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	return files, nil
+	d.Errors = nil
+	d.Skipped = nil
+	return newWalker(d, recursive, d.Readers).walk()
 }
 
-// GetSubdirectories returns a list of subdirectories
-func (d *Directory) GetSubdirectories() ([]*Directory, error) {
-	var dirs []*Directory
-
-	entries, err := os.ReadDir(d.Path)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
-
-		subPath := filepath.Join(d.Path, entry.Name())
-		subDir, err := NewDirectory(subPath)
-		if err != nil {
-			continue
-		}
-
-		subDir.IsReference = d.IsReference
-		subDir.ExcludePattern = d.ExcludePattern
-		dirs = append(dirs, subDir)
+// handleError records an error for a path found in the directory of frame,
+// returning it only in strict mode
+func (d *Directory) handleError(frame *walkFrame, path string, err error) error {
+	if d.Strict {
+		return err
 	}
 
-	return dirs, nil
+	pathErr := NewPathError(path, err)
+	d.Errors = append(d.Errors, pathErr)
+	frame.errors = append(frame.errors, pathErr)
+	return nil
 }
@@ -0,0 +1,85 @@
+package fs
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+)
+
+// ErrorCategory classifies errors that occur for a single path during a scan
+type ErrorCategory int
+
+const (
+	// ErrorIO is a read or other I/O error
+	ErrorIO ErrorCategory = iota
+	// ErrorPermission is a path that could not be accessed due to permissions
+	ErrorPermission
+	// ErrorVanished is a path that was removed while the scan was running
+	ErrorVanished
+	// ErrorPathTooLong is a path that exceeds the system's path length limit
+	ErrorPathTooLong
+)
+
+// String returns the name of the error category
+func (c ErrorCategory) String() string {
+	switch c {
+	case ErrorPermission:
+		return "permission"
+	case ErrorVanished:
+		return "vanished"
+	case ErrorPathTooLong:
+		return "too-long-path"
+	default:
+		return "io"
+	}
+}
+
+// ParseErrorCategory returns the category with the given name
+func ParseErrorCategory(name string) ErrorCategory {
+	for _, c := range []ErrorCategory{ErrorPermission, ErrorVanished, ErrorPathTooLong} {
+		if c.String() == name {
+			return c
+		}
+	}
+	return ErrorIO
+}
+
+// PathError is an error for a single path that did not abort the scan
+type PathError struct {
+	Path     string        // Path the error occurred for
+	Category ErrorCategory // Category of the error
+	Err      error         // Underlying error
+}
+
+// NewPathError creates a new PathError, classifying err
+func NewPathError(path string, err error) *PathError {
+	return &PathError{
+		Path:     path,
+		Category: CategorizeError(err),
+		Err:      err,
+	}
+}
+
+// Error returns the error message
+func (e *PathError) Error() string {
+	return e.Category.String() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// CategorizeError returns the category of an error
+func CategorizeError(err error) ErrorCategory {
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return ErrorPermission
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrorVanished
+	case errors.Is(err, syscall.ENAMETOOLONG):
+		return ErrorPathTooLong
+	default:
+		return ErrorIO
+	}
+}
@@ -0,0 +1,59 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestCategorizeError(t *testing.T) {
+	pathErr := func(err error) error { return &os.PathError{Op: "open", Path: "/a", Err: err} }
+	tests := []struct {
+		name string
+		err  error
+		want ErrorCategory
+	}{
+		{"EACCES", pathErr(syscall.EACCES), ErrorPermission},
+		{"EPERM", pathErr(syscall.EPERM), ErrorPermission},
+		{"ENOENT", pathErr(syscall.ENOENT), ErrorVanished},
+		{"ENAMETOOLONG", pathErr(syscall.ENAMETOOLONG), ErrorPathTooLong},
+		{"EIO", pathErr(syscall.EIO), ErrorIO},
+		{"ELOOP", pathErr(syscall.ELOOP), ErrorIO},
+		{"wrapped", fmt.Errorf("reading: %w", pathErr(syscall.EACCES)), ErrorPermission},
+		{"generic", errors.New("checksum mismatch"), ErrorIO},
+	}
+	for _, tt := range tests {
+		if got := CategorizeError(tt.err); got != tt.want {
+			t.Errorf("%s: CategorizeError(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+
+	// Errors of real calls
+	dir := t.TempDir()
+	if _, err := os.Lstat(filepath.Join(dir, "missing")); CategorizeError(err) != ErrorVanished {
+		t.Errorf("missing file: got %v for %v, want %v", CategorizeError(err), err, ErrorVanished)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, strings.Repeat("n", 300))); CategorizeError(err) != ErrorPathTooLong {
+		t.Errorf("long name: got %v for %v, want %v", CategorizeError(err), err, ErrorPathTooLong)
+	}
+}
+
+func TestErrorCategoryNames(t *testing.T) {
+	for _, c := range []ErrorCategory{ErrorIO, ErrorPermission, ErrorVanished, ErrorPathTooLong} {
+		if got := ParseErrorCategory(c.String()); got != c {
+			t.Errorf("ParseErrorCategory(%q) = %v, want %v", c.String(), got, c)
+		}
+	}
+	if got := ParseErrorCategory("unknown"); got != ErrorIO {
+		t.Errorf("ParseErrorCategory of an unknown name = %v, want %v", got, ErrorIO)
+	}
+
+	err := NewPathError("/a", &os.PathError{Op: "open", Path: "/a", Err: syscall.EACCES})
+	if err.Category != ErrorPermission || !errors.Is(err, syscall.EACCES) {
+		t.Errorf("NewPathError = %+v, want a permission error wrapping EACCES", err)
+	}
+}
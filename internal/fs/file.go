@@ -24,6 +24,9 @@ type File struct {
 	DigestPart  []byte    // Partial file hash for large files (calculated on demand)
 	Words       []string  // Words extracted from filename for fuzzy matching
 	IsReference bool      // Whether this file is in a reference directory (shouldn't be deleted)
+	Dev         uint64    // Device the file is on (0 if unknown)
+	Inode       uint64    // Inode number of the file (0 if unknown)
+	Symlink     bool      // Whether the file was found through a symbolic link
 }
 
 // NewFile creates a new File instance from a file path
@@ -43,18 +46,21 @@ func NewFile(path string) (*File, error) {
 		Size:    info.Size(),
 		ModTime: info.ModTime(),
 	}
+	file.Dev, file.Inode = fileID(info)
 
 	return file, nil
 }
 
 // NewFileFromFileInfo creates a new File instance from os.FileInfo
 func NewFileFromFileInfo(path string, info os.FileInfo) *File {
-	return &File{
+	file := &File{
 		Path:    path,
 		Name:    info.Name(),
 		Size:    info.Size(),
 		ModTime: info.ModTime(),
 	}
+	file.Dev, file.Inode = fileID(info)
+	return file
 }
 
 // GetDigest returns the file's digest, calculating it if necessary
@@ -159,3 +165,8 @@ func extractWords(filename string) []string {
 
 	return words
 }
+
+// SameInode reports whether two files are hard links to the same inode
+func (f *File) SameInode(other *File) bool {
+	return f.Inode != 0 && f.Inode == other.Inode && f.Dev == other.Dev
+}
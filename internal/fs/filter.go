@@ -0,0 +1,94 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/glob"
+)
+
+// FileFilter reports whether a file found by a walk is kept
+type FileFilter func(path string, info os.FileInfo) bool
+
+// DirFilter reports whether a walk descends into a directory
+type DirFilter func(path string) bool
+
+// SizeFilter keeps files of at least min and, if max is not 0, at most max bytes
+func SizeFilter(min, max int64) FileFilter {
+	return func(_ string, info os.FileInfo) bool {
+		size := info.Size()
+		return size >= min && (max == 0 || size <= max)
+	}
+}
+
+// ModTimeFilter keeps files modified after newer and before older. A zero
+// time leaves that side of the range open.
+func ModTimeFilter(newer, older time.Time) FileFilter {
+	return func(_ string, info os.FileInfo) bool {
+		modTime := info.ModTime()
+		if !newer.IsZero() && !modTime.After(newer) {
+			return false
+		}
+		return older.IsZero() || modTime.Before(older)
+	}
+}
+
+// ExtensionFilter keeps files with one of the extensions, given with or
+// without the leading dot and compared without case
+func ExtensionFilter(exts []string) FileFilter {
+	wanted := make(map[string]bool, len(exts))
+	for _, ext := range exts {
+		wanted[strings.ToLower(strings.TrimPrefix(ext, "."))] = true
+	}
+	return func(path string, _ os.FileInfo) bool {
+		ext := strings.TrimPrefix(filepath.Ext(path), ".")
+		return ext != "" && wanted[strings.ToLower(ext)]
+	}
+}
+
+// IncludeFilter keeps files matching one of the glob patterns. Patterns
+// without a path separator match the file name, others the full path.
+func IncludeFilter(patterns []string) (FileFilter, error) {
+	g, err := glob.CompilePaths(patterns...)
+	if err != nil {
+		return nil, err
+	}
+	return func(path string, _ os.FileInfo) bool {
+		return g.Match(path)
+	}, nil
+}
+
+// ExcludeDirFilter skips directories matching one of the glob patterns.
+// Patterns without a path separator match the directory name, others the
+// full path.
+func ExcludeDirFilter(patterns []string) (DirFilter, error) {
+	g, err := glob.CompilePaths(patterns...)
+	if err != nil {
+		return nil, err
+	}
+	return func(path string) bool {
+		return !g.Match(path)
+	}, nil
+}
+
+// KeepFile reports whether a file passes all filters
+func KeepFile(filters []FileFilter, path string, info os.FileInfo) bool {
+	for _, filter := range filters {
+		if !filter(path, info) {
+			return false
+		}
+	}
+	return true
+}
+
+// descend reports whether a directory passes all filters
+func descend(filters []DirFilter, path string) bool {
+	for _, filter := range filters {
+		if !filter(path) {
+			return false
+		}
+	}
+	return true
+}
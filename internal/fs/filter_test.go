@@ -0,0 +1,138 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fileInfo is the FileInfo of a regular file with a size and modification time
+type fileInfo struct {
+	size    int64
+	modTime time.Time
+}
+
+func (fi fileInfo) Name() string       { return "file" }
+func (fi fileInfo) Size() int64        { return fi.size }
+func (fi fileInfo) Mode() os.FileMode  { return 0644 }
+func (fi fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi fileInfo) IsDir() bool        { return false }
+func (fi fileInfo) Sys() interface{}   { return nil }
+
+func TestSizeFilter(t *testing.T) {
+	tests := []struct {
+		min, max int64
+		size     int64
+		want     bool
+	}{
+		{0, 0, 0, true},
+		{1, 0, 0, false},
+		{1, 0, 1 << 40, true},
+		{10, 20, 10, true},
+		{10, 20, 20, true},
+		{10, 20, 21, false},
+		{0, 20, 9, true},
+	}
+	for _, tt := range tests {
+		if got := SizeFilter(tt.min, tt.max)("f", fileInfo{size: tt.size}); got != tt.want {
+			t.Errorf("SizeFilter(%d, %d) of size %d = %v, want %v", tt.min, tt.max, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestModTimeFilter(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		newer, older time.Time
+		modTime      time.Time
+		want         bool
+	}{
+		{time.Time{}, time.Time{}, day(1), true},
+		{day(10), time.Time{}, day(11), true},
+		{day(10), time.Time{}, day(10), false},
+		{time.Time{}, day(10), day(9), true},
+		{time.Time{}, day(10), day(10), false},
+		{day(10), day(20), day(15), true},
+		{day(10), day(20), day(25), false},
+	}
+	for _, tt := range tests {
+		if got := ModTimeFilter(tt.newer, tt.older)("f", fileInfo{modTime: tt.modTime}); got != tt.want {
+			t.Errorf("ModTimeFilter(%v, %v) of %v = %v, want %v", tt.newer, tt.older, tt.modTime, got, tt.want)
+		}
+	}
+}
+
+func TestExtensionFilter(t *testing.T) {
+	filter := ExtensionFilter([]string{".jpg", "PNG", "tar.gz"})
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/a/photo.jpg", true},
+		{"/a/photo.JPG", true},
+		{"/a/photo.png", true},
+		{"/a/photo.jpeg", false},
+		{"/a/jpg", false},
+		{"/a/.jpg", true},
+		{"/a/archive.tar.gz", false},
+		{"/a.jpg/file", false},
+	}
+	for _, tt := range tests {
+		if got := filter(tt.path, fileInfo{}); got != tt.want {
+			t.Errorf("ExtensionFilter of %s = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestIncludeFilter(t *testing.T) {
+	filter, err := IncludeFilter([]string{"*.txt", "/data/keep/*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/data/a.txt", true},
+		{"/data/sub/b.txt", true},
+		{"/data/keep/c.bin", true},
+		{"/data/keep/sub/c.bin", false},
+		{"/data/c.bin", false},
+	}
+	for _, tt := range tests {
+		if got := filter(tt.path, fileInfo{}); got != tt.want {
+			t.Errorf("IncludeFilter of %s = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	if _, err := IncludeFilter([]string{"[abc"}); err == nil {
+		t.Error("IncludeFilter of an invalid pattern succeeded")
+	}
+}
+
+func TestScanFilesFilters(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "a.txt", "b.bin", "node_modules/c.txt", "src/d.txt", "src/cache/e.txt", "src/big.txt")
+	if err := os.WriteFile(filepath.Join(root, "src", "big.txt"), make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	excludeDirs, err := ExcludeDirFilter([]string{"node_modules", filepath.Join(root, "src", "cache")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := scanPaths(t, root, func(d *Directory) {
+		d.Filters = []FileFilter{ExtensionFilter([]string{"txt"}), SizeFilter(0, 50)}
+		d.DirFilters = []DirFilter{excludeDirs}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Filters apply in subdirectories, and excluded directories aren't walked
+	if want := []string{"a.txt", "src/d.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("files = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,58 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsWithin reports whether path is dir or a path inside dir. Paths are
+// compared as they are, except that every relative path that doesn't lead
+// out with ".." is inside ".".
+func IsWithin(path, dir string) bool {
+	if dir == "." {
+		return !filepath.IsAbs(path) && path != ".." && !strings.HasPrefix(path, ".."+string(os.PathSeparator))
+	}
+	if !strings.HasPrefix(path, dir) {
+		return false
+	}
+	if len(path) == len(dir) || strings.HasSuffix(dir, string(os.PathSeparator)) {
+		return true
+	}
+	return path[len(dir)] == os.PathSeparator
+}
+
+// ComparePaths compares two paths element by element, which orders them
+// the way a depth-first walk with sorted directory entries visits them.
+// It returns -1 if a sorts before b, 1 if it sorts after and 0 if they are equal.
+func ComparePaths(a, b string) int {
+	for a != "" && b != "" {
+		var elemA, elemB string
+		elemA, a = splitFirst(a)
+		elemB, b = splitFirst(b)
+		if elemA != elemB {
+			if elemA < elemB {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return -1
+	default:
+		return 1
+	}
+}
+
+// splitFirst splits a path into its first element and the rest
+func splitFirst(path string) (string, string) {
+	i := strings.IndexByte(path, os.PathSeparator)
+	if i < 0 {
+		return path, ""
+	}
+	return path[:i], path[i+1:]
+}
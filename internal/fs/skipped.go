@@ -0,0 +1,37 @@
+package fs
+
+import "os"
+
+// SkippedPath is a path a walk found but did not scan, because it is not a
+// regular file
+type SkippedPath struct {
+	Path   string // Path that was skipped
+	Type   string // Type of the path: "symlink", "fifo", "socket", "device", "char-device" or "irregular"
+	Target string // Target of a symbolic link
+}
+
+// fileType returns the type name of a path that is not a regular file or a directory
+func fileType(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeSymlink != 0:
+		return "symlink"
+	case mode&os.ModeNamedPipe != 0:
+		return "fifo"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeCharDevice != 0:
+		return "char-device"
+	case mode&os.ModeDevice != 0:
+		return "device"
+	default:
+		return "irregular"
+	}
+}
+
+// NewSkippedPath creates the SkippedPath of a path that is not a regular file
+func NewSkippedPath(path string, info os.FileInfo) *SkippedPath {
+	if info.Mode()&os.ModeSymlink != 0 {
+		return newSkippedLink(path)
+	}
+	return &SkippedPath{Path: path, Type: fileType(info.Mode())}
+}
@@ -0,0 +1,11 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+
+package fs
+
+import "os"
+
+// fileID returns the device and inode numbers of a file, which are not
+// available on this platform
+func fileID(info os.FileInfo) (dev, inode uint64) {
+	return 0, 0
+}
@@ -0,0 +1,16 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package fs
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID returns the device and inode numbers of a file
+func fileID(info os.FileInfo) (dev, inode uint64) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Dev), uint64(st.Ino)
+	}
+	return 0, 0
+}
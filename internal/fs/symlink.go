@@ -0,0 +1,38 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SymlinkPolicy decides what a walk does with symbolic links
+type SymlinkPolicy string
+
+const (
+	SymlinksSkip   SymlinkPolicy = "skip"   // Ignore symbolic links
+	SymlinksFollow SymlinkPolicy = "follow" // Scan the files and walk the directories links point to
+	SymlinksReport SymlinkPolicy = "report" // Ignore symbolic links, but report them as skipped
+)
+
+// SymlinkPolicies are the names of the symlink policies
+var SymlinkPolicies = []string{string(SymlinksSkip), string(SymlinksFollow), string(SymlinksReport)}
+
+// newSkippedLink creates the SkippedPath of a symbolic link
+func newSkippedLink(path string) *SkippedPath {
+	target, _ := os.Readlink(path)
+	return &SkippedPath{Path: path, Type: fileType(os.ModeSymlink), Target: target}
+}
+
+// dirKey identifies a directory for the cycle detection of followed links:
+// by its device and inode numbers where they are known, by its path with
+// links resolved elsewhere
+func dirKey(path string, info os.FileInfo) string {
+	if dev, inode := fileID(info); inode != 0 {
+		return fmt.Sprintf("%d:%d", dev, inode)
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return path
+}
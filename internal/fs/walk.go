@@ -0,0 +1,384 @@
+package fs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// prefetchPerReader is the number of directories each reader may read ahead
+// of the walk, which bounds the entries held in memory before they're visited
+const prefetchPerReader = 16
+
+// walkDir is a directory of a walk. It is read once, by a prefetching worker
+// or by the walk itself, whichever gets to it first.
+type walkDir struct {
+	path    string
+	depth   int    // Number of directories between the root and the directory, the root being 0
+	key     string // Identity of the directory for cycle detection, when following links
+	viaLink bool   // Whether the directory was reached through a symbolic link
+
+	state      int32         // 0 until a reader claims the directory
+	prefetched bool          // Whether a prefetching worker read the directory, and it wasn't visited yet
+	done       chan struct{} // Closed when the directory has been read
+	err        error         // Error reading the directory
+	entries    []walkEntry   // Entries of the directory, in name order
+}
+
+// walkEntry is the outcome of an entry of a directory: a file to scan, a
+// subdirectory to walk, a path to report as skipped, or an error. Entries
+// that are left out have none of them.
+type walkEntry struct {
+	file    *File
+	dir     *walkDir
+	link    bool // Whether dir is a followed link, walked only if its target wasn't
+	skipped *SkippedPath
+	path    string // Path of err
+	err     error
+}
+
+// walkFrame holds the files, errors and skipped paths found directly in a
+// directory being walked
+type walkFrame struct {
+	path    string
+	files   []*File
+	errors  []*PathError
+	skipped []*SkippedPath
+}
+
+// walker walks a directory tree depth-first in name order, with
+// directories read ahead by concurrent workers. Everything the walk finds
+// is handled by the goroutine that called walk, in the same order as a
+// sequential walk, so the result doesn't depend on the concurrency.
+type walker struct {
+	d         *Directory
+	recursive bool
+	follow    bool
+	sem       chan struct{}   // Limits the number of directories read at the same time
+	visited   map[string]bool // Directories walked, when following links
+	rootDev   uint64          // Device of the root, when staying on its filesystem
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []*walkDir // Directories to read ahead, the last one first
+	ahead  int        // Directories read ahead and not visited yet
+	limit  int        // Maximum of ahead, and of the length of queue
+	closed bool
+
+	files []*File
+}
+
+// newWalker creates a walker of d that reads up to readers directories at
+// the same time
+func newWalker(d *Directory, recursive bool, readers int) *walker {
+	if readers < 1 {
+		readers = 1
+	}
+	w := &walker{
+		d:         d,
+		recursive: recursive,
+		follow:    d.Symlinks == SymlinksFollow,
+		sem:       make(chan struct{}, readers),
+		visited:   make(map[string]bool),
+		limit:     readers * prefetchPerReader,
+	}
+	w.cond = sync.NewCond(&w.mu)
+	return w
+}
+
+// walk walks the tree and returns the files found
+func (w *walker) walk() ([]*File, error) {
+	root := &walkDir{path: w.d.Path, done: make(chan struct{})}
+	if w.d.Walked[root.path] {
+		return nil, nil
+	}
+	if info, err := os.Stat(root.path); err == nil {
+		w.rootDev, _ = fileID(info)
+		if w.follow {
+			root.key = dirKey(root.path, info)
+		}
+	}
+
+	// The walk itself is one of the readers
+	var wg sync.WaitGroup
+	for i := 1; i < cap(w.sem); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.prefetch()
+		}()
+	}
+	defer func() {
+		w.mu.Lock()
+		w.closed = true
+		w.mu.Unlock()
+		w.cond.Broadcast()
+		wg.Wait()
+	}()
+
+	if err := w.visit(root); err != nil {
+		return nil, err
+	}
+	return w.files, nil
+}
+
+// prefetch reads the queued directories until the walk is done. It waits
+// while the walk is the limit of directories behind.
+func (w *walker) prefetch() {
+	for {
+		w.mu.Lock()
+		for (len(w.queue) == 0 || w.ahead >= w.limit) && !w.closed {
+			w.cond.Wait()
+		}
+		if w.closed {
+			w.mu.Unlock()
+			return
+		}
+		dir := w.queue[len(w.queue)-1]
+		w.queue = w.queue[:len(w.queue)-1]
+		claimed := w.claim(dir)
+		if claimed {
+			dir.prefetched = true
+			w.ahead++
+		}
+		w.mu.Unlock()
+
+		if claimed {
+			w.read(dir)
+		}
+	}
+}
+
+// schedule queues directories to be read ahead, so that the first one is
+// read first. Beyond the limit, the directories queued first, which the walk
+// reaches last, are dropped: the walk reads them itself if no worker does.
+func (w *walker) schedule(dirs []*walkDir) {
+	if len(dirs) == 0 || cap(w.sem) == 1 {
+		return
+	}
+	w.mu.Lock()
+	for i := len(dirs) - 1; i >= 0; i-- {
+		w.queue = append(w.queue, dirs[i])
+	}
+	if drop := len(w.queue) - w.limit; drop > 0 {
+		n := copy(w.queue, w.queue[drop:])
+		for i := n; i < len(w.queue); i++ {
+			w.queue[i] = nil
+		}
+		w.queue = w.queue[:n]
+	}
+	w.mu.Unlock()
+	w.cond.Broadcast()
+}
+
+// release counts a directory read ahead as visited, or skipped, so that the
+// workers can read further
+func (w *walker) release(dir *walkDir) {
+	w.mu.Lock()
+	released := dir.prefetched
+	if released {
+		dir.prefetched = false
+		w.ahead--
+	}
+	w.mu.Unlock()
+	if released {
+		w.cond.Broadcast()
+	}
+}
+
+// claim reports whether the caller is the first to claim a directory, and
+// so has to read it
+func (w *walker) claim(dir *walkDir) bool {
+	return atomic.CompareAndSwapInt32(&dir.state, 0, 1)
+}
+
+// read reads a directory claimed by the caller
+func (w *walker) read(dir *walkDir) {
+	w.sem <- struct{}{}
+	entries, err := os.ReadDir(dir.path)
+	dir.err = err
+	var subdirs []*walkDir
+	for _, entry := range entries {
+		e := w.entry(dir, entry)
+		if e.dir != nil && !e.link {
+			subdirs = append(subdirs, e.dir)
+		}
+		dir.entries = append(dir.entries, e)
+	}
+	<-w.sem
+
+	close(dir.done)
+	w.schedule(subdirs)
+}
+
+// entry returns the outcome of an entry of a directory
+func (w *walker) entry(parent *walkDir, entry fs.DirEntry) walkEntry {
+	d := w.d
+	path := filepath.Join(parent.path, entry.Name())
+
+	if entry.IsDir() {
+		if !w.descend(parent, path) {
+			return walkEntry{}
+		}
+		sub := &walkDir{path: path, depth: parent.depth + 1, viaLink: parent.viaLink, done: make(chan struct{})}
+		if w.follow || d.OneFileSystem {
+			info, err := entry.Info()
+			if err != nil {
+				return walkEntry{path: path, err: err}
+			}
+			if !w.sameFileSystem(info) {
+				return walkEntry{}
+			}
+			sub.key = dirKey(path, info)
+		}
+		return walkEntry{dir: sub}
+	}
+
+	// Check if file matches exclude pattern
+	if d.ExcludePattern != nil && d.ExcludePattern.Match(entry.Name()) {
+		return walkEntry{}
+	}
+
+	// Symbolic links are skipped, reported or resolved to their target
+	var info os.FileInfo
+	var err error
+	viaLink := parent.viaLink
+	if entry.Type()&fs.ModeSymlink != 0 {
+		switch {
+		case d.Symlinks == SymlinksReport:
+			info, err := entry.Info()
+			if err != nil {
+				return walkEntry{path: path, err: err}
+			}
+			return w.skip(path, info, newSkippedLink(path))
+		case !w.follow:
+			return walkEntry{}
+		}
+
+		info, err = os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return walkEntry{} // Dangling link
+			}
+			return walkEntry{path: path, err: err}
+		}
+		if info.IsDir() {
+			if !w.descend(parent, path) || !w.sameFileSystem(info) {
+				return walkEntry{}
+			}
+			sub := &walkDir{path: path, depth: parent.depth + 1, key: dirKey(path, info), viaLink: true, done: make(chan struct{})}
+			return walkEntry{dir: sub, link: true}
+		}
+		viaLink = true
+	} else {
+		info, err = entry.Info()
+		if err != nil {
+			return walkEntry{path: path, err: err}
+		}
+	}
+
+	// FIFOs, sockets and devices are never scanned: reading a FIFO
+	// blocks, and devices don't have contents to compare
+	if !info.Mode().IsRegular() {
+		return w.skip(path, info, NewSkippedPath(path, info))
+	}
+	if !KeepFile(d.Filters, path, info) {
+		return walkEntry{}
+	}
+
+	// Create file object
+	file := NewFileFromFileInfo(path, info)
+	file.IsReference = d.IsReference
+	file.Symlink = viaLink
+	return walkEntry{file: file}
+}
+
+// descend reports whether the walk descends into a subdirectory of parent
+func (w *walker) descend(parent *walkDir, path string) bool {
+	d := w.d
+	if !w.recursive || (d.MaxDepth > 0 && parent.depth >= d.MaxDepth) {
+		return false
+	}
+	return !d.Walked[path] && descend(d.DirFilters, path)
+}
+
+// sameFileSystem reports whether a directory is on the filesystem of the
+// root, or the walk doesn't stay on it
+func (w *walker) sameFileSystem(info os.FileInfo) bool {
+	if !w.d.OneFileSystem {
+		return true
+	}
+	dev, _ := fileID(info)
+	return dev == w.rootDev
+}
+
+// skip returns the entry of a path that is skipped because it is not a
+// regular file, reported unless the filters leave it out anyway
+func (w *walker) skip(path string, info os.FileInfo, skipped *SkippedPath) walkEntry {
+	if !KeepFile(w.d.Filters, path, info) {
+		return walkEntry{}
+	}
+	return walkEntry{skipped: skipped}
+}
+
+// visit walks a directory and its subtree. It calls OnDirDone when it's done.
+func (w *walker) visit(dir *walkDir) error {
+	d := w.d
+	if w.claim(dir) {
+		w.read(dir)
+	} else {
+		<-dir.done
+		w.release(dir)
+	}
+	if w.follow {
+		w.visited[dir.key] = true
+	}
+
+	frame := &walkFrame{path: dir.path}
+	if dir.err != nil {
+		if err := d.handleError(frame, dir.path, dir.err); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range dir.entries {
+		switch {
+		case e.err != nil:
+			if err := d.handleError(frame, e.path, e.err); err != nil {
+				return err
+			}
+
+		case e.skipped != nil:
+			d.Skipped = append(d.Skipped, e.skipped)
+			frame.skipped = append(frame.skipped, e.skipped)
+
+		case e.file != nil:
+			w.files = append(w.files, e.file)
+			frame.files = append(frame.files, e.file)
+			if d.OnFile != nil {
+				d.OnFile(e.file)
+			}
+
+		case e.dir != nil:
+			// Directories that were walked already, through a link or
+			// not, are skipped, which also breaks cycles
+			if w.follow && w.visited[e.dir.key] {
+				w.release(e.dir)
+				continue
+			}
+			if err := w.visit(e.dir); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The entries are not needed anymore, the files are in the result
+	dir.entries = nil
+
+	if d.OnDirDone != nil {
+		d.OnDirDone(frame.path, frame.files, frame.errors, frame.skipped)
+	}
+	return nil
+}
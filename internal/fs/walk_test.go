@@ -0,0 +1,370 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// makeTree creates files and directories under root. Paths ending with a
+// slash are directories.
+func makeTree(t *testing.T, root string, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		full := filepath.Join(root, filepath.FromSlash(path))
+		if path[len(path)-1] == '/' {
+			if err := os.MkdirAll(full, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(path), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// symlink creates a symbolic link, skipping the test where links can't be created
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+}
+
+// scanPaths walks root and returns the paths of the files found, relative
+// to root, with the directory
+func scanPaths(t *testing.T, root string, setup func(d *Directory)) ([]string, *Directory, error) {
+	t.Helper()
+	d, err := NewDirectory(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if setup != nil {
+		setup(d)
+	}
+
+	files, err := d.ScanFiles(true)
+	var paths []string
+	for _, file := range files {
+		rel, relErr := filepath.Rel(root, file.Path)
+		if relErr != nil {
+			t.Fatal(relErr)
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	return paths, d, err
+}
+
+func TestScanFilesOrder(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "b.txt", "a/x", "a/y/z", "a.txt", "c/w", "c/empty/", "B")
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 5; j++ {
+			makeTree(t, root, filepath.ToSlash(filepath.Join("wide", string(rune('a'+i)), string(rune('a'+j)), "f")))
+		}
+	}
+
+	// Depth-first, with the entries of each directory sorted by name
+	want := []string{"B", "a/x", "a/y/z", "a.txt", "b.txt", "c/w"}
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 5; j++ {
+			want = append(want, "wide/"+string(rune('a'+i))+"/"+string(rune('a'+j))+"/f")
+		}
+	}
+
+	for _, readers := range []int{0, 1, 2, 4, 16, 64} {
+		for run := 0; run < 5; run++ {
+			var dirsDone []string
+			got, _, err := scanPaths(t, root, func(d *Directory) {
+				d.Readers = readers
+				d.OnDirDone = func(dir string, files []*File, errs []*PathError, skipped []*SkippedPath) {
+					dirsDone = append(dirsDone, dir)
+				}
+			})
+			if err != nil {
+				t.Fatalf("readers %d: %v", readers, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("readers %d: files = %q, want %q", readers, got, want)
+			}
+
+			// Directories are done after their subdirectories, the root last
+			if len(dirsDone) == 0 || dirsDone[len(dirsDone)-1] != root {
+				t.Fatalf("readers %d: directories done = %q, want the root last", readers, dirsDone)
+			}
+			if dirsDone[0] != filepath.Join(root, "a", "y") {
+				t.Fatalf("readers %d: first directory done = %q, want a/y", readers, dirsDone[0])
+			}
+		}
+	}
+}
+
+func TestScanFilesReadAhead(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "0")
+	for i := 0; i < 50; i++ {
+		makeTree(t, root, filepath.ToSlash(filepath.Join("d", string(rune('a'+i/26)), string(rune('a'+i%26)), "f")))
+	}
+	d, err := NewDirectory(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The walk waits on its first file, so that the workers read as far
+	// ahead as they may, then checks the bound at every file
+	w := newWalker(d, true, 4)
+	w.limit = 3
+	first := true
+	d.OnFile = func(file *File) {
+		if first {
+			first = false
+			time.Sleep(100 * time.Millisecond)
+		}
+		w.mu.Lock()
+		ahead, queued := w.ahead, len(w.queue)
+		w.mu.Unlock()
+		if ahead > w.limit || queued > w.limit {
+			t.Errorf("at %s: %d directories read ahead and %d queued, want at most %d", file.Path, ahead, queued, w.limit)
+		}
+	}
+
+	files, err := w.walk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 51 {
+		t.Errorf("found %d files, want 51", len(files))
+	}
+	if w.ahead != 0 {
+		t.Errorf("%d directories read ahead were never visited", w.ahead)
+	}
+}
+
+func TestScanFilesMaxDepth(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "f0", "a/f1", "a/b/f2", "a/b/c/f3")
+
+	tests := []struct {
+		maxDepth int
+		want     []string
+	}{
+		{0, []string{"a/b/c/f3", "a/b/f2", "a/f1", "f0"}},
+		{1, []string{"a/f1", "f0"}},
+		{2, []string{"a/b/f2", "a/f1", "f0"}},
+		{3, []string{"a/b/c/f3", "a/b/f2", "a/f1", "f0"}},
+	}
+
+	for _, tt := range tests {
+		got, _, err := scanPaths(t, root, func(d *Directory) { d.MaxDepth = tt.maxDepth })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("MaxDepth %d: files = %q, want %q", tt.maxDepth, got, tt.want)
+		}
+	}
+}
+
+func TestScanFilesSymlinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		tree    []string
+		links   map[string]string // Link path to target, relative to the root
+		policy  SymlinkPolicy
+		want    []string
+		skipped []string
+	}{
+		{
+			name:   "links are skipped",
+			tree:   []string{"d/f"},
+			links:  map[string]string{"d/file-link": "f", "dir-link": "d"},
+			policy: SymlinksSkip,
+			want:   []string{"d/f"},
+		},
+		{
+			name:    "links are reported",
+			tree:    []string{"d/f"},
+			links:   map[string]string{"d/file-link": "f", "dir-link": "d"},
+			policy:  SymlinksReport,
+			want:    []string{"d/f"},
+			skipped: []string{"d/file-link", "dir-link"},
+		},
+		{
+			name:   "link to a file is followed",
+			tree:   []string{"d/f"},
+			links:  map[string]string{"d/file-link": "f"},
+			policy: SymlinksFollow,
+			want:   []string{"d/f", "d/file-link"},
+		},
+		{
+			name:   "link to the root is a cycle",
+			tree:   []string{"d/f"},
+			links:  map[string]string{"d/loop": ".."},
+			policy: SymlinksFollow,
+			want:   []string{"d/f"},
+		},
+		{
+			name:   "link to its own directory is a cycle",
+			tree:   []string{"d/f"},
+			links:  map[string]string{"d/self": "."},
+			policy: SymlinksFollow,
+			want:   []string{"d/f"},
+		},
+		{
+			name:   "links to each other's directories are cycles",
+			tree:   []string{"d/f", "e/g"},
+			links:  map[string]string{"d/to-e": "../e", "e/to-d": "../d"},
+			policy: SymlinksFollow,
+			want:   []string{"d/f", "d/to-e/g"},
+		},
+		{
+			name:   "directory walked before is not walked again",
+			tree:   []string{"d/f", "e/g"},
+			links:  map[string]string{"z-link": "d"},
+			policy: SymlinksFollow,
+			want:   []string{"d/f", "e/g"},
+		},
+		{
+			name:   "directory walked through a link is not walked again",
+			tree:   []string{"d/f", "e/g"},
+			links:  map[string]string{"a-link": "e"},
+			policy: SymlinksFollow,
+			want:   []string{"a-link/g", "d/f"},
+		},
+		{
+			name:   "dangling link is left out",
+			tree:   []string{"d/f"},
+			links:  map[string]string{"d/dangling": "missing"},
+			policy: SymlinksFollow,
+			want:   []string{"d/f"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			makeTree(t, root, tt.tree...)
+			for link, target := range tt.links {
+				symlink(t, filepath.FromSlash(target), filepath.Join(root, filepath.FromSlash(link)))
+			}
+
+			got, d, err := scanPaths(t, root, func(d *Directory) { d.Symlinks = tt.policy })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("files = %q, want %q", got, tt.want)
+			}
+
+			var skipped []string
+			for _, s := range d.Skipped {
+				rel, _ := filepath.Rel(root, s.Path)
+				skipped = append(skipped, filepath.ToSlash(rel))
+			}
+			if !reflect.DeepEqual(skipped, tt.skipped) {
+				t.Errorf("skipped = %q, want %q", skipped, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestScanFilesErrors(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "a/f", "c/g")
+	// A link to itself cannot be resolved, and is an error rather than a dangling link
+	symlink(t, "b-loop", filepath.Join(root, "b-loop"))
+	loop := filepath.Join(root, "b-loop")
+
+	t.Run("errors are recorded and the walk goes on", func(t *testing.T) {
+		var dirErrs []string
+		got, d, err := scanPaths(t, root, func(d *Directory) {
+			d.Symlinks = SymlinksFollow
+			d.OnDirDone = func(dir string, files []*File, errs []*PathError, skipped []*SkippedPath) {
+				for _, e := range errs {
+					dirErrs = append(dirErrs, e.Path)
+				}
+			}
+		})
+		if err != nil {
+			t.Fatalf("ScanFiles: %v", err)
+		}
+		if want := []string{"a/f", "c/g"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("files = %q, want %q", got, want)
+		}
+		if len(d.Errors) != 1 || d.Errors[0].Path != loop {
+			t.Fatalf("errors = %v, want one for %s", d.Errors, loop)
+		}
+		if !reflect.DeepEqual(dirErrs, []string{loop}) {
+			t.Errorf("errors passed to OnDirDone = %q, want %q", dirErrs, []string{loop})
+		}
+	})
+
+	t.Run("strict walk stops at the first error", func(t *testing.T) {
+		for _, readers := range []int{1, 8} {
+			got, _, err := scanPaths(t, root, func(d *Directory) {
+				d.Symlinks = SymlinksFollow
+				d.Strict = true
+				d.Readers = readers
+			})
+			if err == nil {
+				t.Fatalf("readers %d: ScanFiles succeeded, want an error", readers)
+			}
+			if got != nil {
+				t.Errorf("readers %d: files = %q, want none", readers, got)
+			}
+		}
+	})
+
+	t.Run("missing root", func(t *testing.T) {
+		d := &Directory{Path: filepath.Join(root, "missing")}
+		if _, err := d.ScanFiles(true); err != nil {
+			t.Fatalf("ScanFiles: %v", err)
+		}
+		if len(d.Errors) != 1 || d.Errors[0].Category != ErrorVanished {
+			t.Errorf("errors = %v, want one vanished path", d.Errors)
+		}
+	})
+}
+
+func TestScanFilesOneFileSystem(t *testing.T) {
+	// /dev/pts is a mount point on Linux, on another filesystem than /dev
+	root, mount := "/dev", "/dev/pts"
+	rootInfo, err := os.Stat(root)
+	if err != nil {
+		t.Skipf("no %s: %v", root, err)
+	}
+	mountInfo, err := os.Stat(mount)
+	if err != nil {
+		t.Skipf("no %s: %v", mount, err)
+	}
+	rootDev, _ := fileID(rootInfo)
+	mountDev, _ := fileID(mountInfo)
+	if rootDev == mountDev {
+		t.Skipf("%s is on the filesystem of %s", mount, root)
+	}
+
+	for _, oneFileSystem := range []bool{false, true} {
+		walked := false
+		_, _, err := scanPaths(t, root, func(d *Directory) {
+			d.OneFileSystem = oneFileSystem
+			d.MaxDepth = 1
+			d.OnDirDone = func(dir string, files []*File, errs []*PathError, skipped []*SkippedPath) {
+				if dir == mount {
+					walked = true
+				}
+			}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if walked == oneFileSystem {
+			t.Errorf("OneFileSystem %v: walked %s = %v", oneFileSystem, mount, walked)
+		}
+	}
+}
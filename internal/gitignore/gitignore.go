@@ -0,0 +1,188 @@
+// Package gitignore excludes paths from scans with the rules of exclude
+// files, which have the syntax and semantics of .gitignore files
+package gitignore
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/tendant/dupe-cli/internal/fs"
+)
+
+// Names are the names of the ignore files honored in scanned directories
+var Names = []string{".gitignore", ".dupeignore"}
+
+// Matcher decides which paths under a set of root directories are excluded,
+// by the rules of exclude files and of the ignore files found in the
+// directories themselves. As with git, the last matching rule wins, rules
+// of ignore files deeper in the tree take precedence, and nothing inside an
+// excluded directory can be included again. A Matcher can be used by
+// concurrent walks: the ignore files of a directory are read without
+// holding up the other directories, and cached.
+type Matcher struct {
+	roots  []string
+	global []*pattern // Rules of the exclude files, anchored at each root
+	names  []string   // Names of the ignore files read from directories
+
+	dirRules sync.Map // Rules of the ignore files of each directory read so far ([]*pattern)
+	excluded sync.Map // Whether each directory checked so far is excluded (bool)
+}
+
+// New creates a matcher for the directories roots, with the rules of the
+// exclude files and of the ignore files called names in the directories
+func New(roots []string, excludeFiles []string, names []string) (*Matcher, error) {
+	m := &Matcher{names: names}
+	for _, root := range roots {
+		m.roots = append(m.roots, filepath.Clean(root))
+	}
+
+	for _, path := range excludeFiles {
+		rules, err := load(path, true)
+		if err != nil {
+			return nil, err
+		}
+		m.global = append(m.global, rules...)
+	}
+	return m, nil
+}
+
+// load reads the rules of an exclude file. With strict set, an invalid
+// pattern is an error; otherwise, like git, it is skipped.
+func load(path string, strict bool) ([]*pattern, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []*pattern
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		p, err := parsePattern(sc.Text())
+		if err != nil {
+			if strict {
+				return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+			}
+			continue
+		}
+		if p != nil {
+			rules = append(rules, p)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Excluded reports whether a path is excluded, either by a rule or because
+// a directory containing it is
+func (m *Matcher) Excluded(path string, isDir bool) bool {
+	path = filepath.Clean(path)
+	root := m.rootOf(path)
+	if root == path {
+		return false
+	}
+
+	dirs := ancestors(root, path)
+	for _, dir := range dirs[1:] {
+		excluded, ok := m.excluded.Load(dir)
+		if !ok {
+			excluded, _ = m.excluded.LoadOrStore(dir, m.match(root, dir, true))
+		}
+		if excluded.(bool) {
+			return true
+		}
+	}
+	return m.match(root, path, isDir)
+}
+
+// rootOf returns the root directory a path is in. Paths outside the roots
+// are matched as if their directory was a root.
+func (m *Matcher) rootOf(path string) string {
+	root := ""
+	for _, r := range m.roots {
+		if fs.IsWithin(path, r) && len(r) > len(root) {
+			root = r
+		}
+	}
+	if root == "" {
+		root = filepath.Dir(path)
+	}
+	return root
+}
+
+// ancestors returns root and the directories between it and path
+func ancestors(root, path string) []string {
+	var dirs []string
+	for dir := filepath.Dir(path); dir != root && fs.IsWithin(dir, root); dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+	}
+	dirs = append(dirs, root)
+	for i, j := 0, len(dirs)-1; i < j; i, j = i+1, j-1 {
+		dirs[i], dirs[j] = dirs[j], dirs[i]
+	}
+	return dirs
+}
+
+// match reports whether the last rule matching a path excludes it
+func (m *Matcher) match(root, path string, isDir bool) bool {
+	excluded := false
+	apply := func(rules []*pattern, base string) {
+		if len(rules) == 0 {
+			return
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return
+		}
+		elems := strings.Split(filepath.ToSlash(rel), "/")
+		for _, rule := range rules {
+			if rule.match(elems, isDir) {
+				excluded = !rule.negate
+			}
+		}
+	}
+
+	apply(m.global, root)
+	for _, dir := range ancestors(root, path) {
+		apply(m.rulesOf(dir), dir)
+	}
+	return excluded
+}
+
+// rulesOf returns the rules of the ignore files in a directory. Ignore
+// files that cannot be read are skipped. Walks reading the same directory
+// at the same time may both read its files, but share the first rules.
+func (m *Matcher) rulesOf(dir string) []*pattern {
+	if rules, ok := m.dirRules.Load(dir); ok {
+		return rules.([]*pattern)
+	}
+	var rules []*pattern
+	for _, name := range m.names {
+		fileRules, err := load(filepath.Join(dir, name), false)
+		if err == nil {
+			rules = append(rules, fileRules...)
+		}
+	}
+	stored, _ := m.dirRules.LoadOrStore(dir, rules)
+	return stored.([]*pattern)
+}
+
+// FileFilter returns a filter that skips the excluded files
+func (m *Matcher) FileFilter() fs.FileFilter {
+	return func(path string, info os.FileInfo) bool {
+		return !m.Excluded(path, info.IsDir())
+	}
+}
+
+// DirFilter returns a filter that prunes the excluded directories
+func (m *Matcher) DirFilter() fs.DirFilter {
+	return func(path string) bool {
+		return !m.Excluded(path, true)
+	}
+}
@@ -0,0 +1,249 @@
+package gitignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFile writes a file, creating its directory
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExcluded(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".gitignore"), `# Comment
+*.log
+!keep.log
+/build
+docs/*.tmp
+cache/
+**/gen/**
+\#hash
+{a,b}.txt
+[unclosed
+`)
+	writeFile(t, filepath.Join(root, "sub", ".gitignore"), `!*.log
+secret.txt
+`)
+
+	m, err := New([]string{root}, nil, Names)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		// Unanchored patterns match names at any depth
+		{"a.log", false, true},
+		{"x/y/a.log", false, true},
+		{"a.txt", false, false},
+
+		// Negation includes a path again, the last matching rule winning
+		{"keep.log", false, false},
+		{"x/keep.log", false, false},
+
+		// A leading slash anchors a pattern at the directory of its file
+		{"build", true, true},
+		{"sub/build", true, false},
+		{"build/a.txt", false, true},
+
+		// Nothing inside an excluded directory is included again
+		{"build/keep.log", false, true},
+
+		// A slash in the middle anchors a pattern too
+		{"docs/a.tmp", false, true},
+		{"docs/x/a.tmp", false, false},
+		{"sub/docs/a.tmp", false, false},
+
+		// A trailing slash only matches directories
+		{"cache", true, true},
+		{"cache", false, false},
+		{"sub/cache", true, true},
+		{"cache/a.txt", false, true},
+
+		// A trailing ** matches what is inside a directory, not the directory
+		{"gen", true, false},
+		{"gen/a.txt", false, true},
+		{"x/gen/a/b.txt", false, true},
+
+		// Rules of deeper ignore files take precedence
+		{"sub/a.log", false, false},
+		{"sub/x/a.log", false, false},
+		{"sub/secret.txt", false, true},
+		{"secret.txt", false, false},
+
+		// Escaped # is a pattern, not a comment
+		{"#hash", false, true},
+
+		// Braces are literal, unlike in shell globs
+		{"{a,b}.txt", false, true},
+		{"b.txt", false, false},
+
+		// A malformed pattern matches nothing, not even itself
+		{"[unclosed", false, false},
+
+		// The root itself is never excluded
+		{".", true, false},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(root, filepath.FromSlash(tt.path))
+		if got := m.Excluded(path, tt.isDir); got != tt.want {
+			t.Errorf("Excluded(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestExcludeFiles(t *testing.T) {
+	dir := t.TempDir()
+	excludeFile := filepath.Join(dir, "exclude")
+	writeFile(t, excludeFile, "/top.txt\n*.bak\n!important.bak\n")
+	rootA, rootB := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+
+	m, err := New([]string{rootA, rootB}, []string{excludeFile}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		// Rules of exclude files are anchored at each root
+		{"a/top.txt", true},
+		{"b/top.txt", true},
+		{"a/x/top.txt", false},
+		{"b/x/file.bak", true},
+		{"b/x/important.bak", false},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(dir, filepath.FromSlash(tt.path))
+		if got := m.Excluded(path, false); got != tt.want {
+			t.Errorf("Excluded(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParsePattern(t *testing.T) {
+	tests := []struct {
+		line     string
+		nilRule  bool
+		negate   bool
+		dirOnly  bool
+		anchored bool
+		wantErr  bool
+	}{
+		{line: "", nilRule: true},
+		{line: "   ", nilRule: true},
+		{line: "# comment", nilRule: true},
+		{line: "*.log"},
+		{line: "*.log  "},
+		{line: "!*.log", negate: true},
+		{line: `\!bang`},
+		{line: "dir/", dirOnly: true},
+		{line: "/dir", anchored: true},
+		{line: "a/b", anchored: true},
+		{line: "/", wantErr: true},
+		{line: "[abc"},
+		{line: "{a,b}.txt"},
+	}
+
+	for _, tt := range tests {
+		p, err := parsePattern(tt.line)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parsePattern(%q) succeeded, want an error", tt.line)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parsePattern(%q): %v", tt.line, err)
+			continue
+		}
+		if tt.nilRule {
+			if p != nil {
+				t.Errorf("parsePattern(%q) = %+v, want nil", tt.line, p)
+			}
+			continue
+		}
+		if p == nil || p.negate != tt.negate || p.dirOnly != tt.dirOnly || p.anchored != tt.anchored {
+			t.Errorf("parsePattern(%q) = %+v, want negate %v, dirOnly %v, anchored %v",
+				tt.line, p, tt.negate, tt.dirOnly, tt.anchored)
+		}
+	}
+}
+
+func TestExcludeFileWithMalformedPattern(t *testing.T) {
+	dir := t.TempDir()
+	excludeFile := filepath.Join(dir, "exclude")
+	writeFile(t, excludeFile, "[abc\n*.bak\n")
+
+	// Like git, a malformed pattern is not an error and matches nothing
+	m, err := New([]string{dir}, []string{excludeFile}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]bool{"[abc": false, "a": false, "x.bak": true} {
+		if got := m.Excluded(filepath.Join(dir, path), false); got != want {
+			t.Errorf("Excluded(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestWildcard(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.txt", "a.txt", true},
+		{"*.txt", ".txt", true},
+		{"*.txt", "a.txt.bak", false},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{"a**b", "axyb", true},
+		{"?", "é", true},
+		{"?", "", false},
+		{"[abc]", "b", true},
+		{"[abc]", "d", false},
+		{"[a-c]x", "bx", true},
+		{"[!a-c]", "b", false},
+		{"[^a-c]", "d", true},
+		{"[]a]", "]", true},
+		{"[a-]", "-", true},
+		{`[\]]`, "]", true},
+		{"[[:digit:]]*", "7up", true},
+		{"[[:digit:]]*", "up", false},
+		{"[[:upper:][:digit:]]", "Q", true},
+		{`\*`, "*", true},
+		{`\*`, "a", false},
+
+		// Braces are literal
+		{"{a,b}.txt", "{a,b}.txt", true},
+		{"{a,b}.txt", "a.txt", false},
+
+		// Malformed patterns match nothing
+		{"[abc", "[abc", false},
+		{"[abc", "a", false},
+		{"[[:nope:]]", "a", false},
+		{`abc\`, `abc\`, false},
+	}
+
+	for _, tt := range tests {
+		if got := newWildcard(tt.pattern).Match(tt.name); got != tt.want {
+			t.Errorf("%q matching %q = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
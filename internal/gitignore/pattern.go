@@ -0,0 +1,106 @@
+package gitignore
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pattern is a rule of an exclude file
+type pattern struct {
+	text     string      // Pattern as written, for errors
+	segments []*wildcard // Wildcard of each path element, nil for "**", which matches any number of elements
+	negate   bool        // Whether a match includes the path again
+	dirOnly  bool        // Whether the pattern only matches directories
+	anchored bool        // Whether the pattern matches paths from the base, rather than names at any depth
+}
+
+// parsePattern parses a line of an exclude file. It returns nil for blank
+// lines and comments.
+func parsePattern(line string) (*pattern, error) {
+	line = strings.TrimSuffix(line, "\r")
+	line = trimTrailingSpace(line)
+	if line == "" || line[0] == '#' {
+		return nil, nil
+	}
+
+	p := &pattern{text: line}
+	if line[0] == '!' {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		p.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return nil, fmt.Errorf("invalid pattern: %s", p.text)
+	}
+
+	for _, segment := range strings.Split(line, "/") {
+		if segment == "**" {
+			p.segments = append(p.segments, nil)
+			continue
+		}
+		p.segments = append(p.segments, newWildcard(segment))
+	}
+	return p, nil
+}
+
+// trimTrailingSpace removes trailing spaces, except one escaped with a backslash
+func trimTrailingSpace(line string) string {
+	for strings.HasSuffix(line, " ") {
+		if strings.HasSuffix(line, `\ `) {
+			return line[:len(line)-2] + " "
+		}
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+// match reports whether the pattern matches a path, given as its elements
+// relative to the base of the pattern
+func (p *pattern) match(elems []string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if !p.anchored {
+		return p.segments[0] == nil || p.segments[0].Match(elems[len(elems)-1])
+	}
+	return matchSegments(p.segments, elems)
+}
+
+// matchSegments matches path elements against wildcard segments, where "**"
+// (a nil segment) matches any number of elements. A trailing "**" matches
+// everything inside a directory, but not the directory itself.
+func matchSegments(segments []*wildcard, elems []string) bool {
+	for len(segments) > 0 {
+		if segments[0] == nil {
+			segments = segments[1:]
+			if len(segments) == 0 {
+				return len(elems) > 0
+			}
+			for i := 0; i <= len(elems); i++ {
+				if matchSegments(segments, elems[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(elems) == 0 {
+			return false
+		}
+		if !segments[0].Match(elems[0]) {
+			return false
+		}
+		segments, elems = segments[1:], elems[1:]
+	}
+	return len(elems) == 0
+}
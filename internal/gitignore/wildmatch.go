@@ -0,0 +1,201 @@
+package gitignore
+
+import "unicode"
+
+// wildcard is a path element of a pattern, matched like git's wildmatch:
+//
+//	Pattern       Meaning
+//	*             matches any characters
+//	?             matches any character
+//	[abc]         matches one of the characters, [a-z] one of a range
+//	[[:alpha:]]   matches a character of a POSIX class
+//	[!abc]        matches a character not in the class ([^abc] too)
+//	\c            matches the character c literally
+//
+// Unlike shell globs, braces are literal. A malformed pattern, such as one
+// with an unclosed class, matches nothing rather than being an error.
+type wildcard struct {
+	pattern []rune
+	valid   bool
+}
+
+// newWildcard compiles a path element of a pattern
+func newWildcard(pattern string) *wildcard {
+	w := &wildcard{pattern: []rune(pattern)}
+	w.valid = validWildcard(w.pattern)
+	return w
+}
+
+// validWildcard reports whether every class of a pattern is closed and no
+// backslash ends it
+func validWildcard(p []rune) bool {
+	for i := 0; i < len(p); i++ {
+		switch p[i] {
+		case '\\':
+			if i+1 >= len(p) {
+				return false
+			}
+			i++
+		case '[':
+			_, n, ok := matchClass(p[i+1:], 0)
+			if !ok {
+				return false
+			}
+			i += n
+		}
+	}
+	return true
+}
+
+// Match reports whether a name matches the pattern
+func (w *wildcard) Match(name string) bool {
+	return w.valid && wildmatch(w.pattern, []rune(name))
+}
+
+// wildmatch matches a name against a valid pattern. A star is tried with
+// the shortest match first, and extended when the rest doesn't match.
+func wildmatch(p, name []rune) bool {
+	px, nx := 0, 0
+	starPx, starNx := -1, -1
+	for px < len(p) || nx < len(name) {
+		if px < len(p) {
+			switch p[px] {
+			case '*':
+				for px < len(p) && p[px] == '*' {
+					px++
+				}
+				starPx, starNx = px, nx
+				continue
+			case '?':
+				if nx < len(name) {
+					px++
+					nx++
+					continue
+				}
+			case '[':
+				if nx < len(name) {
+					if in, n, _ := matchClass(p[px+1:], name[nx]); in {
+						px += 1 + n
+						nx++
+						continue
+					}
+				}
+			case '\\':
+				if nx < len(name) && name[nx] == p[px+1] {
+					px += 2
+					nx++
+					continue
+				}
+			default:
+				if nx < len(name) && name[nx] == p[px] {
+					px++
+					nx++
+					continue
+				}
+			}
+		}
+
+		// Let the last star match one more character
+		if starPx >= 0 && starNx < len(name) {
+			starNx++
+			px, nx = starPx, starNx
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// posixClasses are the character classes of [[:name:]]
+var posixClasses = map[string]func(rune) bool{
+	"alnum":  func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) },
+	"alpha":  unicode.IsLetter,
+	"blank":  func(r rune) bool { return r == ' ' || r == '\t' },
+	"cntrl":  unicode.IsControl,
+	"digit":  unicode.IsDigit,
+	"graph":  func(r rune) bool { return unicode.IsGraphic(r) && !unicode.IsSpace(r) },
+	"lower":  unicode.IsLower,
+	"print":  unicode.IsPrint,
+	"punct":  unicode.IsPunct,
+	"space":  unicode.IsSpace,
+	"upper":  unicode.IsUpper,
+	"xdigit": func(r rune) bool { return unicode.Is(unicode.ASCII_Hex_Digit, r) },
+}
+
+// matchClass matches r against a character class, given as the pattern
+// after its opening [. It returns whether r is in the class, the length of
+// the class in the pattern with its closing ], and false if the class is
+// malformed.
+func matchClass(p []rune, r rune) (bool, int, bool) {
+	i := 0
+	negate := false
+	if i < len(p) && (p[i] == '!' || p[i] == '^') {
+		negate = true
+		i++
+	}
+
+	in := false
+	for first := true; ; first = false {
+		if i >= len(p) {
+			return false, 0, false
+		}
+		c := p[i]
+		if c == ']' && !first {
+			return in != negate, i + 1, true
+		}
+
+		// A POSIX class, such as [:digit:]
+		if c == '[' && i+1 < len(p) && p[i+1] == ':' {
+			if end := indexClassEnd(p[i+2:]); end >= 0 {
+				isClass, ok := posixClasses[string(p[i+2:i+2+end])]
+				if !ok {
+					return false, 0, false
+				}
+				in = in || isClass(r)
+				i += 2 + end + 2
+				continue
+			}
+		}
+
+		lo, n, ok := classChar(p[i:])
+		if !ok {
+			return false, 0, false
+		}
+		i += n
+
+		// A range, unless the - is last in the class
+		if i+1 < len(p) && p[i] == '-' && p[i+1] != ']' {
+			hi, n, ok := classChar(p[i+1:])
+			if !ok {
+				return false, 0, false
+			}
+			i += 1 + n
+			in = in || (lo <= r && r <= hi)
+			continue
+		}
+		in = in || r == lo
+	}
+}
+
+// classChar returns the character at the start of a class and its length
+// in the pattern, which is 2 if it is escaped with a backslash
+func classChar(p []rune) (rune, int, bool) {
+	if p[0] != '\\' {
+		return p[0], 1, true
+	}
+	if len(p) < 2 {
+		return 0, 0, false
+	}
+	return p[1], 2, true
+}
+
+// indexClassEnd returns the index of the :] closing a POSIX class name, or
+// -1 if there is none
+func indexClassEnd(p []rune) int {
+	for i := 0; i+1 < len(p); i++ {
+		if p[i] == ':' && p[i+1] == ']' {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,256 @@
+// Package glob matches names and paths against shell glob patterns
+package glob
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Glob is a compiled set of glob patterns, with this syntax:
+//
+//	Pattern   Meaning
+//	*         matches any characters except the path separator
+//	**        matches any characters, including the path separator
+//	?         matches any character except the path separator
+//	[abc]     matches one of the characters, [a-z] one of a range
+//	[!abc]    matches a character not in the class ([^abc] too)
+//	{a,b}     matches one of the comma-separated alternatives, which can nest
+//	\c        matches the character c literally
+type Glob struct {
+	patterns []string
+	re       *regexp.Regexp
+}
+
+// notSeparator is the regular expression of a character that is not a path separator
+var notSeparator = func() string {
+	if filepath.Separator == '/' {
+		return `[^/]`
+	}
+	return `[^/` + regexp.QuoteMeta(string(filepath.Separator)) + `]`
+}()
+
+// Compile compiles glob patterns into a Glob that matches names matching
+// any of them
+func Compile(patterns ...string) (*Glob, error) {
+	exprs := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		expr, err := translate(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if _, err := regexp.Compile(expr); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		exprs = append(exprs, expr)
+	}
+
+	re, err := regexp.Compile("^(?:" + strings.Join(exprs, "|") + ")$")
+	if err != nil {
+		return nil, err
+	}
+	return &Glob{patterns: patterns, re: re}, nil
+}
+
+// Match reports whether name matches one of the patterns
+func (g *Glob) Match(name string) bool {
+	return g.re.MatchString(name)
+}
+
+// PathGlob is a compiled set of glob patterns matched against paths.
+// Patterns with a path separator match the whole path, and the others the
+// base name of the path.
+type PathGlob struct {
+	names *Glob // Patterns without a path separator (may be nil)
+	paths *Glob // Patterns with a path separator (may be nil)
+}
+
+// CompilePaths compiles glob patterns into a PathGlob that matches paths
+// matching any of them
+func CompilePaths(patterns ...string) (*PathGlob, error) {
+	var names, paths []string
+	for _, pattern := range patterns {
+		if strings.ContainsRune(pattern, '/') || strings.ContainsRune(pattern, filepath.Separator) {
+			paths = append(paths, pattern)
+		} else {
+			names = append(names, pattern)
+		}
+	}
+
+	g := &PathGlob{}
+	var err error
+	if len(names) > 0 {
+		if g.names, err = Compile(names...); err != nil {
+			return nil, err
+		}
+	}
+	if len(paths) > 0 {
+		if g.paths, err = Compile(paths...); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// Match reports whether a path matches one of the patterns
+func (g *PathGlob) Match(path string) bool {
+	return (g.names != nil && g.names.Match(filepath.Base(path))) || (g.paths != nil && g.paths.Match(path))
+}
+
+// Patterns returns the patterns of the glob
+func (g *Glob) Patterns() []string {
+	return g.patterns
+}
+
+// String returns the patterns, comma-separated
+func (g *Glob) String() string {
+	return strings.Join(g.patterns, ",")
+}
+
+// Split splits comma-separated patterns. Commas in braces or character
+// classes, or escaped with a backslash, don't separate patterns. Spaces
+// around patterns and empty patterns are dropped.
+func Split(s string) []string {
+	var patterns []string
+	start, depth, inClass := 0, 0, false
+	add := func(end int) {
+		if pattern := strings.TrimSpace(s[start:end]); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+		case c == '[':
+			inClass = true
+			// A ] first in the class, after an optional negation, is literal
+			if i+1 < len(s) && (s[i+1] == '!' || s[i+1] == '^') {
+				i++
+			}
+			if i+1 < len(s) && s[i+1] == ']' {
+				i++
+			}
+		case c == '{':
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+		case c == ',' && depth == 0:
+			add(i)
+			start = i + 1
+		}
+	}
+	add(len(s))
+	return patterns
+}
+
+// translate converts a glob pattern to a regular expression
+func translate(pattern string) (string, error) {
+	var b strings.Builder
+	depth := 0
+
+	for i := 0; i < len(pattern); {
+		r, size := utf8.DecodeRuneInString(pattern[i:])
+		i += size
+
+		switch r {
+		case '\\':
+			if i >= len(pattern) {
+				return "", fmt.Errorf("trailing backslash")
+			}
+			r, size = utf8.DecodeRuneInString(pattern[i:])
+			i += size
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		case '*':
+			if strings.HasPrefix(pattern[i:], "*") {
+				i++
+				b.WriteString(".*")
+			} else {
+				b.WriteString(notSeparator + "*")
+			}
+		case '?':
+			b.WriteString(notSeparator)
+		case '[':
+			class, n, err := translateClass(pattern[i:])
+			if err != nil {
+				return "", err
+			}
+			i += n
+			b.WriteString(class)
+		case '{':
+			depth++
+			b.WriteString("(?:")
+		case ',':
+			if depth > 0 {
+				b.WriteString("|")
+			} else {
+				b.WriteString(",")
+			}
+		case '}':
+			if depth > 0 {
+				depth--
+				b.WriteString(")")
+			} else {
+				b.WriteString(`\}`)
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	if depth > 0 {
+		return "", fmt.Errorf("unclosed brace")
+	}
+	return b.String(), nil
+}
+
+// translateClass converts a character class, given as the pattern after
+// its opening [, to a regular expression. It returns the expression and
+// the length of the class in the pattern.
+func translateClass(s string) (string, int, error) {
+	var b strings.Builder
+	b.WriteString("[")
+
+	i := 0
+	if i < len(s) && (s[i] == '!' || s[i] == '^') {
+		b.WriteString("^")
+		i++
+	}
+	for first := true; ; first = false {
+		if i >= len(s) {
+			return "", 0, fmt.Errorf("unclosed character class")
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+
+		switch {
+		case r == ']' && !first:
+			b.WriteString("]")
+			return b.String(), i, nil
+		case r == '\\':
+			if i >= len(s) {
+				return "", 0, fmt.Errorf("unclosed character class")
+			}
+			r, size = utf8.DecodeRuneInString(s[i:])
+			i += size
+			if r == '-' {
+				b.WriteString(`\-`)
+			} else {
+				b.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		case r == '-':
+			b.WriteString("-")
+		case strings.ContainsRune(`[]^\`, r):
+			b.WriteString(`\` + string(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package glob
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.txt", "notes.txt", true},
+		{"*.txt", "notes.txt.bak", false},
+		{"*.txt", "dir/notes.txt", false},
+		{"**.txt", "dir/notes.txt", true},
+		{"dir/**", "dir/sub/notes.txt", true},
+		{"dir/*", "dir/sub/notes.txt", false},
+		{"file?.log", "file1.log", true},
+		{"file?.log", "file10.log", false},
+		{"file?", "file/", false},
+		{"[abc].go", "b.go", true},
+		{"[abc].go", "d.go", false},
+		{"[a-c].go", "c.go", true},
+		{"[!abc].go", "d.go", true},
+		{"[^abc].go", "a.go", false},
+		{"[]].go", "].go", true},
+		{"[a\\-c].go", "-.go", true},
+		{"[a\\-c].go", "b.go", false},
+		{"*.{jpg,png}", "photo.png", true},
+		{"*.{jpg,png}", "photo.gif", false},
+		{"{a,b{c,d}}.txt", "bd.txt", true},
+		{"{a,b{c,d}}.txt", "b.txt", false},
+		{"a,b", "a,b", true},
+		{"a}", "a}", true},
+		{"\\*.txt", "*.txt", true},
+		{"\\*.txt", "a.txt", false},
+		{"a+b(1).txt", "a+b(1).txt", true},
+		{"*", "", true},
+	}
+
+	for _, tt := range tests {
+		g, err := Compile(tt.pattern)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.pattern, err)
+			continue
+		}
+		if got := g.Match(tt.name); got != tt.want {
+			t.Errorf("Compile(%q).Match(%q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCompileInvalid(t *testing.T) {
+	for _, pattern := range []string{"[abc", "{a,b", "abc\\", "[\\"} {
+		if _, err := Compile(pattern); err == nil {
+			t.Errorf("Compile(%q) succeeded, want an error", pattern)
+		}
+	}
+}
+
+func TestCompileAny(t *testing.T) {
+	g, err := Compile("*.jpg", "*.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"a.jpg": true, "a.png": true, "a.gif": false} {
+		if got := g.Match(name); got != want {
+			t.Errorf("Match(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestPathMatch(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		path     string
+		want     bool
+	}{
+		{[]string{"*.tmp"}, "/data/cache/file.tmp", true},
+		{[]string{"*.tmp"}, "/data/file.tmp/other", false},
+		{[]string{"/data/*/file.tmp"}, "/data/cache/file.tmp", true},
+		{[]string{"/data/*.tmp"}, "/data/cache/file.tmp", false},
+		{[]string{"/data/**.tmp"}, "/data/cache/file.tmp", true},
+		{[]string{"cache", "/other/**"}, "/data/cache", true},
+		{[]string{"cache", "/other/**"}, "/other/file", true},
+		{[]string{"cache", "/other/**"}, "/data/file", false},
+	}
+
+	for _, tt := range tests {
+		g, err := CompilePaths(tt.patterns...)
+		if err != nil {
+			t.Errorf("CompilePaths(%q): %v", tt.patterns, err)
+			continue
+		}
+		if got := g.Match(tt.path); got != tt.want {
+			t.Errorf("CompilePaths(%q).Match(%q) = %v, want %v", tt.patterns, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"", nil},
+		{"*.jpg", []string{"*.jpg"}},
+		{"*.jpg, *.png ,", []string{"*.jpg", "*.png"}},
+		{"*.{jpg,png},*.gif", []string{"*.{jpg,png}", "*.gif"}},
+		{"[,]x,y", []string{"[,]x", "y"}},
+		{"[],]x,y", []string{"[],]x", "y"}},
+		{"a\\,b,c", []string{"a\\,b", "c"}},
+	}
+
+	for _, tt := range tests {
+		if got := Split(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Split(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
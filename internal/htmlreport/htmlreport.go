@@ -0,0 +1,286 @@
+package htmlreport
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"image"
+	_ "image/gif" // Register decoders for thumbnails
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+)
+
+const (
+	// ThumbnailSize is the maximum width and height of image thumbnails in pixels
+	ThumbnailSize = 96
+
+	// MaxThumbnails is the maximum number of thumbnails embedded in a report,
+	// which bounds the size of the report file
+	MaxThumbnails = 1000
+
+	// maxThumbnailSource is the largest image file a thumbnail is made of
+	maxThumbnailSource = 32 * 1024 * 1024 // 32 MiB
+
+	// maxThumbnailPixels is the largest image a thumbnail is made of, as a
+	// small file can hold a huge image that would take gigabytes to decode
+	maxThumbnailPixels = 50 * 1000 * 1000
+)
+
+//go:embed report.html
+var reportTemplate string
+
+// tmpl is the parsed report template
+var tmpl = template.Must(template.New("report").Parse(reportTemplate))
+
+// report is the data embedded in the HTML report as JSON
+type report struct {
+	Generated  string      `json:"generated"`
+	ScanTime   string      `json:"scanTime"`
+	Groups     []group     `json:"groups"`
+	Dirs       []dirTotal  `json:"dirs"`
+	Errors     []pathError `json:"errors"`
+	Duplicates int         `json:"duplicates"`
+	Wasted     int64       `json:"wasted"`
+}
+
+// group is a duplicate group in the report
+type group struct {
+	ID     int    `json:"id"`
+	Size   int64  `json:"size"`   // Size of the reference
+	Wasted int64  `json:"wasted"` // Total size of the duplicates
+	Files  []file `json:"files"`  // Reference first, then the duplicates
+}
+
+// file is a file of a duplicate group in the report
+type file struct {
+	Path        string `json:"path"`
+	Size        int64  `json:"size"`
+	ModTime     string `json:"mtime"`
+	Percentage  int    `json:"pct"`
+	Reference   bool   `json:"ref"`   // Whether this is the group's reference
+	InReference bool   `json:"inRef"` // Whether the file is in a reference directory
+	Thumbnail   string `json:"thumb,omitempty"`
+}
+
+// dirTotal is the duplicate bytes found in one directory
+type dirTotal struct {
+	Path  string `json:"path"`
+	Files int    `json:"files"`
+	Bytes int64  `json:"bytes"`
+}
+
+// pathError is a path that could not be read by the scan
+type pathError struct {
+	Path     string `json:"path"`
+	Category string `json:"category"`
+	Message  string `json:"message"`
+}
+
+// Write writes a self-contained HTML report of duplicate groups, with
+// embedded styles, scripts, data and image thumbnails
+func Write(w io.Writer, groups []*engine.DuplicateGroup, errs []*fs.PathError, scanTime time.Duration) error {
+	r := report{
+		Generated:  time.Now().Format(time.RFC1123),
+		ScanTime:   scanTime.Round(time.Millisecond).String(),
+		Groups:     make([]group, 0, len(groups)),
+		Errors:     make([]pathError, 0, len(errs)),
+		Duplicates: engine.TotalDuplicateCount(groups),
+		Wasted:     engine.TotalDuplicateSize(groups),
+	}
+
+	t := &thumbnailer{}
+	dirs := make(map[string]*dirTotal)
+	for i, g := range groups {
+		rg := group{
+			ID:    i + 1,
+			Size:  g.Reference.Size,
+			Files: make([]file, 0, len(g.Duplicates)+1),
+		}
+
+		t.startGroup()
+		rg.Files = append(rg.Files, newFile(g.Reference, 100, true, t))
+		for j, dupe := range g.Duplicates {
+			rg.Files = append(rg.Files, newFile(dupe, g.Matches[j].Percentage, false, t))
+			rg.Wasted += dupe.Size
+
+			// Count duplicates against the directory they are in
+			dir := filepath.Dir(dupe.Path)
+			total := dirs[dir]
+			if total == nil {
+				total = &dirTotal{Path: dir}
+				dirs[dir] = total
+			}
+			total.Files++
+			total.Bytes += dupe.Size
+		}
+
+		r.Groups = append(r.Groups, rg)
+	}
+
+	r.Dirs = make([]dirTotal, 0, len(dirs))
+	for _, total := range dirs {
+		r.Dirs = append(r.Dirs, *total)
+	}
+	sort.Slice(r.Dirs, func(i, j int) bool {
+		if r.Dirs[i].Bytes != r.Dirs[j].Bytes {
+			return r.Dirs[i].Bytes > r.Dirs[j].Bytes
+		}
+		return r.Dirs[i].Path < r.Dirs[j].Path
+	})
+
+	for _, pathErr := range errs {
+		r.Errors = append(r.Errors, pathError{
+			Path:     pathErr.Path,
+			Category: pathErr.Category.String(),
+			Message:  pathErr.Err.Error(),
+		})
+	}
+
+	// json.Marshal escapes <, > and &, so the data is safe inside a script element
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(w, struct {
+		Data template.JS
+	}{
+		Data: template.JS(data),
+	})
+}
+
+// newFile converts a file of a duplicate group, with a thumbnail for images
+func newFile(f *fs.File, percentage int, reference bool, t *thumbnailer) file {
+	rf := file{
+		Path:        f.Path,
+		Size:        f.Size,
+		ModTime:     f.ModTime.Format("2006-01-02 15:04:05"),
+		Percentage:  percentage,
+		Reference:   reference,
+		InReference: f.IsReference,
+	}
+
+	rf.Thumbnail = t.thumbnail(f)
+	return rf
+}
+
+// thumbnailer makes the thumbnails of a report, as long as it has room for
+// more. Files of a group with the same content share a thumbnail, so that
+// a group of identical images is decoded once.
+type thumbnailer struct {
+	count int               // Number of thumbnails in the report
+	group map[string]string // Thumbnails of the current group by digest
+}
+
+// startGroup starts the thumbnails of another group
+func (t *thumbnailer) startGroup() {
+	t.group = make(map[string]string)
+}
+
+// thumbnail returns the thumbnail of a file, or "" if it has none
+func (t *thumbnailer) thumbnail(f *fs.File) string {
+	if t.count >= MaxThumbnails || !isImage(f.Path) || f.Size > maxThumbnailSource {
+		return ""
+	}
+
+	// Files without a digest may differ from the rest of their group
+	key := string(f.Digest)
+	thumb, ok := t.group[key]
+	if !ok {
+		thumb, _ = makeThumbnail(f.Path)
+		if key != "" {
+			t.group[key] = thumb
+		}
+	}
+	if thumb != "" {
+		t.count++
+	}
+	return thumb
+}
+
+// isImage reports whether a file has an image extension that thumbnails can be made of
+func isImage(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	default:
+		return false
+	}
+}
+
+// makeThumbnail returns a JPEG thumbnail of an image as a data URI
+func makeThumbnail(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return "", err
+	}
+	if int64(config.Width)*int64(config.Height) > maxThumbnailPixels {
+		return "", fmt.Errorf("image too large for a thumbnail: %dx%d", config.Width, config.Height)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleDown(img, ThumbnailSize), &jpeg.Options{Quality: 75}); err != nil {
+		return "", err
+	}
+
+	return "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// scaleDown scales an image to fit in a square of the given size,
+// sampling the nearest source pixel
+func scaleDown(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= size && height <= size {
+		return src
+	}
+
+	newWidth, newHeight := size, size
+	if width > height {
+		newHeight = height * size / width
+	} else {
+		newWidth = width * size / height
+	}
+	if newWidth < 1 {
+		newWidth = 1
+	}
+	if newHeight < 1 {
+		newHeight = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		sy := bounds.Min.Y + y*height/newHeight
+		for x := 0; x < newWidth; x++ {
+			sx := bounds.Min.X + x*width/newWidth
+			dst.Set(x, y, src.At(sx, sy))
+		}
+	}
+	return dst
+}
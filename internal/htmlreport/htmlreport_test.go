@@ -0,0 +1,286 @@
+package htmlreport
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+	"github.com/tendant/dupe-cli/internal/matcher"
+)
+
+// newGroup returns a group of a reference and its duplicates, which match
+// it at 100%
+func newGroup(ref *fs.File, dupes ...*fs.File) *engine.DuplicateGroup {
+	group := &engine.DuplicateGroup{Reference: ref, Duplicates: dupes}
+	for _, dupe := range dupes {
+		group.Matches = append(group.Matches, &matcher.Match{First: ref, Second: dupe, Percentage: 100})
+	}
+	return group
+}
+
+// writeReport writes the report of groups and returns its HTML and the
+// data embedded in its script
+func writeReport(t *testing.T, groups []*engine.DuplicateGroup, errs []*fs.PathError) (string, report) {
+	t.Helper()
+	var out bytes.Buffer
+	if err := Write(&out, groups, errs, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	html := out.String()
+
+	const prefix = "const DATA = "
+	start := strings.Index(html, prefix)
+	if start < 0 {
+		t.Fatal("report has no data")
+	}
+	line := html[start+len(prefix):]
+	line = line[:strings.Index(line, "\n")]
+	var r report
+	if err := json.Unmarshal([]byte(strings.TrimSuffix(line, ";")), &r); err != nil {
+		t.Fatalf("invalid data %s: %v", line, err)
+	}
+	return html, r
+}
+
+// writeImage writes a PNG image of the given size
+func writeImage(t *testing.T, path string, width, height int) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteScriptBreakout(t *testing.T) {
+	paths := []string{
+		"/a/</script><script>alert(1)</script>.txt",
+		"/a/<!--<script>.txt",
+		"/a/   & \"quotes\".txt",
+	}
+	ref := &fs.File{Path: "/a/ref.txt", Size: 3}
+	var dupes []*fs.File
+	for _, path := range paths {
+		dupes = append(dupes, &fs.File{Path: path, Size: 3})
+	}
+	errs := []*fs.PathError{{Path: "/a/</script>", Category: fs.ErrorPermission, Err: errors.New("<!-- denied -->")}}
+
+	html, r := writeReport(t, []*engine.DuplicateGroup{newGroup(ref, dupes...)}, errs)
+
+	// The only end of a script element and no comment are the template's
+	if n := strings.Count(strings.ToLower(html), "</script"); n != 1 {
+		t.Errorf("report has %d ends of script elements, want 1", n)
+	}
+	if strings.Contains(html, "<!--") {
+		t.Error("report opens an HTML comment")
+	}
+
+	// Paths are intact in the data
+	for i, path := range paths {
+		if got := r.Groups[0].Files[i+1].Path; got != path {
+			t.Errorf("got path %q, want %q", got, path)
+		}
+	}
+	if got := r.Errors[0]; got.Path != "/a/</script>" || got.Message != "<!-- denied -->" {
+		t.Errorf("got error %+v", got)
+	}
+}
+
+func TestWriteNoGroups(t *testing.T) {
+	html, r := writeReport(t, nil, nil)
+	if !strings.Contains(html, "</html>") {
+		t.Error("report is incomplete")
+	}
+
+	// Empty lists, not null, which the script would fail on
+	if !strings.Contains(html, `"groups":[]`) || !strings.Contains(html, `"dirs":[]`) || !strings.Contains(html, `"errors":[]`) {
+		t.Errorf("report data isn't empty lists: %+v", r)
+	}
+	if r.Duplicates != 0 || r.Wasted != 0 {
+		t.Errorf("got %d duplicates and %d bytes wasted, want none", r.Duplicates, r.Wasted)
+	}
+}
+
+func TestThumbnailLimit(t *testing.T) {
+	dir := t.TempDir()
+	var files []*fs.File
+	for _, name := range []string{"a.png", "b.png", "c.png"} {
+		path := filepath.Join(dir, name)
+		writeImage(t, path, 4, 4)
+		files = append(files, &fs.File{Path: path, Size: 100, Digest: []byte(name)})
+	}
+
+	// Two thumbnails are left of the limit
+	th := &thumbnailer{count: MaxThumbnails - 2}
+	th.startGroup()
+	var got []bool
+	for _, f := range files {
+		got = append(got, th.thumbnail(f) != "")
+	}
+	if want := []bool{true, true, false}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got thumbnails %v, want %v", got, want)
+	}
+	if th.count != MaxThumbnails {
+		t.Errorf("counted %d thumbnails, want %d", th.count, MaxThumbnails)
+	}
+
+	// Files of a group with the same digest share a thumbnail, which still
+	// counts for each of them
+	th = &thumbnailer{}
+	th.startGroup()
+	first := th.thumbnail(&fs.File{Path: files[0].Path, Size: 100, Digest: []byte("same")})
+	second := th.thumbnail(&fs.File{Path: files[1].Path, Size: 100, Digest: []byte("same")})
+	if first == "" || first != second || th.count != 2 {
+		t.Errorf("thumbnails of the same content differ, or were counted %d times", th.count)
+	}
+
+	// Sources that are too big, and files that aren't images, have none
+	th = &thumbnailer{}
+	th.startGroup()
+	if th.thumbnail(&fs.File{Path: files[0].Path, Size: maxThumbnailSource + 1}) != "" {
+		t.Error("file over the source limit has a thumbnail")
+	}
+	text := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(text, []byte("text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if th.thumbnail(&fs.File{Path: text, Size: 4}) != "" {
+		t.Error("text file has a thumbnail")
+	}
+}
+
+func TestMakeThumbnail(t *testing.T) {
+	dir := t.TempDir()
+
+	// Thumbnails fit in the thumbnail size, keeping the aspect ratio
+	wide := filepath.Join(dir, "wide.png")
+	writeImage(t, wide, 300, 150)
+	thumb, err := makeThumbnail(wide)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _, err := image.DecodeConfig(base64Reader(t, thumb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Width != ThumbnailSize || img.Height != ThumbnailSize/2 {
+		t.Errorf("thumbnail is %dx%d, want %dx%d", img.Width, img.Height, ThumbnailSize, ThumbnailSize/2)
+	}
+
+	// An image with more pixels than the limit isn't decoded: its header
+	// claims a size much larger than its data
+	huge := filepath.Join(dir, "huge.png")
+	writeImage(t, huge, 1, 1)
+	setPNGSize(t, huge, 10000, 10000)
+	if thumb, err := makeThumbnail(huge); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("thumbnail of a 10000x10000 image: %q, %v, want too large", thumb, err)
+	}
+}
+
+// base64Reader returns a reader of the image in a data URI
+func base64Reader(t *testing.T, uri string) *bytes.Reader {
+	t.Helper()
+	const prefix = "data:image/jpeg;base64,"
+	if !strings.HasPrefix(uri, prefix) {
+		t.Fatalf("thumbnail %.40q isn't a JPEG data URI", uri)
+	}
+	data, err := base64.StdEncoding.DecodeString(uri[len(prefix):])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(data)
+}
+
+// setPNGSize rewrites the width and height in the header of a PNG file
+func setPNGSize(t *testing.T, path string, width, height uint32) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The IHDR chunk follows the 8 byte signature: length, type, width,
+	// height, 5 more bytes, then the CRC of the type and data
+	binary.BigEndian.PutUint32(data[16:], width)
+	binary.BigEndian.PutUint32(data[20:], height)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportScriptQuoting(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	// The quoting function of the report's script
+	html, _ := writeReport(t, nil, nil)
+	start := strings.Index(html, "function shellQuote(")
+	if start < 0 {
+		t.Fatal("report has no shellQuote function")
+	}
+	end := strings.Index(html[start:], "\n}\n")
+	shellQuote := html[start : start+end+2]
+
+	dir := t.TempDir()
+	names := []string{"with space", "it's", `"double"`, "$(touch pwned)`x`", "back\\slash", "'; rm -rf x; '", "-n"}
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		keep := path + ".keep"
+		for _, p := range []string{path, keep} {
+			if err := os.WriteFile(p, []byte("x"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// The delete line of the exported script, run by the shell
+		script := shellQuote + "\nprocess.stdout.write(\"rm -f -- \" + shellQuote(process.argv[1]));"
+		line, err := exec.Command(node, "-e", script, path).Output()
+		if err != nil {
+			t.Fatalf("%s: node: %v", name, err)
+		}
+		cmd := exec.Command(sh, "-c", string(line))
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%s: %s: %v: %s", name, line, err, out)
+		}
+
+		if _, err := os.Lstat(path); err == nil {
+			t.Errorf("%s: %s didn't delete the file", name, line)
+		}
+		if _, err := os.Lstat(keep); err != nil {
+			t.Errorf("%s: %s deleted another file", name, line)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "pwned")); err == nil {
+		t.Error("a command in a path was run")
+	}
+}
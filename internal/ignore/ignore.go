@@ -0,0 +1,255 @@
+package ignore
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/fs"
+	"github.com/tendant/dupe-cli/internal/glob"
+)
+
+// Version is the version of the ignore list file format
+const Version = 1
+
+// RuleType is the kind of an ignore rule
+type RuleType string
+
+const (
+	// RulePair ignores two files being reported as duplicates of each other
+	RulePair RuleType = "pair"
+	// RuleDigest ignores all files with the given content digest
+	RuleDigest RuleType = "digest"
+	// RuleGlob ignores all files matching a glob pattern
+	RuleGlob RuleType = "glob"
+)
+
+// Rule is an entry of the ignore list
+type Rule struct {
+	Type    RuleType  `json:"type"`
+	Paths   []string  `json:"paths,omitempty"`   // Absolute paths of the two files of a pair rule
+	Digest  string    `json:"digest,omitempty"`  // Hex-encoded digest of a digest rule
+	Pattern string    `json:"pattern,omitempty"` // Pattern of a glob rule
+	Added   time.Time `json:"added"`
+}
+
+// String describes the rule
+func (r Rule) String() string {
+	switch r.Type {
+	case RulePair:
+		return fmt.Sprintf("pair    %s <-> %s", r.Paths[0], r.Paths[1])
+	case RuleDigest:
+		return fmt.Sprintf("digest  %s", r.Digest)
+	default:
+		return fmt.Sprintf("glob    %s", r.Pattern)
+	}
+}
+
+// List is the persistent list of files and pairs of files that are not
+// reported as duplicates, like dupeGuru's ignore list
+type List struct {
+	Rules []Rule
+
+	path    string // Path of the ignore list file
+	cwd     string // Working directory, to make relative scan paths absolute
+	pairs   map[[2]string]bool
+	digests map[string]bool
+	globs   *glob.PathGlob // Patterns of the glob rules (nil if there are none)
+}
+
+// listFile is the on-disk format of the ignore list
+type listFile struct {
+	Version int    `json:"version"`
+	Rules   []Rule `json:"rules"`
+}
+
+// DefaultPath returns the default location of the ignore list file
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ".dupe-cli-ignore.json"
+	}
+	return filepath.Join(dir, "dupe-cli", "ignore.json")
+}
+
+// Load reads an ignore list file. A missing file is an empty list.
+func Load(path string) (*List, error) {
+	l := &List{path: path}
+	l.cwd, _ = os.Getwd()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		l.index()
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file listFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid ignore list %s: %w", path, err)
+	}
+	if file.Version != Version {
+		return nil, fmt.Errorf("unsupported ignore list version %d in %s", file.Version, path)
+	}
+
+	for _, rule := range file.Rules {
+		if err := validate(rule); err != nil {
+			return nil, fmt.Errorf("invalid rule in ignore list %s: %w", path, err)
+		}
+	}
+
+	l.Rules = file.Rules
+	l.index()
+	return l, nil
+}
+
+// Save writes the ignore list file
+func (l *List) Save() error {
+	data, err := json.MarshalIndent(listFile{Version: Version, Rules: l.Rules}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(l.path, data, 0644)
+}
+
+// AddPair adds a pair rule. It returns false if the list already had it.
+func (l *List) AddPair(first, second string) bool {
+	a, b := l.abs(first), l.abs(second)
+	if l.pairs[pairKey(a, b)] {
+		return false
+	}
+	return l.add(Rule{Type: RulePair, Paths: []string{a, b}})
+}
+
+// AddDigest adds a digest rule. It returns false if the list already had it.
+func (l *List) AddDigest(digest []byte) bool {
+	hexDigest := hex.EncodeToString(digest)
+	if l.digests[hexDigest] {
+		return false
+	}
+	return l.add(Rule{Type: RuleDigest, Digest: hexDigest})
+}
+
+// AddGlob adds a glob rule. It returns false if the list already had it.
+func (l *List) AddGlob(pattern string) (bool, error) {
+	rule := Rule{Type: RuleGlob, Pattern: pattern}
+	if err := validate(rule); err != nil {
+		return false, err
+	}
+	for _, r := range l.Rules {
+		if r.Type == RuleGlob && r.Pattern == pattern {
+			return false, nil
+		}
+	}
+	return l.add(rule), nil
+}
+
+// add appends a rule
+func (l *List) add(rule Rule) bool {
+	rule.Added = time.Now()
+	l.Rules = append(l.Rules, rule)
+	l.index()
+	return true
+}
+
+// Remove removes the rule at index
+func (l *List) Remove(index int) error {
+	if index < 0 || index >= len(l.Rules) {
+		return fmt.Errorf("no rule %d in the ignore list", index+1)
+	}
+	l.Rules = append(l.Rules[:index], l.Rules[index+1:]...)
+	l.index()
+	return nil
+}
+
+// IgnoresFile reports whether a file is ignored by a glob rule, or by a
+// digest rule if the file's digest has been calculated
+func (l *List) IgnoresFile(file *fs.File) bool {
+	if l == nil {
+		return false
+	}
+
+	if file.Digest != nil && l.digests[hex.EncodeToString(file.Digest)] {
+		return true
+	}
+
+	return l.globs != nil && l.globs.Match(l.abs(file.Path))
+}
+
+// IgnoresPair reports whether two files are not to be reported as duplicates
+// of each other
+func (l *List) IgnoresPair(first, second *fs.File) bool {
+	if l == nil || len(l.pairs) == 0 {
+		return false
+	}
+	return l.pairs[pairKey(l.abs(first.Path), l.abs(second.Path))]
+}
+
+// index rebuilds the lookup tables of pair and digest rules, and compiles
+// the patterns of glob rules, which validate has checked
+func (l *List) index() {
+	l.pairs = make(map[[2]string]bool)
+	l.digests = make(map[string]bool)
+	var patterns []string
+	for _, rule := range l.Rules {
+		switch rule.Type {
+		case RulePair:
+			l.pairs[pairKey(rule.Paths[0], rule.Paths[1])] = true
+		case RuleDigest:
+			l.digests[rule.Digest] = true
+		case RuleGlob:
+			patterns = append(patterns, rule.Pattern)
+		}
+	}
+
+	l.globs = nil
+	if len(patterns) > 0 {
+		l.globs, _ = glob.CompilePaths(patterns...)
+	}
+}
+
+// abs returns the absolute, clean form of a path
+func (l *List) abs(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(l.cwd, path)
+}
+
+// pairKey returns the key of a pair independent of the order of its paths
+func pairKey(a, b string) [2]string {
+	if b < a {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
+
+// validate checks that a rule is well-formed
+func validate(rule Rule) error {
+	switch rule.Type {
+	case RulePair:
+		if len(rule.Paths) != 2 || rule.Paths[0] == "" || rule.Paths[1] == "" {
+			return fmt.Errorf("pair rule needs two paths")
+		}
+	case RuleDigest:
+		if _, err := hex.DecodeString(rule.Digest); err != nil || rule.Digest == "" {
+			return fmt.Errorf("invalid digest: %s", rule.Digest)
+		}
+	case RuleGlob:
+		if _, err := glob.Compile(rule.Pattern); err != nil || rule.Pattern == "" {
+			return fmt.Errorf("invalid glob pattern: %s", rule.Pattern)
+		}
+	default:
+		return fmt.Errorf("unknown rule type: %s", rule.Type)
+	}
+	return nil
+}
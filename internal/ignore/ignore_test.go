@@ -0,0 +1,156 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tendant/dupe-cli/internal/fs"
+)
+
+// load loads the ignore list at path, failing the test on error
+func load(t *testing.T, path string) *List {
+	t.Helper()
+	l, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	l := load(t, filepath.Join(t.TempDir(), "ignore.json"))
+	if len(l.Rules) != 0 {
+		t.Errorf("got %d rules, want none", len(l.Rules))
+	}
+	if l.IgnoresFile(&fs.File{Path: "/a"}) || l.IgnoresPair(&fs.File{Path: "/a"}, &fs.File{Path: "/b"}) {
+		t.Error("empty list ignores files")
+	}
+}
+
+func TestRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config", "ignore.json")
+	l := load(t, path)
+
+	if !l.AddPair("/data/b", "/data/a") {
+		t.Error("AddPair of a new pair returned false")
+	}
+	if l.AddPair("/data/a", "/data/b") {
+		t.Error("AddPair of the same pair in the other order returned true")
+	}
+	if !l.AddDigest([]byte{0xab, 0xcd}) || l.AddDigest([]byte{0xab, 0xcd}) {
+		t.Error("AddDigest didn't add the digest exactly once")
+	}
+	if added, err := l.AddGlob("*.tmp"); !added || err != nil {
+		t.Errorf("AddGlob(*.tmp) = %v, %v", added, err)
+	}
+	if added, _ := l.AddGlob("*.tmp"); added {
+		t.Error("AddGlob of the same pattern returned true")
+	}
+	if _, err := l.AddGlob("[abc"); err == nil {
+		t.Error("AddGlob of an invalid pattern succeeded")
+	}
+	if err := l.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rules apply the same once reloaded
+	for name, l := range map[string]*List{"added": l, "reloaded": load(t, path)} {
+		if len(l.Rules) != 3 {
+			t.Errorf("%s: got %d rules, want 3", name, len(l.Rules))
+		}
+
+		tests := []struct {
+			file *fs.File
+			want bool
+		}{
+			{&fs.File{Path: "/data/x.tmp"}, true},
+			{&fs.File{Path: "/data/x.txt"}, false},
+			{&fs.File{Path: "/data/x.txt", Digest: []byte{0xab, 0xcd}}, true},
+			{&fs.File{Path: "/data/x.txt", Digest: []byte{0xab}}, false},
+		}
+		for _, tt := range tests {
+			if got := l.IgnoresFile(tt.file); got != tt.want {
+				t.Errorf("%s: IgnoresFile(%s, %x) = %v, want %v", name, tt.file.Path, tt.file.Digest, got, tt.want)
+			}
+		}
+
+		a, b, c := &fs.File{Path: "/data/a"}, &fs.File{Path: "/data/b"}, &fs.File{Path: "/data/c"}
+		if !l.IgnoresPair(a, b) || !l.IgnoresPair(b, a) {
+			t.Errorf("%s: pair rule doesn't ignore the pair in both orders", name)
+		}
+		if l.IgnoresPair(a, c) {
+			t.Errorf("%s: pair rule ignores another pair", name)
+		}
+	}
+}
+
+func TestRelativePaths(t *testing.T) {
+	dir := t.TempDir()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	l := load(t, filepath.Join(dir, "ignore.json"))
+	l.AddPair("a", "sub/../b")
+	if got, want := l.Rules[0].Paths, []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}; got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("pair rule paths = %v, want %v", got, want)
+	}
+	if !l.IgnoresPair(&fs.File{Path: filepath.Join(dir, "b")}, &fs.File{Path: "./a"}) {
+		t.Error("pair rule doesn't match relative and absolute paths of the pair")
+	}
+
+	l.AddGlob(filepath.Join(dir, "cache", "*"))
+	if !l.IgnoresFile(&fs.File{Path: "cache/x"}) {
+		t.Error("glob rule with an absolute pattern doesn't match a relative path")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	l := load(t, filepath.Join(t.TempDir(), "ignore.json"))
+	l.AddGlob("*.tmp")
+	l.AddPair("/a", "/b")
+
+	if err := l.Remove(2); err == nil {
+		t.Error("Remove of a missing rule succeeded")
+	}
+	if err := l.Remove(0); err != nil {
+		t.Fatal(err)
+	}
+	if l.IgnoresFile(&fs.File{Path: "/x.tmp"}) {
+		t.Error("removed glob rule still ignores files")
+	}
+	if !l.IgnoresPair(&fs.File{Path: "/a"}, &fs.File{Path: "/b"}) {
+		t.Error("remaining pair rule doesn't ignore its pair")
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"not json", `{`},
+		{"version", `{"version": 2, "rules": []}`},
+		{"pair", `{"version": 1, "rules": [{"type": "pair", "paths": ["/a"]}]}`},
+		{"digest", `{"version": 1, "rules": [{"type": "digest", "digest": "xyz"}]}`},
+		{"glob", `{"version": 1, "rules": [{"type": "glob", "pattern": ""}]}`},
+		{"type", `{"version": 1, "rules": [{"type": "name"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ignore.json")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(path); err == nil {
+				t.Error("Load succeeded")
+			}
+		})
+	}
+}
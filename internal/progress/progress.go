@@ -0,0 +1,232 @@
+package progress
+
+import (
+	"sync"
+	"time"
+)
+
+// Stage represents the phase of a scan
+type Stage int
+
+const (
+	// StageScanning is the directory walk that discovers files
+	StageScanning Stage = iota
+	// StageHashing is the content hashing of candidate files
+	StageHashing
+	// StageMatching is the filename comparison of candidate files
+	StageMatching
+	// StageDone is reported once when the scan has finished
+	StageDone
+)
+
+// String returns the name of the stage
+func (s Stage) String() string {
+	switch s {
+	case StageScanning:
+		return "scanning"
+	case StageHashing:
+		return "hashing"
+	case StageMatching:
+		return "matching"
+	case StageDone:
+		return "done"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a snapshot of the progress of a scan
+type Event struct {
+	Stage       Stage         // Current stage
+	FilesFound  int           // Files discovered by the directory walk
+	FilesDone   int           // Candidate files processed in the current stage
+	FilesTotal  int           // Candidate files to process in the current stage
+	BytesHashed int64         // Bytes read for hashing so far
+	BytesTotal  int64         // Estimated bytes to read for hashing
+	Elapsed     time.Duration // Time since the scan started
+	ETA         time.Duration // Estimated time remaining in the current stage (zero if unknown)
+}
+
+// Func receives progress events
+type Func func(Event)
+
+// Channel returns a Func that sends events to ch.
+// Events are dropped rather than blocking the scan when ch is full.
+func Channel(ch chan<- Event) Func {
+	return func(event Event) {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Tracker accumulates progress counters and emits throttled events. The
+// receiver is called without the tracker's lock held, by one goroutine at a
+// time: an event emitted while another is being received waits for it, and
+// is replaced by any later one.
+type Tracker struct {
+	fn         Func             // Receiver of events (may be nil)
+	interval   time.Duration    // Minimum time between two events of the same stage
+	now        func() time.Time // Clock of the tracker
+	mu         sync.Mutex       // Mutex for thread safety
+	start      time.Time        // Start of the scan
+	stageStart time.Time        // Start of the current stage
+	lastEmit   time.Time        // Time of the last emitted event
+	event      Event            // Current counters
+	sending    bool             // Whether a goroutine is calling fn
+	pending    *Event           // Latest event waiting for the call to fn to return
+}
+
+// NewTracker creates a new Tracker that reports to fn at most once per interval
+func NewTracker(fn Func, interval time.Duration) *Tracker {
+	return newTracker(fn, interval, time.Now)
+}
+
+// newTracker creates a Tracker with a clock
+func newTracker(fn Func, interval time.Duration, now func() time.Time) *Tracker {
+	start := now()
+	return &Tracker{
+		fn:         fn,
+		interval:   interval,
+		now:        now,
+		start:      start,
+		stageStart: start,
+	}
+}
+
+// SetStage starts a new stage and emits an event immediately
+func (t *Tracker) SetStage(stage Stage, filesTotal int, bytesTotal int64) {
+	t.mu.Lock()
+	t.stageStart = t.now()
+	t.event.Stage = stage
+	t.event.FilesDone = 0
+	t.event.FilesTotal = filesTotal
+	t.event.BytesHashed = 0
+	t.event.BytesTotal = bytesTotal
+	event, ok := t.next(true)
+	t.mu.Unlock()
+
+	t.send(event, ok)
+}
+
+// FileFound records a file discovered by the directory walk
+func (t *Tracker) FileFound() {
+	t.mu.Lock()
+	t.event.FilesFound++
+	event, ok := t.next(false)
+	t.mu.Unlock()
+
+	t.send(event, ok)
+}
+
+// AddBytesTotal grows the estimate of bytes to hash, e.g. when a partial
+// hash match means a full hash is needed after all
+func (t *Tracker) AddBytesTotal(bytes int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.event.BytesTotal += bytes
+}
+
+// BytesHashed records bytes read for hashing
+func (t *Tracker) BytesHashed(bytes int64) {
+	t.mu.Lock()
+	t.event.BytesHashed += bytes
+	event, ok := t.next(false)
+	t.mu.Unlock()
+
+	t.send(event, ok)
+}
+
+// FilesDone records candidate files processed in the current stage
+func (t *Tracker) FilesDone(count int) {
+	t.mu.Lock()
+	t.event.FilesDone += count
+	event, ok := t.next(false)
+	t.mu.Unlock()
+
+	t.send(event, ok)
+}
+
+// Finish emits the final event
+func (t *Tracker) Finish() {
+	t.mu.Lock()
+	t.event.Stage = StageDone
+	t.event.FilesDone = t.event.FilesTotal
+	event, ok := t.next(true)
+	t.mu.Unlock()
+
+	t.send(event, ok)
+}
+
+// next returns the event of the current counters, with the lock held, and
+// whether the caller sends it. It returns false if the event is throttled,
+// or if it is left for the goroutine calling fn to send.
+func (t *Tracker) next(force bool) (Event, bool) {
+	if t.fn == nil {
+		return Event{}, false
+	}
+
+	now := t.now()
+	if !force && now.Sub(t.lastEmit) < t.interval {
+		return Event{}, false
+	}
+	t.lastEmit = now
+
+	event := t.event
+	event.Elapsed = now.Sub(t.start)
+	event.ETA = t.eta(now)
+	if t.sending {
+		t.pending = &event
+		return Event{}, false
+	}
+	t.sending = true
+	return event, true
+}
+
+// send calls fn with an event returned by next, then with the events left
+// pending meanwhile
+func (t *Tracker) send(event Event, ok bool) {
+	if !ok {
+		return
+	}
+	for {
+		t.fn(event)
+
+		t.mu.Lock()
+		if t.pending == nil {
+			t.sending = false
+			t.mu.Unlock()
+			return
+		}
+		event = *t.pending
+		t.pending = nil
+		t.mu.Unlock()
+	}
+}
+
+// eta estimates the time remaining in the current stage from its throughput so far
+func (t *Tracker) eta(now time.Time) time.Duration {
+	elapsed := now.Sub(t.stageStart)
+	if elapsed <= 0 {
+		return 0
+	}
+
+	var done, total float64
+	switch {
+	case t.event.Stage == StageHashing && t.event.BytesTotal > 0:
+		done, total = float64(t.event.BytesHashed), float64(t.event.BytesTotal)
+	case t.event.FilesTotal > 0:
+		done, total = float64(t.event.FilesDone), float64(t.event.FilesTotal)
+	default:
+		return 0
+	}
+
+	if done <= 0 || done >= total {
+		return 0
+	}
+
+	rate := done / elapsed.Seconds()
+	return time.Duration((total - done) / rate * float64(time.Second))
+}
@@ -0,0 +1,183 @@
+package progress
+
+import (
+	"testing"
+	"time"
+)
+
+// clock is a clock that only moves when advanced
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+func newClock() *clock                   { return &clock{t: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)} }
+
+// recorder returns a Func that records events, and the recorded events
+func recorder() (Func, *[]Event) {
+	var events []Event
+	return func(event Event) { events = append(events, event) }, &events
+}
+
+func TestTrackerThrottle(t *testing.T) {
+	c := newClock()
+	fn, events := recorder()
+	tr := newTracker(fn, time.Second, c.now)
+
+	// Stage changes are emitted at once, other events once per interval
+	tr.SetStage(StageScanning, 0, 0)
+	tr.FileFound()
+	c.advance(500 * time.Millisecond)
+	tr.FileFound()
+	c.advance(500 * time.Millisecond)
+	tr.FileFound()
+	tr.FileFound()
+	tr.SetStage(StageHashing, 10, 100)
+	tr.BytesHashed(10)
+
+	want := []struct {
+		stage Stage
+		found int
+	}{
+		{StageScanning, 0},
+		{StageScanning, 3},
+		{StageHashing, 4},
+	}
+	if len(*events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(*events), len(want), *events)
+	}
+	for i, w := range want {
+		if got := (*events)[i]; got.Stage != w.stage || got.FilesFound != w.found {
+			t.Errorf("event %d = %+v, want stage %v with %d files found", i, got, w.stage, w.found)
+		}
+	}
+	if got := (*events)[1].Elapsed; got != time.Second {
+		t.Errorf("elapsed = %v, want 1s", got)
+	}
+}
+
+func TestTrackerETA(t *testing.T) {
+	c := newClock()
+	fn, events := recorder()
+	tr := newTracker(fn, 0, c.now)
+
+	// Hashing is estimated from the bytes hashed, other stages from the files done
+	tr.SetStage(StageHashing, 4, 1000)
+	c.advance(10 * time.Second)
+	tr.BytesHashed(250)
+	tr.AddBytesTotal(1000)
+	c.advance(10 * time.Second)
+	tr.FilesDone(1)
+	tr.SetStage(StageMatching, 4, 0)
+	c.advance(2 * time.Second)
+	tr.FilesDone(1)
+	tr.FilesDone(3)
+
+	want := []time.Duration{
+		0,                 // Nothing done yet
+		30 * time.Second,  // 250 of 1000 bytes in 10s
+		140 * time.Second, // 250 of 2000 bytes in 20s
+		0,                 // New stage
+		6 * time.Second,   // 1 of 4 files in 2s
+		0,                 // All files done
+	}
+	if len(*events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(*events), len(want))
+	}
+	for i, eta := range want {
+		if got := (*events)[i].ETA; got != eta {
+			t.Errorf("event %d: ETA = %v, want %v", i, got, eta)
+		}
+	}
+}
+
+func TestTrackerFinish(t *testing.T) {
+	c := newClock()
+	fn, events := recorder()
+	tr := newTracker(fn, time.Hour, c.now)
+
+	tr.SetStage(StageMatching, 5, 0)
+	tr.FilesDone(2)
+	c.advance(3 * time.Second)
+	tr.Finish()
+
+	if len(*events) != 2 {
+		t.Fatalf("got %d events, want the stage change and the final event", len(*events))
+	}
+	final := (*events)[1]
+	if final.Stage != StageDone || final.FilesDone != 5 || final.ETA != 0 || final.Elapsed != 3*time.Second {
+		t.Errorf("final event = %+v", final)
+	}
+}
+
+func TestTrackerWithoutReceiver(t *testing.T) {
+	tr := NewTracker(nil, 0)
+	tr.SetStage(StageScanning, 0, 0)
+	tr.FileFound()
+	tr.Finish()
+}
+
+func TestTrackerReentrantReceiver(t *testing.T) {
+	c := newClock()
+	var tr *Tracker
+	var events []Event
+	tr = newTracker(func(event Event) {
+		events = append(events, event)
+		// Events emitted by the receiver are sent once it returns
+		if event.Stage == StageScanning && event.FilesFound == 0 {
+			tr.FileFound()
+		}
+	}, 0, c.now)
+
+	tr.SetStage(StageScanning, 0, 0)
+	if len(events) != 2 || events[1].FilesFound != 1 {
+		t.Errorf("got events %+v, want the stage change then the file found", events)
+	}
+}
+
+func TestTrackerSlowReceiver(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	var events []Event
+	tr := newTracker(func(event Event) {
+		events = append(events, event)
+		if len(events) == 1 {
+			close(entered)
+			<-release
+		}
+	}, 0, newClock().now)
+
+	done := make(chan struct{})
+	go func() {
+		tr.SetStage(StageScanning, 0, 0)
+		close(done)
+	}()
+	<-entered
+
+	// Other goroutines go on while the receiver is busy; the latest of
+	// their events is sent when it returns
+	tr.FileFound()
+	tr.FileFound()
+	close(release)
+	<-done
+
+	if len(events) != 2 || events[1].FilesFound != 2 {
+		t.Errorf("got events %+v, want the stage change then 2 files found", events)
+	}
+}
+
+func TestChannel(t *testing.T) {
+	ch := make(chan Event, 1)
+	fn := Channel(ch)
+	fn(Event{FilesFound: 1})
+	fn(Event{FilesFound: 2})
+	if got := <-ch; got.FilesFound != 1 {
+		t.Errorf("got event %+v, want the first one", got)
+	}
+	select {
+	case event := <-ch:
+		t.Errorf("got event %+v, want it dropped", event)
+	default:
+	}
+}
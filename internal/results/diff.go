@@ -0,0 +1,118 @@
+package results
+
+import "sort"
+
+// Diff is the difference between the duplicate groups of two result sets
+type Diff struct {
+	New       []Group       `json:"new"`      // Groups sharing no file with the old groups
+	Resolved  []Group       `json:"resolved"` // Old groups sharing no file with the new groups
+	Changed   []GroupChange `json:"changed"`  // Groups whose files changed
+	Unchanged int           `json:"unchanged"`
+}
+
+// GroupChange is a group of both result sets whose files changed
+type GroupChange struct {
+	Old     Group    `json:"old"`
+	New     Group    `json:"new"`
+	Added   []string `json:"added"`   // Paths only in the new group
+	Removed []string `json:"removed"` // Paths only in the old group
+}
+
+// Empty reports whether the result sets have the same groups
+func (d *Diff) Empty() bool {
+	return len(d.New) == 0 && len(d.Resolved) == 0 && len(d.Changed) == 0
+}
+
+// Compare compares the groups of two result sets. Each new group is paired
+// with the old group it shares the most files with; an old group is paired
+// at most once, so the parts of a group that was split are new groups.
+func Compare(before, after []Group) *Diff {
+	d := &Diff{
+		New:      make([]Group, 0),
+		Resolved: make([]Group, 0),
+		Changed:  make([]GroupChange, 0),
+	}
+
+	oldGroupOf := make(map[string]int)
+	for i, group := range before {
+		for _, path := range group.Paths() {
+			oldGroupOf[path] = i
+		}
+	}
+
+	paired := make([]bool, len(before))
+	for _, group := range after {
+		shared := make(map[int]int)
+		for _, path := range group.Paths() {
+			if i, ok := oldGroupOf[path]; ok && !paired[i] {
+				shared[i]++
+			}
+		}
+
+		best, bestCount := -1, 0
+		for i, count := range shared {
+			if count > bestCount || (count == bestCount && i < best) {
+				best, bestCount = i, count
+			}
+		}
+		if best < 0 {
+			d.New = append(d.New, group)
+			continue
+		}
+		paired[best] = true
+
+		added, removed := diffPaths(before[best].Paths(), group.Paths())
+		if len(added) == 0 && len(removed) == 0 {
+			d.Unchanged++
+			continue
+		}
+		d.Changed = append(d.Changed, GroupChange{
+			Old:     before[best],
+			New:     group,
+			Added:   added,
+			Removed: removed,
+		})
+	}
+
+	for i, group := range before {
+		if !paired[i] {
+			d.Resolved = append(d.Resolved, group)
+		}
+	}
+
+	return d
+}
+
+// Paths returns the paths of the reference and the duplicates of a group
+func (g Group) Paths() []string {
+	paths := make([]string, 0, len(g.Duplicates)+1)
+	paths = append(paths, g.Reference.Path)
+	for _, dupe := range g.Duplicates {
+		paths = append(paths, dupe.Path)
+	}
+	return paths
+}
+
+// diffPaths returns the sorted paths only in after and only in before
+func diffPaths(before, after []string) (added, removed []string) {
+	added, removed = make([]string, 0), make([]string, 0)
+	inBefore := make(map[string]bool, len(before))
+	for _, path := range before {
+		inBefore[path] = true
+	}
+	inAfter := make(map[string]bool, len(after))
+	for _, path := range after {
+		inAfter[path] = true
+		if !inBefore[path] {
+			added = append(added, path)
+		}
+	}
+	for _, path := range before {
+		if !inAfter[path] {
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
@@ -0,0 +1,87 @@
+package results
+
+import (
+	"reflect"
+	"testing"
+)
+
+// group returns a saved group of paths, the first being the reference
+func group(paths ...string) Group {
+	g := Group{Reference: File{Path: paths[0]}}
+	for _, path := range paths[1:] {
+		g.Duplicates = append(g.Duplicates, File{Path: path})
+	}
+	return g
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after []Group
+		want          *Diff
+	}{
+		{
+			name:   "unchanged",
+			before: []Group{group("a", "b"), group("c", "d")},
+			after:  []Group{group("c", "d"), group("a", "b")},
+			want:   &Diff{New: []Group{}, Resolved: []Group{}, Changed: []GroupChange{}, Unchanged: 2},
+		},
+		{
+			name:   "new and resolved",
+			before: []Group{group("a", "b")},
+			after:  []Group{group("c", "d")},
+			want:   &Diff{New: []Group{group("c", "d")}, Resolved: []Group{group("a", "b")}, Changed: []GroupChange{}},
+		},
+		{
+			name:   "members changed",
+			before: []Group{group("a", "b", "c")},
+			after:  []Group{group("b", "a", "e", "d")},
+			want: &Diff{New: []Group{}, Resolved: []Group{}, Changed: []GroupChange{{
+				Old:     group("a", "b", "c"),
+				New:     group("b", "a", "e", "d"),
+				Added:   []string{"d", "e"},
+				Removed: []string{"c"},
+			}}},
+		},
+		{
+			// The first part is paired with the old group, the other is new
+			name:   "split group",
+			before: []Group{group("a", "b", "c", "d", "e")},
+			after:  []Group{group("a", "b"), group("c", "d", "e")},
+			want: &Diff{New: []Group{group("c", "d", "e")}, Resolved: []Group{}, Changed: []GroupChange{{
+				Old:     group("a", "b", "c", "d", "e"),
+				New:     group("a", "b"),
+				Added:   []string{},
+				Removed: []string{"c", "d", "e"},
+			}}},
+		},
+		{
+			// The merged group is paired with the first of two equal matches
+			name:   "merged groups",
+			before: []Group{group("a", "b"), group("c", "d")},
+			after:  []Group{group("a", "b", "c", "d")},
+			want: &Diff{New: []Group{}, Resolved: []Group{group("c", "d")}, Changed: []GroupChange{{
+				Old:     group("a", "b"),
+				New:     group("a", "b", "c", "d"),
+				Added:   []string{"c", "d"},
+				Removed: []string{},
+			}}},
+		},
+		{
+			name:  "no previous results",
+			after: []Group{group("a", "b")},
+			want:  &Diff{New: []Group{group("a", "b")}, Resolved: []Group{}, Changed: []GroupChange{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Compare(tt.before, tt.after)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if got.Empty() != (tt.want.Unchanged == len(tt.after)) {
+				t.Errorf("Empty() = %v", got.Empty())
+			}
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package results
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+	"github.com/tendant/dupe-cli/internal/hash"
+	"github.com/tendant/dupe-cli/internal/matcher"
+)
+
+// Version is the version of the result file format
+const Version = 1
+
+// ErrChanged is returned by File.Verify when a file differs from the saved metadata
+var ErrChanged = errors.New("file has changed since the scan")
+
+// ResultSet is a saved scan result
+type ResultSet struct {
+	Version   int             `json:"version"`
+	CreatedAt time.Time       `json:"created_at"`
+	ScanTime  string          `json:"scan_time"`
+	Params    json.RawMessage `json:"params,omitempty"` // Parameters of the scan, as defined by the caller
+	Groups    []Group         `json:"groups"`
+	Errors    []Error         `json:"errors,omitempty"`
+
+	// Fingerprint of the files of each size the scan compared, with which
+	// an incremental scan finds the sizes whose groups are unchanged
+	Fingerprints map[int64]string `json:"fingerprints,omitempty"`
+}
+
+// Group is a saved duplicate group
+type Group struct {
+	Reference  File    `json:"reference"`
+	Duplicates []File  `json:"duplicates"`
+	Matches    []Match `json:"matches"` // Matches between the reference and each duplicate
+}
+
+// File is the metadata of a file in a duplicate group
+type File struct {
+	Path        string    `json:"path"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mtime"`
+	Digest      string    `json:"digest,omitempty"`      // Hex-encoded full digest, if it was calculated
+	DigestPart  string    `json:"digest_part,omitempty"` // Hex-encoded partial digest, if it was calculated
+	IsReference bool      `json:"is_reference,omitempty"`
+	Dev         uint64    `json:"dev,omitempty"`
+	Inode       uint64    `json:"inode,omitempty"`
+}
+
+// Match is a saved match between two files
+type Match struct {
+	First      string `json:"first"`
+	Second     string `json:"second"`
+	Percentage int    `json:"percentage"`
+}
+
+// Error is an error for a path that was skipped by the scan
+type Error struct {
+	Path     string `json:"path"`
+	Category string `json:"category"`
+	Message  string `json:"message"`
+}
+
+// New creates a ResultSet from the results of a scan
+func New(groups []*engine.DuplicateGroup, errs []*fs.PathError, params json.RawMessage, scanTime time.Duration) *ResultSet {
+	r := &ResultSet{
+		Version:   Version,
+		CreatedAt: time.Now(),
+		ScanTime:  scanTime.String(),
+		Params:    params,
+		Groups:    make([]Group, 0, len(groups)),
+		Errors:    make([]Error, 0, len(errs)),
+	}
+
+	for _, group := range groups {
+		g := Group{
+			Reference:  newFile(group.Reference),
+			Duplicates: make([]File, 0, len(group.Duplicates)),
+			Matches:    make([]Match, 0, len(group.Matches)),
+		}
+		for _, dupe := range group.Duplicates {
+			g.Duplicates = append(g.Duplicates, newFile(dupe))
+		}
+		for _, match := range group.Matches {
+			g.Matches = append(g.Matches, Match{
+				First:      match.First.Path,
+				Second:     match.Second.Path,
+				Percentage: match.Percentage,
+			})
+		}
+		r.Groups = append(r.Groups, g)
+	}
+
+	for _, pathErr := range errs {
+		r.Errors = append(r.Errors, Error{
+			Path:     pathErr.Path,
+			Category: pathErr.Category.String(),
+			Message:  pathErr.Err.Error(),
+		})
+	}
+
+	return r
+}
+
+// newFile converts a scanned file to its saved metadata
+func newFile(file *fs.File) File {
+	return File{
+		Path:        file.Path,
+		Size:        file.Size,
+		ModTime:     file.ModTime,
+		Digest:      hex.EncodeToString(file.Digest),
+		DigestPart:  hex.EncodeToString(file.DigestPart),
+		IsReference: file.IsReference,
+		Dev:         file.Dev,
+		Inode:       file.Inode,
+	}
+}
+
+// Load reads a result file
+func Load(path string) (*ResultSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var r ResultSet
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("invalid result file %s: %w", path, err)
+	}
+	if r.Version != Version {
+		return nil, fmt.Errorf("unsupported result file version %d in %s", r.Version, path)
+	}
+
+	return &r, nil
+}
+
+// Save writes the result file
+func (r *ResultSet) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// DuplicateGroups converts the saved groups back to duplicate groups
+func (r *ResultSet) DuplicateGroups() []*engine.DuplicateGroup {
+	groups := make([]*engine.DuplicateGroup, 0, len(r.Groups))
+
+	for _, g := range r.Groups {
+		files := make(map[string]*fs.File, len(g.Duplicates)+1)
+		group := &engine.DuplicateGroup{
+			Reference:  g.Reference.toFile(),
+			Duplicates: make([]*fs.File, 0, len(g.Duplicates)),
+			Matches:    make([]*matcher.Match, 0, len(g.Matches)),
+		}
+		files[group.Reference.Path] = group.Reference
+
+		for _, dupe := range g.Duplicates {
+			file := dupe.toFile()
+			files[file.Path] = file
+			group.Duplicates = append(group.Duplicates, file)
+		}
+
+		for _, m := range g.Matches {
+			group.Matches = append(group.Matches, &matcher.Match{
+				First:      files[m.First],
+				Second:     files[m.Second],
+				Percentage: m.Percentage,
+			})
+		}
+
+		groups = append(groups, group)
+	}
+
+	return groups
+}
+
+// Seed returns the groups of the results for an incremental scan to update
+func (r *ResultSet) Seed() *engine.Seed {
+	return &engine.Seed{
+		Fingerprints: r.Fingerprints,
+		Groups:       r.DuplicateGroups(),
+	}
+}
+
+// PathErrors converts the saved errors back to path errors
+func (r *ResultSet) PathErrors() []*fs.PathError {
+	errs := make([]*fs.PathError, 0, len(r.Errors))
+	for _, e := range r.Errors {
+		errs = append(errs, &fs.PathError{
+			Path:     e.Path,
+			Category: fs.ParseErrorCategory(e.Category),
+			Err:      errors.New(e.Message),
+		})
+	}
+	return errs
+}
+
+// ScanDuration returns the duration of the scan that produced the results
+func (r *ResultSet) ScanDuration() time.Duration {
+	d, _ := time.ParseDuration(r.ScanTime)
+	return d
+}
+
+// FilterDuplicates returns a copy of the group with only the duplicates,
+// and their matches, for which keep returns true
+func (g Group) FilterDuplicates(keep func(dupe File, match Match) bool) Group {
+	filtered := Group{
+		Reference:  g.Reference,
+		Duplicates: make([]File, 0, len(g.Duplicates)),
+		Matches:    make([]Match, 0, len(g.Matches)),
+	}
+	for j, dupe := range g.Duplicates {
+		var match Match
+		if j < len(g.Matches) {
+			match = g.Matches[j]
+		}
+		if keep(dupe, match) {
+			filtered.Duplicates = append(filtered.Duplicates, dupe)
+			filtered.Matches = append(filtered.Matches, match)
+		}
+	}
+	return filtered
+}
+
+// toFile converts saved metadata to a file
+func (f File) toFile() *fs.File {
+	return &fs.File{
+		Path:        f.Path,
+		Name:        filepath.Base(f.Path),
+		Size:        f.Size,
+		ModTime:     f.ModTime,
+		Digest:      decodeDigest(f.Digest),
+		DigestPart:  decodeDigest(f.DigestPart),
+		IsReference: f.IsReference,
+		Dev:         f.Dev,
+		Inode:       f.Inode,
+	}
+}
+
+// decodeDigest decodes a hex-encoded digest, nil if it is empty or invalid
+func decodeDigest(s string) []byte {
+	digest, err := hex.DecodeString(s)
+	if err != nil || len(digest) == 0 {
+		return nil
+	}
+	return digest
+}
+
+// Verify checks that the file is unchanged since the scan: same size,
+// same modification time and, if it was recorded, the same digest.
+// It returns the hex-encoded digest of the file, which is calculated even
+// if none was recorded, so that files can be compared by content.
+// It returns ErrChanged if the file differs, or the error from reading it.
+func (f File) Verify() (string, error) {
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() || info.Size() != f.Size || !info.ModTime().Equal(f.ModTime) {
+		return "", ErrChanged
+	}
+
+	digest, err := hash.HashFile(f.Path)
+	if err != nil {
+		return "", err
+	}
+	if f.Digest != "" && hex.EncodeToString(digest) != f.Digest {
+		return "", ErrChanged
+	}
+	return hex.EncodeToString(digest), nil
+}
+
+// Digests holds the digests of the files of saved results, so that an
+// incremental scan doesn't hash the files that are unchanged since
+type Digests struct {
+	cwd   string          // Working directory relative paths are resolved against
+	files map[string]File // Files with digests, by absolute path
+}
+
+// Digests returns the digests of the files of the groups. Relative paths
+// are resolved against the working directory.
+func (r *ResultSet) Digests() *Digests {
+	d := &Digests{files: make(map[string]File)}
+	d.cwd, _ = os.Getwd()
+	for _, g := range r.Groups {
+		for _, f := range append([]File{g.Reference}, g.Duplicates...) {
+			if f.Digest != "" || f.DigestPart != "" {
+				d.files[d.abs(f.Path)] = f
+			}
+		}
+	}
+	return d
+}
+
+// Len returns the number of files with digests
+func (d *Digests) Len() int {
+	return len(d.files)
+}
+
+// Apply sets the digests of a file from the saved results if the file has
+// the same size, modification time and inode as when they were saved, and
+// reports whether it did
+func (d *Digests) Apply(file *fs.File) bool {
+	saved, ok := d.files[d.abs(file.Path)]
+	if !ok || saved.Size != file.Size || !saved.ModTime.Equal(file.ModTime) {
+		return false
+	}
+	// A file replaced by another one with the same size and time
+	if saved.Inode != 0 && file.Inode != 0 && (saved.Dev != file.Dev || saved.Inode != file.Inode) {
+		return false
+	}
+
+	if digest := decodeDigest(saved.Digest); digest != nil {
+		file.Digest = digest
+	}
+	if digest := decodeDigest(saved.DigestPart); digest != nil {
+		file.DigestPart = digest
+	}
+	return file.Digest != nil || file.DigestPart != nil
+}
+
+// abs makes a path absolute against the working directory
+func (d *Digests) abs(path string) string {
+	if filepath.IsAbs(path) || d.cwd == "" {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(d.cwd, path)
+}
@@ -0,0 +1,61 @@
+package results
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/fs"
+)
+
+func TestDigestsApply(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	saved := File{Path: "a", Size: 4, ModTime: modTime, Digest: "abcd", DigestPart: "ef", Dev: 1, Inode: 7}
+	r := &ResultSet{Groups: []Group{{
+		Reference:  saved,
+		Duplicates: []File{{Path: filepath.Join(dir, "b"), Size: 4, ModTime: modTime}},
+	}}}
+
+	d := r.Digests()
+	if got := d.Len(); got != 1 {
+		t.Errorf("Len() = %d, want 1, the file without digests left out", got)
+	}
+
+	tests := []struct {
+		name string
+		file fs.File
+		want bool
+	}{
+		{"unchanged, by absolute path", fs.File{Path: filepath.Join(dir, "a"), Size: 4, ModTime: modTime, Dev: 1, Inode: 7}, true},
+		{"unchanged, by relative path", fs.File{Path: "./a", Size: 4, ModTime: modTime, Dev: 1, Inode: 7}, true},
+		{"inode unknown", fs.File{Path: "a", Size: 4, ModTime: modTime}, true},
+		{"other size", fs.File{Path: "a", Size: 5, ModTime: modTime, Dev: 1, Inode: 7}, false},
+		{"other time", fs.File{Path: "a", Size: 4, ModTime: modTime.Add(time.Second), Dev: 1, Inode: 7}, false},
+		{"replaced", fs.File{Path: "a", Size: 4, ModTime: modTime, Dev: 1, Inode: 8}, false},
+		{"without digests", fs.File{Path: "b", Size: 4, ModTime: modTime}, false},
+		{"not saved", fs.File{Path: "c", Size: 4, ModTime: modTime}, false},
+	}
+	for _, tt := range tests {
+		file := tt.file
+		if got := d.Apply(&file); got != tt.want {
+			t.Errorf("%s: Apply() = %v, want %v", tt.name, got, tt.want)
+		}
+		if applied := file.Digest != nil; applied != tt.want {
+			t.Errorf("%s: digest %x applied %v, want %v", tt.name, file.Digest, applied, tt.want)
+		}
+		if tt.want && (string(file.Digest) != "\xab\xcd" || string(file.DigestPart) != "\xef") {
+			t.Errorf("%s: got digests %x and %x", tt.name, file.Digest, file.DigestPart)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package scanner
+
+// EmptyPolicy decides what a scan does with empty files
+type EmptyPolicy string
+
+const (
+	EmptySkip       EmptyPolicy = "skip"       // Don't compare empty files
+	EmptyReport     EmptyPolicy = "report"     // Don't compare empty files, but report them separately
+	EmptyDuplicates EmptyPolicy = "duplicates" // Compare empty files like any other, as duplicates of each other
+)
+
+// EmptyPolicies are the names of the empty file policies
+var EmptyPolicies = []string{string(EmptySkip), string(EmptyReport), string(EmptyDuplicates)}
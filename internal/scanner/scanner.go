@@ -1,16 +1,20 @@
 package scanner
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strings"
+	"sort"
 	"sync"
 
 	"github.com/tendant/dupe-cli/internal/fs"
+	"github.com/tendant/dupe-cli/internal/glob"
 )
 
+// DefaultReaders is the default number of directories read at the same time
+const DefaultReaders = 8
+
 // ScanType represents the type of scan to perform
 type ScanType int
 
@@ -21,29 +25,61 @@ const (
 	ScanTypeContent
 )
 
+// RootState is the progress of the walk of one of the scanner's directories,
+// used to resume an interrupted scan
+type RootState struct {
+	WalkedDirs []string          // Directories whose whole subtree has been walked
+	Files      []*fs.File        // Files found in the walked directories
+	Errors     []*fs.PathError   // Errors found in the walked directories
+	Skipped    []*fs.SkippedPath // Reported skipped paths found in the walked directories
+}
+
 // Scanner is responsible for scanning directories and finding files
 type Scanner struct {
-	Directories    []string             // Directories to scan
-	ExcludePattern *regexp.Regexp       // Pattern to exclude files
-	Recursive      bool                 // Whether to scan recursively
-	ScanType       ScanType             // Type of scan to perform
-	MinMatchPct    int                  // Minimum match percentage for fuzzy matching
-	RefDirs        map[string]bool      // Reference directories (files won't be marked for deletion)
-	mu             sync.Mutex           // Mutex for thread safety
-	files          []*fs.File           // Collected files
-	filesBySize    map[int64][]*fs.File // Files grouped by size
-}
-
-// NewScanner creates a new Scanner instance
-func NewScanner(dirs []string, exclude string, recursive bool, scanType ScanType, minMatch int) *Scanner {
-	var excludePattern *regexp.Regexp
-	if exclude != "" {
-		// Convert glob patterns to regex
-		regexStr := strings.Replace(exclude, ".", "\\.", -1)
-		regexStr = strings.Replace(regexStr, "*", ".*", -1)
-		regexStr = strings.Replace(regexStr, "?", ".", -1)
-		regexStr = "^(" + strings.Replace(regexStr, ",", "|", -1) + ")$"
-		excludePattern = regexp.MustCompile(regexStr)
+	Directories    []string         // Directories to scan
+	Files          []string         // Files to scan, in addition to the directories (directories among them are left out)
+	ExcludePattern *glob.Glob       // Patterns of the names of files to exclude
+	Recursive      bool             // Whether to scan recursively
+	ScanType       ScanType         // Type of scan to perform
+	MinMatchPct    int              // Minimum match percentage for fuzzy matching
+	RefDirs        map[string]bool  // Reference directories (files won't be marked for deletion)
+	OnFile         func(*fs.File)   // Called for each file found (may be nil)
+	Strict         bool             // Whether to abort the scan on the first error
+	Resume         []*RootState     // Walk progress of a previous scan, indexed like Directories (may be nil)
+	Filters        []fs.FileFilter  // Filters a file must pass to be scanned
+	DirFilters     []fs.DirFilter   // Filters a subdirectory must pass to be walked
+	Symlinks       fs.SymlinkPolicy // What the walk does with symbolic links (skips them if empty)
+	Empty          EmptyPolicy      // What the scan does with empty files (skips them if empty)
+	Readers        int              // Number of directories read at the same time (1 if 0)
+	MaxDepth       int              // Levels of subdirectories walked when recursive (no limit if 0)
+	OneFileSystem  bool             // Whether to skip directories on other filesystems than the scanned ones
+
+	// OnDirDone is called when a directory and its whole subtree have been walked,
+	// with the index of the scanned directory it belongs to and the files,
+	// errors and reported skipped paths found directly in it (may be nil)
+	OnDirDone func(root int, dir string, files []*fs.File, errs []*fs.PathError, skipped []*fs.SkippedPath)
+
+	mu          sync.Mutex           // Mutex for thread safety
+	files       []*fs.File           // Collected files
+	filesBySize map[int64][]*fs.File // Files grouped by size
+	errors      []*fs.PathError      // Errors for paths that were skipped
+	skipped     []*fs.SkippedPath    // Paths that were skipped and are reported
+	empty       []*fs.File           // Empty files that are reported
+}
+
+// errDirectory is the error of ScanFile for a directory
+var errDirectory = errors.New("is a directory, not a file")
+
+// NewScanner creates a new Scanner instance. exclude holds comma-separated
+// glob patterns of the names of files to exclude.
+func NewScanner(dirs []string, exclude string, recursive bool, scanType ScanType, minMatch int) (*Scanner, error) {
+	var excludePattern *glob.Glob
+	if patterns := glob.Split(exclude); len(patterns) > 0 {
+		var err error
+		excludePattern, err = glob.Compile(patterns...)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &Scanner{
@@ -52,9 +88,10 @@ func NewScanner(dirs []string, exclude string, recursive bool, scanType ScanType
 		Recursive:      recursive,
 		ScanType:       scanType,
 		MinMatchPct:    minMatch,
+		Empty:          EmptySkip,
 		RefDirs:        make(map[string]bool),
 		filesBySize:    make(map[int64][]*fs.File),
-	}
+	}, nil
 }
 
 // SetReferenceDir marks a directory as a reference directory
@@ -71,49 +108,264 @@ func (s *Scanner) Scan() ([]*fs.File, error) {
 
 	s.files = make([]*fs.File, 0)
 	s.filesBySize = make(map[int64][]*fs.File)
+	s.errors = nil
+	s.skipped = nil
+	s.empty = nil
 
-	for _, dirPath := range s.Directories {
-		// Create directory object
-		dir, err := fs.NewDirectory(dirPath)
+	for i, dirPath := range s.Directories {
+		dir, err := s.newDirectory(dirPath)
 		if err != nil {
+			if !s.Strict {
+				s.errors = append(s.errors, fs.NewPathError(dirPath, err))
+				continue
+			}
 			return nil, fmt.Errorf("error creating directory object for %s: %w", dirPath, err)
 		}
-
-		// Set exclude pattern
-		if s.ExcludePattern != nil {
-			dir.ExcludePattern = s.ExcludePattern
-		}
-
-		// Check if this is a reference directory
-		if s.RefDirs[dirPath] {
-			dir.IsReference = true
+		dir.OnFile = s.OnFile
+		if s.OnDirDone != nil {
+			root := i
+			dir.OnDirDone = func(path string, files []*fs.File, errs []*fs.PathError, skipped []*fs.SkippedPath) {
+				s.OnDirDone(root, path, files, errs, skipped)
+			}
 		}
 
 		// Scan directory for files
-		err = s.scanDirectory(dir)
+		err = s.scanDirectory(dir, s.resumeState(i))
 		if err != nil {
 			return nil, fmt.Errorf("error scanning directory %s: %w", dirPath, err)
 		}
 	}
 
+	if err := s.scanFiles(); err != nil {
+		return nil, err
+	}
+
+	if s.Symlinks == fs.SymlinksFollow {
+		s.dropLinkedCopies()
+	}
 	return s.files, nil
 }
 
-// scanDirectory scans a directory for files
-func (s *Scanner) scanDirectory(dir *fs.Directory) error {
+// newDirectory creates the Directory of a directory to walk, with the
+// settings of the scanner
+func (s *Scanner) newDirectory(path string) (*fs.Directory, error) {
+	dir, err := fs.NewDirectory(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set exclude pattern
+	if s.ExcludePattern != nil {
+		dir.ExcludePattern = s.ExcludePattern
+	}
+
+	dir.Strict = s.Strict
+	dir.Filters = s.Filters
+	dir.DirFilters = s.DirFilters
+	dir.Symlinks = s.Symlinks
+	dir.Readers = s.Readers
+	dir.MaxDepth = s.MaxDepth
+	dir.OneFileSystem = s.OneFileSystem
+
+	// Check if this is a reference directory
+	if s.RefDirs[path] {
+		dir.IsReference = true
+	}
+	return dir, nil
+}
+
+// Walk walks a directory the way Scan walks the scanned directories, and
+// returns the files found, the directories walked and the errors. Unlike
+// Scan, it doesn't add the files to the collection.
+func (s *Scanner) Walk(path string) ([]*fs.File, []string, []*fs.PathError, error) {
+	dir, err := s.newDirectory(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var dirs []string
+	dir.OnDirDone = func(path string, _ []*fs.File, _ []*fs.PathError, _ []*fs.SkippedPath) {
+		dirs = append(dirs, path)
+	}
+
+	files, err := dir.ScanFiles(s.Recursive)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return files, dirs, dir.Errors, nil
+}
+
+// scanFiles scans the files given individually. Files found by the walk
+// already, or given twice, are only scanned once, whatever the spelling of
+// their paths: they are compared by absolute path and by device and inode.
+// Skipped paths are likewise reported once.
+func (s *Scanner) scanFiles() error {
+	if len(s.Files) == 0 {
+		return nil
+	}
+	cwd, _ := os.Getwd()
+	seenPaths := make(map[string]bool, len(s.files)+len(s.Files))
+	seenFiles := make(map[fileKey]bool, len(s.files)+len(s.Files))
+	for _, file := range s.files {
+		seenPaths[absPath(cwd, file.Path)] = true
+		if key, ok := keyOf(file); ok {
+			seenFiles[key] = true
+		}
+	}
+	for _, skipped := range s.skipped {
+		seenPaths[absPath(cwd, skipped.Path)] = true
+	}
+
+	for _, path := range s.Files {
+		path = filepath.Clean(path)
+		abs := absPath(cwd, path)
+		if seenPaths[abs] {
+			continue
+		}
+		seenPaths[abs] = true
+
+		file, skipped, err := s.checkFile(path)
+		switch {
+		case err == nil:
+		case errors.Is(err, errDirectory):
+			continue
+		case !s.Strict:
+			s.errors = append(s.errors, fs.NewPathError(path, err))
+			continue
+		default:
+			return fmt.Errorf("error scanning file %s: %w", path, err)
+		}
+
+		if file != nil {
+			if key, ok := keyOf(file); ok {
+				if seenFiles[key] {
+					continue
+				}
+				seenFiles[key] = true
+			}
+		}
+		s.add(file, skipped)
+	}
+	return nil
+}
+
+// fileKey identifies a file by its device and inode numbers
+type fileKey struct{ dev, inode uint64 }
+
+// keyOf returns the key of a file, or false if its inode is unknown
+func keyOf(file *fs.File) (fileKey, bool) {
+	return fileKey{file.Dev, file.Inode}, file.Inode != 0
+}
+
+// absPath returns the absolute form of path, relative to the working
+// directory cwd (path is returned as it is if cwd is unknown)
+func absPath(cwd, path string) string {
+	if filepath.IsAbs(path) || cwd == "" {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(cwd, path)
+}
+
+// dropLinkedCopies removes the files found through symbolic links that are
+// the same file as one found directly, or through an earlier link, so that
+// links are not reported as duplicates of their targets
+func (s *Scanner) dropLinkedCopies() {
+	direct := make(map[fileKey]bool)
+	for _, file := range s.files {
+		if key, ok := keyOf(file); ok && !file.Symlink {
+			direct[key] = true
+		}
+	}
+
+	files := s.files[:0]
+	for _, file := range s.files {
+		if key, ok := keyOf(file); ok && file.Symlink {
+			if direct[key] {
+				continue
+			}
+			direct[key] = true
+		}
+		files = append(files, file)
+	}
+	s.files = files
+
+	s.filesBySize = make(map[int64][]*fs.File)
+	s.empty = nil
+	for _, file := range s.files {
+		s.index(file)
+	}
+}
+
+// index adds a file to the files grouped by size, unless it is empty and
+// empty files are not compared
+func (s *Scanner) index(file *fs.File) {
+	if file.Size == 0 {
+		switch s.Empty {
+		case EmptySkip, "":
+			return
+		case EmptyReport:
+			s.empty = append(s.empty, file)
+			return
+		}
+	}
+
+	// Group files by size (files of different sizes cannot be duplicates)
+	s.filesBySize[file.Size] = append(s.filesBySize[file.Size], file)
+}
+
+// resumeState returns the walk progress of a previous scan for a directory
+func (s *Scanner) resumeState(root int) *RootState {
+	if root >= len(s.Resume) {
+		return nil
+	}
+	return s.Resume[root]
+}
+
+// scanDirectory scans a directory for files, skipping the subtrees
+// that were already walked according to state
+func (s *Scanner) scanDirectory(dir *fs.Directory, state *RootState) error {
+	if state != nil {
+		dir.Walked = make(map[string]bool, len(state.WalkedDirs))
+		for _, walked := range state.WalkedDirs {
+			dir.Walked[walked] = true
+		}
+	}
+
 	// Scan files in this directory
 	files, err := dir.ScanFiles(s.Recursive)
 	if err != nil {
 		return err
 	}
+	errs := dir.Errors
+	skipped := dir.Skipped
+
+	// Merge with the files found by the previous scan, in walk order
+	if state != nil {
+		for _, file := range state.Files {
+			if s.OnFile != nil {
+				s.OnFile(file)
+			}
+		}
+		files = append(files, state.Files...)
+		errs = append(errs, state.Errors...)
+		skipped = append(skipped, state.Skipped...)
+		sort.SliceStable(files, func(i, j int) bool {
+			return fs.ComparePaths(files[i].Path, files[j].Path) < 0
+		})
+		sort.SliceStable(errs, func(i, j int) bool {
+			return fs.ComparePaths(errs[i].Path, errs[j].Path) < 0
+		})
+		sort.SliceStable(skipped, func(i, j int) bool {
+			return fs.ComparePaths(skipped[i].Path, skipped[j].Path) < 0
+		})
+	}
+	s.errors = append(s.errors, errs...)
+	s.skipped = append(s.skipped, skipped...)
 
 	// Process files
 	for _, file := range files {
 		// Add file to collection
 		s.files = append(s.files, file)
-
-		// Group files by size (files of different sizes cannot be duplicates)
-		s.filesBySize[file.Size] = append(s.filesBySize[file.Size], file)
+		s.index(file)
 	}
 
 	return nil
@@ -137,6 +389,11 @@ func (s *Scanner) GetPotentialDuplicates() [][]*fs.File {
 			result = append(result, files)
 		}
 	}
+
+	// Order by size, largest first, so results don't depend on map order
+	sort.Slice(result, func(i, j int) bool {
+		return result[i][0].Size > result[j][0].Size
+	})
 	return result
 }
 
@@ -147,6 +404,29 @@ func (s *Scanner) GetFiles() []*fs.File {
 	return s.files
 }
 
+// GetErrors returns the errors for paths that were skipped during the scan
+func (s *Scanner) GetErrors() []*fs.PathError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.errors
+}
+
+// GetSkipped returns the paths that were skipped during the scan and are
+// reported, such as symbolic links with the report policy
+func (s *Scanner) GetSkipped() []*fs.SkippedPath {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.skipped
+}
+
+// GetEmpty returns the empty files found by the scan, when they are
+// reported rather than compared
+func (s *Scanner) GetEmpty() []*fs.File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.empty
+}
+
 // GetFileCount returns the number of scanned files
 func (s *Scanner) GetFileCount() int {
 	s.mu.Lock()
@@ -154,43 +434,112 @@ func (s *Scanner) GetFileCount() int {
 	return len(s.files)
 }
 
-// ScanFile scans a single file and adds it to the collection
+// ScanFile scans a single file and adds it to the collection. Symbolic
+// links and special files are handled as in a walk.
 func (s *Scanner) ScanFile(path string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	return s.scanFile(path)
+}
 
-	// Check if file exists
-	info, err := os.Stat(path)
+// Check returns the file at path as ScanFile would scan it, or nil if the
+// scan leaves it out or it is a directory. Unlike ScanFile, it doesn't add
+// the file to the collection.
+func (s *Scanner) Check(path string) (*fs.File, error) {
+	file, _, err := s.checkFile(path)
+	if errors.Is(err, errDirectory) {
+		return nil, nil
+	}
+	return file, err
+}
+
+// scanFile scans a single file, with the lock held
+func (s *Scanner) scanFile(path string) error {
+	file, skipped, err := s.checkFile(path)
 	if err != nil {
 		return err
 	}
+	s.add(file, skipped)
+	return nil
+}
+
+// add adds a file checked by checkFile to the collection, and the skipped
+// path to the reported ones (either may be nil)
+func (s *Scanner) add(file *fs.File, skipped *fs.SkippedPath) {
+	if skipped != nil {
+		s.skipped = append(s.skipped, skipped)
+	}
+	if file == nil {
+		return
+	}
+
+	// Add file to collection
+	s.files = append(s.files, file)
+	s.index(file)
+
+	if s.OnFile != nil {
+		s.OnFile(file)
+	}
+}
 
-	// Check if it's a directory
+// checkFile returns the file at path if the scan scans it, or the skipped
+// path to report if it doesn't and reports it
+func (s *Scanner) checkFile(path string) (*fs.File, *fs.SkippedPath, error) {
+	// Check if file exists
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, nil, err
+	}
 	if info.IsDir() {
-		return fmt.Errorf("%s is a directory, not a file", path)
+		return nil, nil, fmt.Errorf("%s %w", path, errDirectory)
 	}
 
 	// Check if file matches exclude pattern
-	if s.ExcludePattern != nil && s.ExcludePattern.MatchString(filepath.Base(path)) {
-		return nil
+	if s.ExcludePattern != nil && s.ExcludePattern.Match(filepath.Base(path)) {
+		return nil, nil, nil
+	}
+
+	// Symbolic links are skipped, reported or resolved to their target
+	symlink := info.Mode()&os.ModeSymlink != 0
+	if symlink {
+		switch s.Symlinks {
+		case fs.SymlinksFollow:
+			info, err = os.Stat(path)
+			if err != nil {
+				return nil, nil, err
+			}
+		case fs.SymlinksReport:
+			if fs.KeepFile(s.Filters, path, info) {
+				return nil, fs.NewSkippedPath(path, info), nil
+			}
+			return nil, nil, nil
+		default:
+			return nil, nil, nil
+		}
+	}
+
+	// Links to directories are left out like directories, special
+	// files are reported
+	if info.IsDir() {
+		return nil, nil, fmt.Errorf("%s %w", path, errDirectory)
+	}
+	if !fs.KeepFile(s.Filters, path, info) {
+		return nil, nil, nil
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fs.NewSkippedPath(path, info), nil
 	}
 
 	// Create file object
 	file := fs.NewFileFromFileInfo(path, info)
+	file.Symlink = symlink
 
 	// Check if file is in a reference directory
 	for refDir := range s.RefDirs {
-		if strings.HasPrefix(path, refDir) {
+		if fs.IsWithin(filepath.Clean(path), filepath.Clean(refDir)) {
 			file.IsReference = true
 			break
 		}
 	}
-
-	// Add file to collection
-	s.files = append(s.files, file)
-
-	// Group files by size
-	s.filesBySize[info.Size()] = append(s.filesBySize[info.Size()], file)
-
-	return nil
+	return file, nil, nil
 }
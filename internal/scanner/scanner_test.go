@@ -0,0 +1,322 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/tendant/dupe-cli/internal/fs"
+)
+
+// writeFiles creates files under root, each holding content
+func writeFiles(t *testing.T, root, content string, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		full := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// chdir changes the working directory for the rest of the test
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// scannedPaths returns the sorted paths of the files found by a scan
+func scannedPaths(t *testing.T, s *Scanner) []string {
+	t.Helper()
+	files, err := s.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestScanFilesSameFileTwice(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "same", "x", "a/big", "b/copy")
+	chdir(t, root)
+
+	tests := []struct {
+		name  string
+		dirs  []string
+		files []string
+		want  []string
+	}{
+		{"relative and absolute", nil, []string{"x", filepath.Join(root, "x")}, []string{"x"}},
+		{"three spellings", nil, []string{"a/big", "./a//big", filepath.Join(root, "a", "big")}, []string{filepath.Join("a", "big")}},
+		{"absolute then relative", nil, []string{filepath.Join(root, "x"), "./x"}, []string{filepath.Join(root, "x")}},
+		{"walked and listed", []string{"a"}, []string{filepath.Join(root, "a", "big"), "b/copy"}, []string{filepath.Join("a", "big"), filepath.Join("b", "copy")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewScanner(tt.dirs, "", true, ScanTypeContent, 80)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.Files = tt.files
+			got := scannedPaths(t, s)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("scanned %q, want %q", got, tt.want)
+			}
+			if groups := s.GetPotentialDuplicates(); len(tt.want) == 1 && len(groups) != 0 {
+				t.Errorf("a file given twice forms a group: %v", groups)
+			}
+		})
+	}
+}
+
+func TestScanFilesHardLink(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "same", "a/file")
+	if err := os.Link(filepath.Join(root, "a", "file"), filepath.Join(root, "link")); err != nil {
+		t.Skipf("cannot create hard links: %v", err)
+	}
+
+	s, err := NewScanner([]string{filepath.Join(root, "a")}, "", false, ScanTypeContent, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Files = []string{filepath.Join(root, "link")}
+	if got := scannedPaths(t, s); len(got) != 1 {
+		t.Errorf("scanned %q, want only the walked file", got)
+	}
+}
+
+func TestScanEmptyFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "", "a", "b", "c")
+	writeFiles(t, root, "data", "d", "e")
+
+	tests := []struct {
+		policy     EmptyPolicy
+		set        bool
+		wantGroups int
+		wantEmpty  int
+	}{
+		{policy: "", set: false, wantGroups: 1},
+		{policy: "", set: true, wantGroups: 1},
+		{policy: EmptySkip, set: true, wantGroups: 1},
+		{policy: EmptyReport, set: true, wantGroups: 1, wantEmpty: 3},
+		{policy: EmptyDuplicates, set: true, wantGroups: 2},
+	}
+	for _, tt := range tests {
+		s, err := NewScanner([]string{root}, "", false, ScanTypeContent, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.set {
+			s.Empty = tt.policy
+		}
+		if _, err := s.Scan(); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(s.GetPotentialDuplicates()); got != tt.wantGroups {
+			t.Errorf("policy %q (set %v): %d groups, want %d", tt.policy, tt.set, got, tt.wantGroups)
+		}
+		if got := len(s.GetEmpty()); got != tt.wantEmpty {
+			t.Errorf("policy %q (set %v): %d empty files reported, want %d", tt.policy, tt.set, got, tt.wantEmpty)
+		}
+	}
+}
+
+// skippedPaths returns the paths and types of the skipped paths of a scan
+func skippedPaths(s *Scanner) []string {
+	var paths []string
+	for _, skipped := range s.GetSkipped() {
+		paths = append(paths, skipped.Type+" "+skipped.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestScanSymlinks(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "same", "data/file", "data/copy")
+	if err := os.Symlink(filepath.Join(root, "data", "file"), filepath.Join(root, "link")); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+	if err := os.Symlink(filepath.Join("data", "file"), filepath.Join(root, "other-link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("data", filepath.Join(root, "dir-link")); err != nil {
+		t.Fatal(err)
+	}
+	path := func(p string) string { return filepath.Join(root, filepath.FromSlash(p)) }
+
+	tests := []struct {
+		policy      string
+		wantFiles   []string
+		wantSkipped []string
+	}{
+		{"", []string{path("data/copy"), path("data/file")}, nil},
+		{"skip", []string{path("data/copy"), path("data/file")}, nil},
+		{"report", []string{path("data/copy"), path("data/file")}, []string{
+			"symlink " + path("dir-link"), "symlink " + path("link"), "symlink " + path("other-link"),
+		}},
+		// Links to files found directly, and the files found again through
+		// the link to their directory, are left out
+		{"follow", []string{path("data/copy"), path("data/file")}, nil},
+	}
+	for _, tt := range tests {
+		s, err := NewScanner([]string{root}, "", true, ScanTypeContent, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Symlinks = fs.SymlinkPolicy(tt.policy)
+		if got := scannedPaths(t, s); !reflect.DeepEqual(got, tt.wantFiles) {
+			t.Errorf("policy %q: scanned %q, want %q", tt.policy, got, tt.wantFiles)
+		}
+		if got := skippedPaths(s); !reflect.DeepEqual(got, tt.wantSkipped) {
+			t.Errorf("policy %q: skipped %q, want %q", tt.policy, got, tt.wantSkipped)
+		}
+		if groups := s.GetPotentialDuplicates(); len(groups) != 1 || len(groups[0]) != 2 {
+			t.Errorf("policy %q: got groups %v, want the file and its copy", tt.policy, groups)
+		}
+	}
+}
+
+func TestScanFollowedSymlinksOnly(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "same", "data/file")
+	if err := os.Symlink(filepath.Join(root, "data", "file"), filepath.Join(root, "a")); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(root, "data", "file"), filepath.Join(root, "b")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two links to a file that isn't scanned count as one file
+	s, err := NewScanner(nil, "", true, ScanTypeContent, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Files = []string{filepath.Join(root, "a"), filepath.Join(root, "b")}
+	s.Symlinks = fs.SymlinksFollow
+	if got, want := scannedPaths(t, s), []string{filepath.Join(root, "a")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("scanned %q, want %q", got, want)
+	}
+	if groups := s.GetPotentialDuplicates(); len(groups) != 0 {
+		t.Errorf("links to the same file form groups: %v", groups)
+	}
+}
+
+func TestCheckSymlink(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "data", "file")
+	link := filepath.Join(root, "link")
+	if err := os.Symlink("file", link); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+	if err := os.Symlink("missing", filepath.Join(root, "broken")); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewScanner(nil, "", false, ScanTypeContent, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file, err := s.Check(link); file != nil || err != nil {
+		t.Errorf("Check of a skipped link = %v, %v", file, err)
+	}
+
+	s.Symlinks = fs.SymlinksFollow
+	file, err := s.Check(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file == nil || !file.Symlink || file.Size != 4 {
+		t.Errorf("Check of a followed link = %+v, want the target's size", file)
+	}
+	if _, err := s.Check(filepath.Join(root, "broken")); err == nil {
+		t.Error("Check of a followed broken link succeeded")
+	}
+}
+
+func TestScanMaxDepth(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "same", "f0", "a/f1", "a/b/f2", "a/b/c/f3")
+	path := func(p string) string { return filepath.Join(root, filepath.FromSlash(p)) }
+
+	tests := []struct {
+		recursive bool
+		maxDepth  int
+		want      []string
+	}{
+		{false, 0, []string{path("f0")}},
+		{true, 0, []string{path("a/b/c/f3"), path("a/b/f2"), path("a/f1"), path("f0")}},
+		{true, 1, []string{path("a/f1"), path("f0")}},
+		{true, 2, []string{path("a/b/f2"), path("a/f1"), path("f0")}},
+	}
+	for _, tt := range tests {
+		s, err := NewScanner([]string{root}, "", tt.recursive, ScanTypeContent, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.MaxDepth = tt.maxDepth
+		if got := scannedPaths(t, s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("recursive %v, max depth %d: scanned %q, want %q", tt.recursive, tt.maxDepth, got, tt.want)
+		}
+	}
+}
+
+func TestScanStrict(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "content", "a")
+	missing := []string{filepath.Join(root, "missing1"), filepath.Join(root, "missing2")}
+
+	for _, strict := range []bool{false, true} {
+		s, err := NewScanner(nil, "", false, ScanTypeStandard, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Files = append([]string{filepath.Join(root, "a")}, missing...)
+		s.Strict = strict
+		_, err = s.Scan()
+
+		var reported []string
+		for _, pathErr := range s.GetErrors() {
+			if pathErr.Category != fs.ErrorVanished {
+				t.Errorf("strict %v: %s reported as %v, want %v", strict, pathErr.Path, pathErr.Category, fs.ErrorVanished)
+			}
+			reported = append(reported, pathErr.Path)
+		}
+		switch {
+		case strict && err == nil:
+			t.Error("strict scan with missing files succeeded")
+		case strict && !strings.Contains(err.Error(), missing[0]):
+			t.Errorf("strict scan failed with %v, want the first missing file", err)
+		case strict && len(reported) > 0:
+			t.Errorf("strict scan reported errors %q after stopping", reported)
+		case !strict && err != nil:
+			t.Errorf("scan failed: %v", err)
+		case !strict && !reflect.DeepEqual(reported, missing):
+			t.Errorf("reported errors for %q, want %q", reported, missing)
+		}
+	}
+}
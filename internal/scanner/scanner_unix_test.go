@@ -0,0 +1,38 @@
+//go:build darwin || linux
+
+package scanner
+
+import (
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+func TestScanSpecialFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "data", "a", "b")
+	fifo := filepath.Join(root, "fifo")
+	if err := syscall.Mkfifo(fifo, 0644); err != nil {
+		t.Skipf("cannot create a FIFO: %v", err)
+	}
+
+	// The FIFO is reported once, though both walked and listed, and never opened
+	s, err := NewScanner([]string{root}, "", false, ScanTypeContent, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Files = []string{fifo}
+	want := []string{filepath.Join(root, "a"), filepath.Join(root, "b")}
+	if got := scannedPaths(t, s); !reflect.DeepEqual(got, want) {
+		t.Errorf("scanned %q, want %q", got, want)
+	}
+	wantSkipped := []string{"fifo " + fifo}
+	if got := skippedPaths(s); !reflect.DeepEqual(got, wantSkipped) {
+		t.Errorf("skipped %q, want %q", got, wantSkipped)
+	}
+
+	if file, err := s.Check(fifo); file != nil || err != nil {
+		t.Errorf("Check of a FIFO = %v, %v", file, err)
+	}
+}
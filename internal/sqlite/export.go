@@ -0,0 +1,177 @@
+package sqlite
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+)
+
+// SchemaVersion is the version of the tables written by Export
+const SchemaVersion = 2
+
+// Schema of exported results
+const (
+	metadataSQL = `CREATE TABLE metadata (key TEXT NOT NULL, value TEXT)`
+
+	groupsSQL = `CREATE TABLE groups (
+  id INTEGER PRIMARY KEY,
+  reference_id INTEGER NOT NULL,
+  size INTEGER NOT NULL,
+  file_count INTEGER NOT NULL,
+  wasted INTEGER NOT NULL
+)`
+
+	filesSQL = `CREATE TABLE files (
+  id INTEGER PRIMARY KEY,
+  group_id INTEGER NOT NULL,
+  role TEXT NOT NULL,
+  path TEXT NOT NULL,
+  dir TEXT NOT NULL,
+  name TEXT NOT NULL,
+  size INTEGER NOT NULL,
+  mtime TEXT NOT NULL,
+  digest TEXT,
+  dev INTEGER,
+  inode INTEGER,
+  in_reference_dir INTEGER NOT NULL
+)`
+
+	matchesSQL = `CREATE TABLE matches (
+  id INTEGER PRIMARY KEY,
+  group_id INTEGER NOT NULL,
+  first_id INTEGER NOT NULL,
+  second_id INTEGER NOT NULL,
+  percentage INTEGER NOT NULL
+)`
+
+	errorsSQL = `CREATE TABLE errors (
+  id INTEGER PRIMARY KEY,
+  path TEXT NOT NULL,
+  category TEXT NOT NULL,
+  message TEXT NOT NULL
+)`
+
+	// directoryPairsSQL totals the duplicate bytes that pairs of directories
+	// share: the directory of each reference with those of its duplicates,
+	// in sorted order so that a pair is the same whichever holds the reference
+	directoryPairsSQL = `CREATE VIEW directory_pairs AS
+SELECT MIN(r.dir, d.dir) AS first_dir, MAX(r.dir, d.dir) AS second_dir,
+  COUNT(*) AS files, SUM(d.size) AS bytes
+FROM files d
+JOIN groups g ON g.id = d.group_id
+JOIN files r ON r.id = g.reference_id
+WHERE d.role = 'duplicate'
+GROUP BY first_dir, second_dir`
+
+	// directoryTotalsSQL totals the duplicate bytes in each directory
+	directoryTotalsSQL = `CREATE VIEW directory_totals AS
+SELECT dir, COUNT(*) AS files, SUM(size) AS bytes
+FROM files
+WHERE role = 'duplicate'
+GROUP BY dir`
+)
+
+// Export writes duplicate groups, the paths that could not be read and the
+// scan metadata to a new SQLite database. An existing database at path is
+// replaced. Params are the scan parameters as JSON, which may be nil.
+func Export(path string, groups []*engine.DuplicateGroup, errs []*fs.PathError, params json.RawMessage, scanTime time.Duration) error {
+	// Journals of a replaced database would be applied to the new one
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		if err := os.Remove(path + suffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	w, err := Create(path)
+	if err != nil {
+		return err
+	}
+
+	metadata := w.CreateTable("metadata", metadataSQL)
+	groupTable := w.CreateTable("groups", groupsSQL)
+	files := w.CreateTable("files", filesSQL)
+	matches := w.CreateTable("matches", matchesSQL)
+	errorTable := w.CreateTable("errors", errorsSQL)
+	w.CreateView("directory_pairs", directoryPairsSQL)
+	w.CreateView("directory_totals", directoryTotalsSQL)
+
+	meta := [][2]string{
+		{"schema_version", strconv.Itoa(SchemaVersion)},
+		{"created_at", time.Now().Format(time.RFC3339)},
+		{"scan_time", scanTime.String()},
+		{"scan_time_ms", strconv.FormatInt(scanTime.Milliseconds(), 10)},
+		{"params", string(params)},
+		{"group_count", strconv.Itoa(len(groups))},
+		{"duplicate_count", strconv.Itoa(engine.TotalDuplicateCount(groups))},
+		{"total_size", strconv.FormatInt(engine.TotalDuplicateSize(groups), 10)},
+		{"error_count", strconv.Itoa(len(errs))},
+	}
+	for _, kv := range meta {
+		if err := metadata.Insert(kv[0], kv[1]); err != nil {
+			w.Close()
+			return err
+		}
+	}
+
+	fileID := int64(0)
+	for i, group := range groups {
+		groupID := int64(i + 1)
+		refID := fileID + 1
+
+		var wasted int64
+		for _, dupe := range group.Duplicates {
+			wasted += dupe.Size
+		}
+		if err := groupTable.Insert(nil, refID, group.Reference.Size, len(group.Duplicates)+1, wasted); err != nil {
+			w.Close()
+			return err
+		}
+
+		fileID++
+		if err := insertFile(files, groupID, "reference", group.Reference); err != nil {
+			w.Close()
+			return err
+		}
+
+		for j, dupe := range group.Duplicates {
+			fileID++
+			if err := insertFile(files, groupID, "duplicate", dupe); err != nil {
+				w.Close()
+				return err
+			}
+			if err := matches.Insert(nil, groupID, refID, fileID, group.Matches[j].Percentage); err != nil {
+				w.Close()
+				return err
+			}
+		}
+	}
+
+	for _, pathErr := range errs {
+		if err := errorTable.Insert(nil, pathErr.Path, pathErr.Category.String(), pathErr.Err.Error()); err != nil {
+			w.Close()
+			return err
+		}
+	}
+
+	return w.Close()
+}
+
+// insertFile inserts a row into the files table
+func insertFile(files *Table, groupID int64, role string, file *fs.File) error {
+	var digest, dev, inode any
+	if file.Digest != nil {
+		digest = hex.EncodeToString(file.Digest)
+	}
+	if file.Inode != 0 {
+		dev, inode = file.Dev, file.Inode
+	}
+
+	return files.Insert(nil, groupID, role, file.Path, filepath.Dir(file.Path), filepath.Base(file.Path),
+		file.Size, file.ModTime.Format(time.RFC3339), digest, dev, inode, file.IsReference)
+}
@@ -0,0 +1,166 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+	"github.com/tendant/dupe-cli/internal/matcher"
+)
+
+// testGroup creates a group of files of the given size in dir
+func testGroup(dir string, size int64, names ...string) *engine.DuplicateGroup {
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var files []*fs.File
+	for i, name := range names {
+		files = append(files, &fs.File{
+			Path:    filepath.Join(dir, name),
+			Name:    name,
+			Size:    size,
+			ModTime: mtime,
+			Digest:  []byte{0xde, 0xad, byte(size)},
+			Dev:     1,
+			Inode:   uint64(size)*10 + uint64(i),
+		})
+	}
+
+	group := &engine.DuplicateGroup{Reference: files[0], Duplicates: files[1:]}
+	for _, dupe := range group.Duplicates {
+		group.Matches = append(group.Matches, &matcher.Match{First: files[0], Second: dupe, Percentage: 100})
+	}
+	return group
+}
+
+// pairGroup creates a group of a reference and a duplicate of the given size
+func pairGroup(size int64, ref, dupe string) *engine.DuplicateGroup {
+	group := testGroup("/", size, ref, dupe)
+	group.Reference.Path, group.Duplicates[0].Path = ref, dupe
+	return group
+}
+
+func TestExport(t *testing.T) {
+	many := make([]*engine.DuplicateGroup, 0, 2000)
+	for i := 0; i < 2000; i++ {
+		many = append(many, testGroup(fmt.Sprintf("/data/%d", i), int64(i+1), "a", "b", "c"))
+	}
+	longName := strings.Repeat("x", 3*PageSize)
+
+	tests := []struct {
+		name   string
+		groups []*engine.DuplicateGroup
+		errs   []*fs.PathError
+		want   map[string]string // Query results
+	}{
+		{
+			name: "no groups",
+			want: map[string]string{
+				"SELECT COUNT(*) FROM groups":                             "0",
+				"SELECT COUNT(*) FROM files":                              "0",
+				"SELECT value FROM metadata WHERE key = 'group_count'":    "0",
+				"SELECT value FROM metadata WHERE key = 'schema_version'": "2",
+				"SELECT COUNT(*) FROM directory_pairs":                    "0",
+				"SELECT COUNT(*) FROM sqlite_schema WHERE type = 'view'":  "2",
+				"SELECT value FROM metadata WHERE key = 'params'":         `{"scan_type":"content"}`,
+				"SELECT value FROM metadata WHERE key = 'scan_time_ms'":   "1500",
+				"SELECT COUNT(*) FROM sqlite_schema WHERE type = 'table'": "5",
+				"SELECT COUNT(*) FROM metadata WHERE key = 'created_at'":  "1",
+				"SELECT value FROM metadata WHERE key = 'scan_time'":      "1.5s",
+			},
+		},
+		{
+			name: "groups and errors",
+			groups: []*engine.DuplicateGroup{
+				testGroup("/photos", 100, "a.jpg", "b.jpg", "c.jpg"),
+				testGroup("/docs", 50, "x.txt", "y.txt"),
+			},
+			errs: []*fs.PathError{
+				fs.NewPathError("/photos/locked", os.ErrPermission),
+				{Path: "/docs/gone", Category: fs.ErrorVanished, Err: errors.New("no such file")},
+			},
+			want: map[string]string{
+				"SELECT COUNT(*), SUM(file_count), SUM(wasted) FROM groups":                 "2|5|250",
+				"SELECT role, path, dir, name FROM files WHERE id = 1":                      "reference|/photos/a.jpg|/photos|a.jpg",
+				"SELECT group_id, role, digest, dev, inode FROM files WHERE id = 5":         "2|duplicate|dead32|1|501",
+				"SELECT f.path FROM groups g JOIN files f ON f.id = g.reference_id":         "/photos/a.jpg\n/docs/x.txt",
+				"SELECT first_id, second_id, percentage FROM matches WHERE group_id = 1":    "1|2|100\n1|3|100",
+				"SELECT path, category FROM errors ORDER BY id":                             "/photos/locked|permission\n/docs/gone|vanished",
+				"SELECT first_dir, second_dir, files, bytes FROM directory_pairs":           "/docs|/docs|1|50\n/photos|/photos|2|200",
+				"SELECT value FROM metadata WHERE key IN ('duplicate_count', 'total_size')": "3\n250",
+				"SELECT mtime, in_reference_dir FROM files WHERE id = 2":                    "2024-05-01T12:00:00Z|0",
+			},
+		},
+		{
+			name: "directory pairs in both directions",
+			groups: []*engine.DuplicateGroup{
+				pairGroup(10, "/b/x", "/a/x"),
+				pairGroup(20, "/a/y", "/b/y"),
+				pairGroup(30, "/a/z", "/c/z"),
+			},
+			want: map[string]string{
+				"SELECT first_dir, second_dir, files, bytes FROM directory_pairs ORDER BY first_dir, second_dir": "/a|/b|2|30\n/a|/c|1|30",
+			},
+		},
+		{
+			name:   "many groups",
+			groups: many,
+			want: map[string]string{
+				"SELECT COUNT(*), MAX(id) FROM groups":           "2000|2000",
+				"SELECT COUNT(*), MAX(id) FROM files":            "6000|6000",
+				"SELECT path FROM files WHERE id = 6000":         "/data/1999/c",
+				"SELECT SUM(bytes) FROM directory_totals":        fmt.Sprint(2000 * 2001),
+				"SELECT COUNT(*) FROM files WHERE inode IS NULL": "0",
+			},
+		},
+		{
+			name:   "paths spilling to overflow pages",
+			groups: []*engine.DuplicateGroup{testGroup("/long", 7, longName+"1", longName+"2")},
+			want: map[string]string{
+				"SELECT LENGTH(name) FROM files": fmt.Sprintf("%d\n%d", len(longName)+1, len(longName)+1),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "results.db")
+			if err := Export(path, tt.groups, tt.errs, []byte(`{"scan_type":"content"}`), 1500*time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+
+			checkIntegrity(t, path)
+			for sql, want := range tt.want {
+				if got := query(t, path, sql); got != want {
+					t.Errorf("%s = %q, want %q", sql, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExportReplaces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.db")
+	if err := Export(path, []*engine.DuplicateGroup{testGroup("/a", 10, "x", "y")}, nil, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	// A journal left by a crashed writer of the old database
+	if err := os.WriteFile(path+"-journal", []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Export(path, nil, nil, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + "-journal"); !os.IsNotExist(err) {
+		t.Errorf("journal of the replaced database was kept: %v", err)
+	}
+	checkIntegrity(t, path)
+	if got := query(t, path, "SELECT COUNT(*) FROM files"); got != "0" {
+		t.Errorf("files = %s, want 0", got)
+	}
+}
@@ -0,0 +1,471 @@
+package sqlite
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+)
+
+// PageSize is the page size of written databases
+const PageSize = 4096
+
+// Page types of the B-tree pages that are written
+const (
+	pageTableInterior = 0x05
+	pageTableLeaf     = 0x0d
+)
+
+const (
+	// leafHeaderSize and interiorHeaderSize are the sizes of B-tree page headers
+	leafHeaderSize     = 8
+	interiorHeaderSize = 12
+
+	// fileHeaderSize is the size of the database header on page 1
+	fileHeaderSize = 100
+
+	// maxLocal and minLocal bound the payload a table leaf cell keeps on its page
+	// before the rest spills to overflow pages
+	maxLocal = PageSize - 35
+	minLocal = (PageSize-12)*32/255 - 23
+)
+
+// Writer writes a new SQLite database file. Tables are written as rows are
+// inserted, so rows must be inserted in increasing rowid order, and nothing
+// can be read back or updated. The schema is written to page 1 on Close.
+type Writer struct {
+	f      *os.File
+	pages  uint32 // Number of pages allocated
+	schema []schemaEntry
+	tables []*Table
+	err    error // First write error
+}
+
+// schemaEntry is a row of the sqlite_schema table
+type schemaEntry struct {
+	kind  string // "table" or "view"
+	name  string
+	table *Table // Table of a table entry
+	sql   string
+}
+
+// Table is a table being written
+type Table struct {
+	w       *Writer
+	leaf    *page
+	lastID  int64
+	leaves  []child // Written leaf pages
+	root    uint32  // Root page, once the table is finished
+	flushed bool
+}
+
+// child is a page of a B-tree and the largest rowid in it
+type child struct {
+	page   uint32
+	maxKey int64
+}
+
+// page is a B-tree page being filled with cells
+type page struct {
+	offset int      // Start of the page header; fileHeaderSize on page 1
+	cells  [][]byte // Cell contents in key order
+	size   int      // Bytes used by the header, cell pointers and cells
+	maxKey int64
+}
+
+// Create creates a database file, replacing an existing file
+func Create(path string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	// Page 1 is written last, once the schema is complete
+	return &Writer{f: f, pages: 1}, nil
+}
+
+// CreateTable adds a table with its CREATE TABLE statement to the schema.
+// The statement can have an INTEGER PRIMARY KEY column, whose value must be
+// inserted as nil, but no other constraints that need an index.
+func (w *Writer) CreateTable(name, sql string) *Table {
+	t := &Table{w: w, leaf: newPage(0, leafHeaderSize)}
+	w.tables = append(w.tables, t)
+	w.schema = append(w.schema, schemaEntry{kind: "table", name: name, table: t, sql: sql})
+	return t
+}
+
+// CreateView adds a view with its CREATE VIEW statement to the schema
+func (w *Writer) CreateView(name, sql string) {
+	w.schema = append(w.schema, schemaEntry{kind: "view", name: name, sql: sql})
+}
+
+// Insert adds a row to the table. Values can be nil, bool, int, int64,
+// uint64, float64, string or []byte. The row gets the next rowid, starting at 1.
+func (t *Table) Insert(values ...any) error {
+	if t.w.err != nil {
+		return t.w.err
+	}
+	if t.flushed {
+		return fmt.Errorf("sqlite: insert into finished table")
+	}
+
+	record, err := encodeRecord(values)
+	if err != nil {
+		return err
+	}
+
+	t.lastID++
+	cell := t.w.leafCell(t.lastID, record)
+	if t.w.err != nil {
+		return t.w.err
+	}
+
+	if !t.leaf.fits(cell) {
+		t.flushLeaf()
+	}
+	t.leaf.add(cell, t.lastID)
+	return t.w.err
+}
+
+// flushLeaf writes the current leaf page and starts a new one
+func (t *Table) flushLeaf() {
+	pageNo := t.w.allocate()
+	t.w.writePage(pageNo, t.leaf.encode(pageTableLeaf, 0))
+	t.leaves = append(t.leaves, child{page: pageNo, maxKey: t.leaf.maxKey})
+	t.leaf = newPage(0, leafHeaderSize)
+}
+
+// finish writes the remaining pages of the table and its interior pages
+func (t *Table) finish() {
+	if t.flushed {
+		return
+	}
+	t.flushed = true
+
+	if len(t.leaf.cells) > 0 || len(t.leaves) == 0 {
+		t.flushLeaf()
+	}
+
+	// Build interior levels until a single page remains as the root
+	level := t.leaves
+	for len(level) > 1 {
+		level = t.w.writeInteriorLevel(level)
+	}
+	t.root = level[0].page
+}
+
+// writeInteriorLevel writes the interior pages above a level of pages and
+// returns the new level
+func (w *Writer) writeInteriorLevel(children []child) []child {
+	var parents []child
+	for len(children) > 0 {
+		p := newPage(0, interiorHeaderSize)
+		n := 0
+		// The last child of a page is its right-most pointer and needs no cell
+		for n < len(children)-1 {
+			cell := interiorCell(children[n])
+			if !p.fits(cell) {
+				break
+			}
+			p.add(cell, children[n].maxKey)
+			n++
+		}
+
+		right := children[n]
+		pageNo := w.allocate()
+		w.writePage(pageNo, p.encode(pageTableInterior, right.page))
+		parents = append(parents, child{page: pageNo, maxKey: right.maxKey})
+		children = children[n+1:]
+	}
+	return parents
+}
+
+// leafCell encodes a table leaf cell, writing the part of the record that
+// doesn't fit on the page to overflow pages
+func (w *Writer) leafCell(rowid int64, record []byte) []byte {
+	cell := appendVarint(nil, uint64(len(record)))
+	cell = appendVarint(cell, uint64(rowid))
+
+	local := len(record)
+	if local > maxLocal {
+		local = minLocal + (len(record)-minLocal)%(PageSize-4)
+		if local > maxLocal {
+			local = minLocal
+		}
+	}
+
+	cell = append(cell, record[:local]...)
+	if local < len(record) {
+		cell = appendUint32(cell, w.writeOverflow(record[local:]))
+	}
+	return cell
+}
+
+// writeOverflow writes data to a chain of overflow pages and returns the first page
+func (w *Writer) writeOverflow(data []byte) uint32 {
+	chunk := PageSize - 4
+	count := (len(data) + chunk - 1) / chunk
+	first := w.pages + 1
+	for i := 0; i < count; i++ {
+		pageNo := w.allocate()
+		buf := make([]byte, PageSize)
+		if i < count-1 {
+			binary.BigEndian.PutUint32(buf, pageNo+1)
+		}
+		end := (i + 1) * chunk
+		if end > len(data) {
+			end = len(data)
+		}
+		copy(buf[4:], data[i*chunk:end])
+		w.writePage(pageNo, buf)
+	}
+	return first
+}
+
+// interiorCell encodes a table interior cell pointing to a child page
+func interiorCell(c child) []byte {
+	cell := appendUint32(nil, c.page)
+	return appendVarint(cell, uint64(c.maxKey))
+}
+
+// Close finishes all tables, writes the schema and header to page 1 and
+// closes the file
+func (w *Writer) Close() error {
+	for _, t := range w.tables {
+		t.finish()
+	}
+
+	// The schema table is a single leaf on page 1, after the file header
+	schema := newPage(fileHeaderSize, leafHeaderSize)
+	for i, entry := range w.schema {
+		var root int64
+		if entry.table != nil {
+			root = int64(entry.table.root)
+		}
+		record, err := encodeRecord([]any{entry.kind, entry.name, entry.name, root, entry.sql})
+		if err != nil {
+			w.setErr(err)
+			break
+		}
+		cell := appendVarint(nil, uint64(len(record)))
+		cell = appendVarint(cell, uint64(i+1))
+		cell = append(cell, record...)
+		if len(record) > maxLocal || !schema.fits(cell) {
+			w.setErr(fmt.Errorf("sqlite: schema does not fit on the first page"))
+			break
+		}
+		schema.add(cell, int64(i+1))
+	}
+
+	if w.err == nil {
+		buf := schema.encode(pageTableLeaf, 0)
+		w.writeHeader(buf)
+		w.writePage(1, buf)
+	}
+
+	if err := w.f.Close(); err != nil {
+		w.setErr(err)
+	}
+	return w.err
+}
+
+// writeHeader writes the database file header to the start of page 1
+func (w *Writer) writeHeader(buf []byte) {
+	copy(buf, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(buf[16:], PageSize)
+	buf[18] = 1                                   // File format write version (legacy, no WAL)
+	buf[19] = 1                                   // File format read version
+	buf[20] = 0                                   // Reserved bytes per page
+	buf[21] = 64                                  // Maximum embedded payload fraction
+	buf[22] = 32                                  // Minimum embedded payload fraction
+	buf[23] = 32                                  // Leaf payload fraction
+	binary.BigEndian.PutUint32(buf[24:], 1)       // File change counter
+	binary.BigEndian.PutUint32(buf[28:], w.pages) // Database size in pages
+	binary.BigEndian.PutUint32(buf[40:], 1)       // Schema cookie
+	binary.BigEndian.PutUint32(buf[44:], 4)       // Schema format number
+	binary.BigEndian.PutUint32(buf[56:], 1)       // Text encoding: UTF-8
+	binary.BigEndian.PutUint32(buf[92:], 1)       // Version-valid-for number
+	binary.BigEndian.PutUint32(buf[96:], 3045000) // SQLite version the format follows
+}
+
+// allocate returns the number of a new page
+func (w *Writer) allocate() uint32 {
+	w.pages++
+	return w.pages
+}
+
+// writePage writes a page to the file
+func (w *Writer) writePage(pageNo uint32, buf []byte) {
+	if w.err != nil {
+		return
+	}
+	if _, err := w.f.WriteAt(buf, int64(pageNo-1)*PageSize); err != nil {
+		w.setErr(err)
+	}
+}
+
+// setErr records the first error
+func (w *Writer) setErr(err error) {
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+// newPage creates an empty B-tree page whose header starts at offset
+func newPage(offset, headerSize int) *page {
+	return &page{offset: offset, size: offset + headerSize}
+}
+
+// fits reports whether a cell fits on the page
+func (p *page) fits(cell []byte) bool {
+	return p.size+2+len(cell) <= PageSize
+}
+
+// add adds a cell with the given key to the page
+func (p *page) add(cell []byte, key int64) {
+	p.cells = append(p.cells, cell)
+	p.size += 2 + len(cell)
+	p.maxKey = key
+}
+
+// encode returns the page contents. Cells are placed at the end of the page
+// in reverse order, with the cell pointer array after the header.
+func (p *page) encode(pageType byte, rightChild uint32) []byte {
+	buf := make([]byte, PageSize)
+	header := buf[p.offset:]
+	header[0] = pageType
+
+	headerSize := leafHeaderSize
+	if pageType == pageTableInterior {
+		headerSize = interiorHeaderSize
+		binary.BigEndian.PutUint32(header[8:], rightChild)
+	}
+
+	content := PageSize
+	pointers := p.offset + headerSize
+	for i, cell := range p.cells {
+		content -= len(cell)
+		copy(buf[content:], cell)
+		binary.BigEndian.PutUint16(buf[pointers+2*i:], uint16(content))
+	}
+
+	binary.BigEndian.PutUint16(header[3:], uint16(len(p.cells)))
+	binary.BigEndian.PutUint16(header[5:], uint16(content))
+	return buf
+}
+
+// encodeRecord encodes values in the SQLite record format
+func encodeRecord(values []any) ([]byte, error) {
+	var types []byte
+	var body []byte
+
+	for _, value := range values {
+		var serial uint64
+		switch v := value.(type) {
+		case nil:
+			serial = 0
+		case bool:
+			serial = 8
+			if v {
+				serial = 9
+			}
+		case int:
+			serial, body = appendInt(body, int64(v))
+		case int64:
+			serial, body = appendInt(body, v)
+		case uint64:
+			if v > math.MaxInt64 {
+				return nil, fmt.Errorf("sqlite: integer out of range: %d", v)
+			}
+			serial, body = appendInt(body, int64(v))
+		case float64:
+			serial = 7
+			body = appendUint64(body, math.Float64bits(v))
+		case string:
+			serial = uint64(len(v))*2 + 13
+			body = append(body, v...)
+		case []byte:
+			serial = uint64(len(v))*2 + 12
+			body = append(body, v...)
+		default:
+			return nil, fmt.Errorf("sqlite: unsupported value type %T", value)
+		}
+		types = appendVarint(types, serial)
+	}
+
+	// The header size includes the varint of the size itself
+	headerSize := len(types) + 1
+	for headerSize != len(types)+varintLen(uint64(headerSize)) {
+		headerSize = len(types) + varintLen(uint64(headerSize))
+	}
+
+	record := appendVarint(make([]byte, 0, headerSize+len(body)), uint64(headerSize))
+	record = append(record, types...)
+	return append(record, body...), nil
+}
+
+// appendInt appends an integer with the smallest serial type that holds it
+func appendInt(body []byte, v int64) (uint64, []byte) {
+	switch {
+	case v == 0:
+		return 8, body
+	case v == 1:
+		return 9, body
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		return 1, append(body, byte(v))
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		return 2, appendUint16(body, uint16(v))
+	case v >= -1<<23 && v < 1<<23:
+		return 3, append(body, byte(v>>16), byte(v>>8), byte(v))
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		return 4, appendUint32(body, uint32(v))
+	case v >= -1<<47 && v < 1<<47:
+		return 5, append(body, byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	default:
+		return 6, appendUint64(body, uint64(v))
+	}
+}
+
+// appendUint16 appends a big-endian 16-bit integer
+func appendUint16(buf []byte, v uint16) []byte {
+	return append(buf, byte(v>>8), byte(v))
+}
+
+// appendUint32 appends a big-endian 32-bit integer
+func appendUint32(buf []byte, v uint32) []byte {
+	return append(buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// appendUint64 appends a big-endian 64-bit integer
+func appendUint64(buf []byte, v uint64) []byte {
+	return appendUint32(appendUint32(buf, uint32(v>>32)), uint32(v))
+}
+
+// appendVarint appends a value as an SQLite varint: big-endian groups of
+// 7 bits with the high bit set on all but the last byte, and a ninth byte
+// that holds 8 bits
+func appendVarint(buf []byte, v uint64) []byte {
+	if v > 1<<56-1 {
+		var tmp [9]byte
+		tmp[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			tmp[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return append(buf, tmp[:]...)
+	}
+
+	var tmp [8]byte
+	i := len(tmp) - 1
+	tmp[i] = byte(v & 0x7f)
+	for v >>= 7; v > 0; v >>= 7 {
+		i--
+		tmp[i] = byte(v&0x7f) | 0x80
+	}
+	return append(buf, tmp[i:]...)
+}
+
+// varintLen returns the length of a value encoded as a varint
+func varintLen(v uint64) int {
+	return len(appendVarint(nil, v))
+}
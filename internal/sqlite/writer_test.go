@@ -0,0 +1,155 @@
+package sqlite
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// query runs SQL statements on a database with the sqlite3 command, and
+// returns their output. The test is skipped if the command isn't installed.
+func query(t *testing.T, path, sql string) string {
+	t.Helper()
+	sqlite3, err := exec.LookPath("sqlite3")
+	if err != nil {
+		t.Skip("sqlite3 is not installed")
+	}
+	out, err := exec.Command(sqlite3, "-batch", "-bail", path, sql).CombinedOutput()
+	if err != nil {
+		t.Fatalf("sqlite3 %q: %v\n%s", sql, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// checkIntegrity fails the test if SQLite finds the database malformed
+func checkIntegrity(t *testing.T, path string) {
+	t.Helper()
+	if got := query(t, path, "PRAGMA integrity_check"); got != "ok" {
+		t.Fatalf("integrity check of %s: %s", path, got)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    int
+		textLen int // Length of the text value of each row
+	}{
+		{"empty table", 0, 0},
+		{"single row", 1, 10},
+		{"one leaf page", 50, 20},
+		{"two levels of pages", 2000, 30},
+		{"three levels of pages", 100000, 30},
+		{"rows spilling to overflow pages", 20, 3 * PageSize},
+		{"rows just over the local payload", 10, maxLocal},
+		{"large rows on many pages", 300, 2 * PageSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.db")
+			w, err := Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			table := w.CreateTable("t", "CREATE TABLE t (id INTEGER PRIMARY KEY, n INTEGER, f REAL, s TEXT, b BLOB, z)")
+			other := w.CreateTable("other", "CREATE TABLE other (v TEXT)")
+			w.CreateView("v", "CREATE VIEW v AS SELECT id, n FROM t WHERE n % 2 = 0")
+
+			var sumLen int64
+			for i := 1; i <= tt.rows; i++ {
+				text := strings.Repeat(string(rune('a'+i%26)), tt.textLen)
+				sumLen += int64(len(text))
+				if err := table.Insert(nil, int64(i)*1000003, float64(i)/4, text, []byte{byte(i), 0, 0xff}, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := other.Insert("after"); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			checkIntegrity(t, path)
+			want := fmt.Sprintf("%d|%d|%d|%d", tt.rows, tt.rows, sumLen, tt.rows/2)
+			got := query(t, path, `SELECT COUNT(*), COALESCE(MAX(id), 0), COALESCE(SUM(LENGTH(s)), 0),
+  (SELECT COUNT(*) FROM v) FROM t`)
+			if got != want {
+				t.Errorf("count, max id, text length, view count = %s, want %s", got, want)
+			}
+			if got := query(t, path, "SELECT v FROM other"); got != "after" {
+				t.Errorf("other table = %q, want after", got)
+			}
+		})
+	}
+}
+
+func TestWriterValues(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string // Output of SELECT typeof(v), quote(v)
+	}{
+		{nil, "null|NULL"},
+		{true, "integer|1"},
+		{false, "integer|0"},
+		{0, "integer|0"},
+		{1, "integer|1"},
+		{-1, "integer|-1"},
+		{127, "integer|127"},
+		{128, "integer|128"},
+		{-32769, "integer|-32769"},
+		{int64(1) << 40, "integer|1099511627776"},
+		{int64(-1) << 62, "integer|-4611686018427387904"},
+		{uint64(1) << 62, "integer|4611686018427387904"},
+		{1.5, "real|1.5"},
+		{"", "text|''"},
+		{"it's", "text|'it''s'"},
+		{"héllo", "text|'héllo'"},
+		{[]byte{}, "blob|X''"},
+		{[]byte{0x00, 0xab}, "blob|X'00AB'"},
+	}
+
+	path := filepath.Join(t.TempDir(), "values.db")
+	w, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := w.CreateTable("t", "CREATE TABLE t (v)")
+	for _, tt := range tests {
+		if err := table.Insert(tt.value); err != nil {
+			t.Fatalf("Insert(%#v): %v", tt.value, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkIntegrity(t, path)
+	got := strings.Split(query(t, path, "SELECT typeof(v) || '|' || quote(v) FROM t ORDER BY rowid"), "\n")
+	if len(got) != len(tests) {
+		t.Fatalf("got %d rows, want %d: %q", len(got), len(tests), got)
+	}
+	for i, tt := range tests {
+		if got[i] != tt.want {
+			t.Errorf("value %#v read back as %s, want %s", tt.value, got[i], tt.want)
+		}
+	}
+}
+
+func TestInsertUnsupported(t *testing.T) {
+	w, err := Create(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	table := w.CreateTable("t", "CREATE TABLE t (v)")
+	for _, value := range []any{int32(1), uint64(1) << 63, struct{}{}} {
+		if err := table.Insert(value); err == nil {
+			t.Errorf("Insert(%#v) succeeded, want an error", value)
+		}
+	}
+}
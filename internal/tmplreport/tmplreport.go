@@ -0,0 +1,193 @@
+package tmplreport
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+	"github.com/tendant/dupe-cli/internal/units"
+)
+
+// Data is the data a template is executed with
+type Data struct {
+	Groups    []Group        // Duplicate groups, in the order of the results
+	Errors    []Error        // Paths that could not be read
+	Totals    Totals         // Totals over all groups
+	Params    map[string]any // Scan parameters, by their JSON names (empty if unknown)
+	ScanTime  time.Duration  // Duration of the scan
+	Generated time.Time      // Time the output was generated
+}
+
+// Group is a duplicate group
+type Group struct {
+	Number     int         // Number of the group, starting at 1
+	Reference  File        // File the duplicates were matched against
+	Duplicates []Duplicate // Duplicates of the reference
+	Size       int64       // Size of the reference
+	Wasted     int64       // Total size of the duplicates
+}
+
+// File is a file of a duplicate group
+type File struct {
+	Path        string
+	Name        string // Base name of the file
+	Dir         string // Directory of the file
+	Size        int64
+	ModTime     time.Time
+	Digest      string // Hex-encoded digest of the content (empty if not calculated)
+	Inode       uint64 // Inode number (0 if unknown)
+	IsReference bool   // Whether the file is in a reference directory
+}
+
+// Duplicate is a duplicate with its match against the group's reference
+type Duplicate struct {
+	File
+	Percentage int // Match percentage with the reference
+}
+
+// Totals are the totals over all duplicate groups
+type Totals struct {
+	Groups     int   // Number of groups
+	Duplicates int   // Number of duplicates
+	Size       int64 // Total size of the duplicates, the space that could be freed
+	Errors     int   // Number of paths that could not be read
+}
+
+// Error is a path that could not be read
+type Error struct {
+	Path     string
+	Category string
+	Message  string
+}
+
+// Funcs are the functions available to templates in addition to the
+// text/template builtins
+var Funcs = template.FuncMap{
+	"size":    units.FormatSize,
+	"shquote": shellQuote,
+	"json":    toJSON,
+	"base":    filepath.Base,
+	"dir":     filepath.Dir,
+	"join":    join,
+	"replace": strings.ReplaceAll,
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"add":     func(a, b int) int { return a + b },
+}
+
+// Load parses a template file
+func Load(path string) (*template.Template, error) {
+	tmpl, err := template.New(filepath.Base(path)).Funcs(Funcs).ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Write executes a template with the data of duplicate groups. Params are
+// the scan parameters as JSON, which may be nil.
+func Write(w io.Writer, tmpl *template.Template, groups []*engine.DuplicateGroup, errs []*fs.PathError, params json.RawMessage, scanTime time.Duration) error {
+	data := NewData(groups, errs, params, scanTime)
+	if err := tmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("error executing template: %w", err)
+	}
+	return nil
+}
+
+// NewData creates the template data of duplicate groups
+func NewData(groups []*engine.DuplicateGroup, errs []*fs.PathError, params json.RawMessage, scanTime time.Duration) *Data {
+	data := &Data{
+		Groups: make([]Group, 0, len(groups)),
+		Errors: make([]Error, 0, len(errs)),
+		Totals: Totals{
+			Groups:     len(groups),
+			Duplicates: engine.TotalDuplicateCount(groups),
+			Size:       engine.TotalDuplicateSize(groups),
+			Errors:     len(errs),
+		},
+		Params:    make(map[string]any),
+		ScanTime:  scanTime,
+		Generated: time.Now(),
+	}
+
+	if len(params) > 0 {
+		// Parameters that can't be decoded are left out rather than failing the output
+		_ = json.Unmarshal(params, &data.Params)
+	}
+
+	for i, g := range groups {
+		group := Group{
+			Number:     i + 1,
+			Reference:  newFile(g.Reference),
+			Duplicates: make([]Duplicate, 0, len(g.Duplicates)),
+			Size:       g.Reference.Size,
+		}
+		for j, dupe := range g.Duplicates {
+			group.Duplicates = append(group.Duplicates, Duplicate{
+				File:       newFile(dupe),
+				Percentage: g.Matches[j].Percentage,
+			})
+			group.Wasted += dupe.Size
+		}
+		data.Groups = append(data.Groups, group)
+	}
+
+	for _, pathErr := range errs {
+		data.Errors = append(data.Errors, Error{
+			Path:     pathErr.Path,
+			Category: pathErr.Category.String(),
+			Message:  pathErr.Err.Error(),
+		})
+	}
+
+	return data
+}
+
+// newFile converts a file of a duplicate group
+func newFile(f *fs.File) File {
+	return File{
+		Path:        f.Path,
+		Name:        filepath.Base(f.Path),
+		Dir:         filepath.Dir(f.Path),
+		Size:        f.Size,
+		ModTime:     f.ModTime,
+		Digest:      hex.EncodeToString(f.Digest),
+		Inode:       f.Inode,
+		IsReference: f.IsReference,
+	}
+}
+
+// shellQuote quotes a string for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// join joins the elements of a list, which can be a []string or a list
+// decoded from JSON like the scan directories of Params
+func join(list any, sep string) (string, error) {
+	switch v := list.(type) {
+	case []string:
+		return strings.Join(v, sep), nil
+	case []any:
+		elems := make([]string, 0, len(v))
+		for _, elem := range v {
+			elems = append(elems, fmt.Sprint(elem))
+		}
+		return strings.Join(elems, sep), nil
+	default:
+		return "", fmt.Errorf("join: cannot join %T", list)
+	}
+}
+
+// toJSON encodes a value as JSON
+func toJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
@@ -0,0 +1,134 @@
+package tmplreport
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+	"github.com/tendant/dupe-cli/internal/matcher"
+)
+
+// newGroup returns a group of a reference and its duplicates, which match
+// it at 100%
+func newGroup(ref *fs.File, dupes ...*fs.File) *engine.DuplicateGroup {
+	group := &engine.DuplicateGroup{Reference: ref, Duplicates: dupes}
+	for _, dupe := range dupes {
+		group.Matches = append(group.Matches, &matcher.Match{First: ref, Second: dupe, Percentage: 100})
+	}
+	return group
+}
+
+func TestNewData(t *testing.T) {
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ref := &fs.File{Path: "/a/x.txt", Size: 3, ModTime: mtime, Digest: []byte{0xab}, Inode: 7, IsReference: true}
+	dupe := &fs.File{Path: "/b/x.txt", Size: 3}
+	groups := []*engine.DuplicateGroup{newGroup(ref, dupe)}
+	groups[0].Matches[0].Percentage = 95
+	errs := []*fs.PathError{{Path: "/c", Category: fs.ErrorPermission, Err: errors.New("denied")}}
+	params := json.RawMessage(`{"dirs": ["/a", "/b"], "min_size": 1}`)
+
+	data := NewData(groups, errs, params, time.Second)
+
+	want := []Group{{
+		Number: 1,
+		Reference: File{
+			Path: "/a/x.txt", Name: "x.txt", Dir: "/a", Size: 3, ModTime: mtime,
+			Digest: "ab", Inode: 7, IsReference: true,
+		},
+		Duplicates: []Duplicate{{File: File{Path: "/b/x.txt", Name: "x.txt", Dir: "/b", Size: 3}, Percentage: 95}},
+		Size:       3,
+		Wasted:     3,
+	}}
+	if !reflect.DeepEqual(data.Groups, want) {
+		t.Errorf("got groups %+v, want %+v", data.Groups, want)
+	}
+	if want := []Error{{Path: "/c", Category: "permission", Message: "denied"}}; !reflect.DeepEqual(data.Errors, want) {
+		t.Errorf("got errors %+v, want %+v", data.Errors, want)
+	}
+	if want := (Totals{Groups: 1, Duplicates: 1, Size: 3, Errors: 1}); data.Totals != want {
+		t.Errorf("got totals %+v, want %+v", data.Totals, want)
+	}
+	if want := map[string]any{"dirs": []any{"/a", "/b"}, "min_size": 1.0}; !reflect.DeepEqual(data.Params, want) {
+		t.Errorf("got params %v, want %v", data.Params, want)
+	}
+	if data.ScanTime != time.Second {
+		t.Errorf("got scan time %v, want 1s", data.ScanTime)
+	}
+}
+
+func TestNewDataWithoutParams(t *testing.T) {
+	for _, params := range []json.RawMessage{nil, json.RawMessage(`[1]`)} {
+		data := NewData(nil, nil, params, 0)
+		if data.Params == nil || len(data.Params) != 0 {
+			t.Errorf("params %s: got %v, want an empty map", params, data.Params)
+		}
+		if data.Groups == nil || data.Errors == nil {
+			t.Errorf("params %s: got nil groups or errors", params)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	groups := []*engine.DuplicateGroup{
+		newGroup(&fs.File{Path: "/a/it's", Size: 2048}, &fs.File{Path: "/b/it's", Size: 2048}),
+	}
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"totals", `{{.Totals.Groups}} {{.Totals.Duplicates}} {{size .Totals.Size}}`, "1 1 2.0 KB"},
+		{"shquote", `{{range .Groups}}{{range .Duplicates}}rm {{shquote .Path}}{{end}}{{end}}`, `rm '/b/it'\''s'`},
+		{"params", `{{join .Params.dirs ","}}`, "/a,/b"},
+		{"json", `{{json (index .Groups 0).Reference.Name}}`, `"it's"`},
+		{"helpers", `{{upper (base "/x/y")}} {{dir "/x/y"}} {{replace "a-b" "-" "+"}} {{add 1 2}}`, "Y /x a+b 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := template.Must(template.New(tt.name).Funcs(Funcs).Parse(tt.text))
+			var out bytes.Buffer
+			if err := Write(&out, tmpl, groups, nil, json.RawMessage(`{"dirs": ["/a", "/b"]}`), 0); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	tmpl := template.Must(template.New("join").Funcs(Funcs).Parse(`{{join .Totals ","}}`))
+	if err := Write(&bytes.Buffer{}, tmpl, nil, nil, nil, 0); err == nil {
+		t.Error("Write of a failing template succeeded")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.tmpl")
+	invalid := filepath.Join(dir, "invalid.tmpl")
+	if err := os.WriteFile(valid, []byte(`{{size 1024}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(invalid, []byte(`{{range}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(valid); err != nil {
+		t.Errorf("Load(valid) error = %v", err)
+	}
+	for _, path := range []string{invalid, filepath.Join(dir, "missing.tmpl")} {
+		if _, err := Load(path); err == nil {
+			t.Errorf("Load(%s) succeeded", filepath.Base(path))
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package units
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatSize formats a size in bytes to a human-readable string
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// ParseSize parses a size in bytes with an optional unit suffix (K, M, G, T,
+// optionally followed by B or iB), using the same 1024 multiples as FormatSize
+func ParseSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+
+	multiplier := int64(1)
+	if s != "" {
+		if exp := strings.IndexByte("KMGTPE", s[len(s)-1]); exp >= 0 {
+			for i := 0; i <= exp; i++ {
+				multiplier *= 1024
+			}
+			s = s[:len(s)-1]
+		}
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %s", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// ageUnits are the units of ParseTime ages, beyond those of time.ParseDuration
+var ageUnits = map[byte]time.Duration{
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+	'y': 365 * 24 * time.Hour,
+}
+
+// timeLayouts are the layouts of absolute times accepted by ParseTime
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// ParseTime parses a time given as a date (2006-01-02), a date and time
+// (RFC 3339, or 2006-01-02 15:04 in local time) or an age before now, such
+// as 90m, 36h, 30d, 2w or 1y
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	s := strings.TrimSpace(value)
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	if s != "" {
+		if unit, ok := ageUnits[s[len(s)-1]]; ok {
+			n, err := strconv.ParseFloat(s[:len(s)-1], 64)
+			if err == nil && n >= 0 {
+				return now.Add(-time.Duration(n * float64(unit))), nil
+			}
+		} else if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+			return now.Add(-d), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %s (expected a date such as 2006-01-02 or an age such as 30d)", value)
+}
@@ -0,0 +1,88 @@
+package watch
+
+import (
+	"sort"
+
+	"github.com/tendant/dupe-cli/internal/fs"
+)
+
+// index holds the watched files by path and by size
+type index struct {
+	files  map[string]*fs.File
+	bySize map[int64][]*fs.File
+}
+
+// newIndex creates an empty index
+func newIndex() *index {
+	return &index{
+		files:  make(map[string]*fs.File),
+		bySize: make(map[int64][]*fs.File),
+	}
+}
+
+// add adds a file, replacing the file indexed at its path
+func (x *index) add(file *fs.File) {
+	x.remove(file.Path)
+	x.files[file.Path] = file
+	x.bySize[file.Size] = append(x.bySize[file.Size], file)
+}
+
+// remove removes the file at path and returns it, or nil if it wasn't indexed
+func (x *index) remove(path string) *fs.File {
+	file := x.files[path]
+	if file == nil {
+		return nil
+	}
+	delete(x.files, path)
+
+	files := x.bySize[file.Size]
+	for i, f := range files {
+		if f == file {
+			files = append(files[:i], files[i+1:]...)
+			break
+		}
+	}
+	if len(files) == 0 {
+		delete(x.bySize, file.Size)
+	} else {
+		x.bySize[file.Size] = files
+	}
+	return file
+}
+
+// sameSize returns the other files of the size of file
+func (x *index) sameSize(file *fs.File) []*fs.File {
+	var files []*fs.File
+	for _, f := range x.bySize[file.Size] {
+		if f.Path != file.Path {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// filesIn returns the paths of the files in a directory or below, sorted
+func (x *index) filesIn(dir string) []string {
+	var paths []string
+	for path := range x.files {
+		if fs.IsWithin(path, dir) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return fs.ComparePaths(paths[i], paths[j]) < 0
+	})
+	return paths
+}
+
+// sizes returns the sizes shared by several files, largest first
+func (x *index) sizes() []int64 {
+	var sizes []int64
+	for size, files := range x.bySize {
+		if len(files) > 1 {
+			sizes = append(sizes, size)
+		}
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] > sizes[j] })
+	return sizes
+}
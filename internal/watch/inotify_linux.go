@@ -0,0 +1,156 @@
+//go:build linux
+
+package watch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/tendant/dupe-cli/internal/fs"
+)
+
+// inotifyMask are the events watched in each directory
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_CLOSE_WRITE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE | syscall.IN_DELETE_SELF | syscall.IN_ONLYDIR
+
+// inotify is the notifier of Linux, with a watch per directory
+type inotify struct {
+	fd   int
+	file *os.File // Reads events from fd, and closes it
+	buf  []byte
+
+	mu    sync.Mutex
+	paths map[int32]string // Directory of each watch descriptor
+	wds   map[string]int32 // Watch descriptor of each directory
+}
+
+// newNotifier creates an inotify instance
+func newNotifier() (notifier, error) {
+	// A non-blocking descriptor lets the runtime poll it, so that closing
+	// the file interrupts a read
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing inotify: %w", err)
+	}
+	return &inotify{
+		fd:    fd,
+		file:  os.NewFile(uintptr(fd), "inotify"),
+		buf:   make([]byte, 64*1024),
+		paths: make(map[int32]string),
+		wds:   make(map[string]int32),
+	}, nil
+}
+
+// add starts watching a directory
+func (n *inotify) add(dir string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	wd, err := syscall.InotifyAddWatch(n.fd, dir, inotifyMask)
+	if err != nil {
+		if errors.Is(err, syscall.ENOSPC) {
+			return fmt.Errorf("too many directories to watch, raise fs.inotify.max_user_watches: %w", err)
+		}
+		return err
+	}
+	n.paths[int32(wd)] = dir
+	n.wds[dir] = int32(wd)
+	return nil
+}
+
+// remove stops watching a directory and the directories below it
+func (n *inotify) remove(dir string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for path, wd := range n.wds {
+		if fs.IsWithin(path, dir) {
+			_, _ = syscall.InotifyRmWatch(n.fd, uint32(wd))
+			delete(n.wds, path)
+			delete(n.paths, wd)
+		}
+	}
+}
+
+// watching reports whether a directory is watched
+func (n *inotify) watching(dir string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	_, ok := n.wds[dir]
+	return ok
+}
+
+// read waits for events and returns the changes they report
+func (n *inotify) read() ([]change, error) {
+	for {
+		size, err := n.file.Read(n.buf)
+		if err != nil {
+			return nil, err
+		}
+		if changes := n.parse(n.buf[:size]); len(changes) > 0 {
+			return changes, nil
+		}
+	}
+}
+
+// parse converts a buffer of inotify events to changes
+func (n *inotify) parse(buf []byte) []change {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var changes []change
+	for offset := 0; offset+syscall.SizeofInotifyEvent <= len(buf); {
+		event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		nameStart := offset + syscall.SizeofInotifyEvent
+		offset = nameStart + int(event.Len)
+		if offset > len(buf) {
+			break
+		}
+		name := strings.TrimRight(string(buf[nameStart:offset]), "\x00")
+		mask := event.Mask
+
+		if mask&syscall.IN_Q_OVERFLOW != 0 {
+			changes = append(changes, change{op: opOverflow})
+			continue
+		}
+		dir, ok := n.paths[event.Wd]
+		if !ok {
+			continue
+		}
+		if mask&syscall.IN_IGNORED != 0 {
+			// The directory is gone, and so is its watch
+			delete(n.paths, event.Wd)
+			delete(n.wds, dir)
+			continue
+		}
+
+		c := change{path: dir, dir: mask&syscall.IN_ISDIR != 0}
+		if name != "" {
+			c.path = filepath.Join(dir, name)
+		}
+		switch {
+		case mask&syscall.IN_DELETE_SELF != 0:
+			c.op, c.dir = opRemove, true
+		case mask&(syscall.IN_DELETE|syscall.IN_MOVED_FROM) != 0:
+			c.op = opRemove
+		case mask&(syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO) != 0:
+			c.op = opDone
+		default:
+			c.op = opChange
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// close stops watching, and interrupts a read
+func (n *inotify) close() error {
+	return n.file.Close()
+}
@@ -0,0 +1,73 @@
+//go:build linux
+
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/scanner"
+)
+
+func TestInotifyEvents(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a"), "same")
+
+	s, err := scanner.NewScanner([]string{root}, "", true, scanner.ScanTypeContent, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWatcher(s)
+	w.Settle = 50 * time.Millisecond
+	events := make(chan Event, 10)
+	w.OnEvent = func(e Event) { events <- e }
+
+	if _, err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- w.Run() }()
+
+	// next waits for the next event
+	next := func() Event {
+		t.Helper()
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(10 * time.Second):
+			t.Fatal("no event")
+			return Event{}
+		}
+	}
+
+	// A copy in a new subdirectory, then its removal
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	copyPath := filepath.Join(root, "sub", "b")
+	writeFile(t, copyPath, "same")
+	if e := next(); e.Type != EventDuplicate || e.Path != copyPath || len(e.Files) != 1 || e.Files[0] != filepath.Join(root, "a") {
+		t.Errorf("got event %+v, want the copy reported as a duplicate", e)
+	}
+
+	if err := os.Remove(copyPath); err != nil {
+		t.Fatal(err)
+	}
+	if e := next(); e.Type != EventResolved || e.Path != copyPath {
+		t.Errorf("got event %+v, want the copy resolved", e)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run didn't end after Close")
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package watch
+
+// newNotifier returns ErrUnsupported: watching directories needs inotify
+func newNotifier() (notifier, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,464 @@
+// Package watch keeps the duplicates of directories up to date as files
+// change, with the change notifications of the operating system
+package watch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+	"github.com/tendant/dupe-cli/internal/ignore"
+	"github.com/tendant/dupe-cli/internal/matcher"
+	"github.com/tendant/dupe-cli/internal/scanner"
+)
+
+// DefaultSettle is the default time a file must go unchanged before it is
+// checked, unless the writer reports it closed first
+const DefaultSettle = time.Second
+
+// ErrUnsupported is returned by Start on platforms without change notifications
+var ErrUnsupported = errors.New("watching directories is not supported on this platform")
+
+// EventType is the type of an Event
+type EventType string
+
+const (
+	EventDuplicate EventType = "duplicate" // A file appeared or changed, and has the content of other files
+	EventResolved  EventType = "resolved"  // A file with the content of other files was removed or changed
+)
+
+// Event is a change of the duplicates of the watched directories
+type Event struct {
+	Type  EventType
+	Time  time.Time
+	Path  string   // File that changed
+	Size  int64    // Size of the file
+	Files []string // Files with the content of Path after the change, sorted
+}
+
+// op is the kind of a change
+type op int
+
+const (
+	opChange   op = iota // Created or modified, possibly still being written
+	opDone               // Closed after writing, or moved in
+	opRemove             // Deleted or moved out
+	opOverflow           // Changes were lost
+)
+
+// change is a change of a path reported by the notifier
+type change struct {
+	path string
+	dir  bool
+	op   op
+}
+
+// notifier reports the changes in watched directories
+type notifier interface {
+	add(dir string) error
+	remove(dir string)
+	watching(dir string) bool
+	read() ([]change, error)
+	close() error
+}
+
+// Watcher watches directories for changes, and keeps track of the
+// duplicates among their files. Files are compared by content; they go
+// through the filters, exclude pattern and policies of the scanner.
+type Watcher struct {
+	Scanner *scanner.Scanner    // Scanner whose directories are watched
+	Ignore  *ignore.List        // Files and pairs not to report as duplicates (may be nil)
+	Settle  time.Duration       // Time a file must go unchanged before it is checked
+	OnEvent func(Event)         // Called for each change of the duplicates (may be nil)
+	OnError func(*fs.PathError) // Called for each path that could not be read (may be nil)
+
+	notifier notifier
+	index    *index
+	pending  map[string]pendingChange // Files changed and not checked yet
+}
+
+// pendingChange is a change of a file waiting to be checked
+type pendingChange struct {
+	last time.Time // Time of the last change
+	done bool      // Whether the file was closed after writing
+}
+
+// NewWatcher creates a Watcher of the directories of a scanner
+func NewWatcher(s *scanner.Scanner) *Watcher {
+	return &Watcher{
+		Scanner: s,
+		Settle:  DefaultSettle,
+		index:   newIndex(),
+		pending: make(map[string]pendingChange),
+	}
+}
+
+// Start scans the directories, starts watching them and returns the
+// duplicate groups they hold
+func (w *Watcher) Start() ([]*engine.DuplicateGroup, error) {
+	n, err := newNotifier()
+	if err != nil {
+		return nil, err
+	}
+	w.notifier = n
+
+	for _, dir := range w.Scanner.Directories {
+		if err := w.sync(filepath.Clean(dir), false); err != nil {
+			n.close()
+			return nil, err
+		}
+	}
+	return w.groups(), nil
+}
+
+// Run handles changes until Close is called
+func (w *Watcher) Run() error {
+	changes := make(chan []change)
+	failed := make(chan error, 1)
+	go func() {
+		for {
+			batch, err := w.notifier.read()
+			if err != nil {
+				failed <- err
+				return
+			}
+			changes <- batch
+		}
+	}()
+
+	for {
+		var settled <-chan time.Time
+		if next, ok := w.nextCheck(); ok {
+			settled = time.After(time.Until(next))
+		}
+
+		select {
+		case batch := <-changes:
+			for _, c := range batch {
+				w.handle(c)
+			}
+		case err := <-failed:
+			if errors.Is(err, os.ErrClosed) {
+				return nil
+			}
+			return err
+		case <-settled:
+		}
+		w.checkSettled(time.Now())
+	}
+}
+
+// Close stops watching, which ends Run
+func (w *Watcher) Close() error {
+	if w.notifier == nil {
+		return nil
+	}
+	return w.notifier.close()
+}
+
+// handle handles a change reported by the notifier
+func (w *Watcher) handle(c change) {
+	switch {
+	case c.op == opOverflow:
+		// Changes were lost, so the directories are compared with the index
+		w.error("", errors.New("too many changes at once, rescanning"))
+		for _, dir := range w.Scanner.Directories {
+			w.report(w.sync(filepath.Clean(dir), true), dir)
+		}
+
+	case c.dir && c.op == opRemove:
+		w.notifier.remove(c.path)
+		for path := range w.pending {
+			if fs.IsWithin(path, c.path) {
+				delete(w.pending, path)
+			}
+		}
+		for _, path := range w.index.filesIn(c.path) {
+			w.removeFile(path)
+		}
+
+	case c.dir:
+		// A directory created or moved in is walked, unless it is watched
+		// already. Its files can be written before its watch starts.
+		if !w.notifier.watching(c.path) && w.descend(c.path) {
+			w.report(w.sync(c.path, true), c.path)
+		}
+
+	case c.op == opRemove:
+		delete(w.pending, c.path)
+		w.removeFile(c.path)
+
+	default:
+		w.pending[c.path] = pendingChange{last: time.Now(), done: c.op == opDone}
+	}
+}
+
+// descend reports whether a directory is walked, as a subdirectory of a
+// watched directory
+func (w *Watcher) descend(dir string) bool {
+	if !w.Scanner.Recursive {
+		return false
+	}
+	for _, filter := range w.Scanner.DirFilters {
+		if !filter(dir) {
+			return false
+		}
+	}
+	return true
+}
+
+// nextCheck returns the time the next pending file settles
+func (w *Watcher) nextCheck() (time.Time, bool) {
+	var next time.Time
+	found := false
+	for _, p := range w.pending {
+		at := p.last.Add(w.Settle)
+		if p.done {
+			at = p.last
+		}
+		if !found || at.Before(next) {
+			next, found = at, true
+		}
+	}
+	return next, found
+}
+
+// checkSettled checks the pending files that were closed or went
+// unchanged for the settle time, in path order
+func (w *Watcher) checkSettled(now time.Time) {
+	var paths []string
+	for path, p := range w.pending {
+		if p.done || now.Sub(p.last) >= w.Settle {
+			paths = append(paths, path)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return fs.ComparePaths(paths[i], paths[j]) < 0
+	})
+
+	for _, path := range paths {
+		delete(w.pending, path)
+		file, err := w.Scanner.Check(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			w.error(path, err)
+		}
+		if file == nil {
+			w.removeFile(path)
+			continue
+		}
+		w.update(file, true)
+	}
+}
+
+// sync walks a directory, starts watching the directories walked and
+// brings the index in line with the files found. Changes are reported with
+// report set.
+func (w *Watcher) sync(dir string, report bool) error {
+	files, dirs, errs, err := w.Scanner.Walk(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	for _, d := range dirs {
+		if !w.notifier.watching(d) {
+			if err := w.notifier.add(d); err != nil {
+				if !report {
+					return fmt.Errorf("error watching %s: %w", d, err)
+				}
+				w.error(d, err)
+			}
+		}
+	}
+	for _, pathErr := range errs {
+		if w.OnError != nil {
+			w.OnError(pathErr)
+		}
+	}
+
+	found := make(map[string]bool, len(files))
+	for _, file := range files {
+		found[file.Path] = true
+	}
+	for _, path := range w.index.filesIn(dir) {
+		if !found[path] {
+			w.removeFile(path)
+		}
+	}
+	for _, file := range files {
+		w.update(file, report)
+	}
+	return nil
+}
+
+// update indexes a file found or changed, and reports the files it is now
+// a duplicate of with report set
+func (w *Watcher) update(file *fs.File, report bool) {
+	if !w.compares(file) {
+		w.removeFile(file.Path)
+		return
+	}
+
+	old := w.index.files[file.Path]
+	if old != nil && old.Size == file.Size && old.ModTime.Equal(file.ModTime) && old.Inode == file.Inode {
+		return
+	}
+
+	// A file rewritten with the same content keeps its duplicates
+	if old != nil && old.Digest != nil && old.Size == file.Size {
+		if digest, err := file.GetDigest(); err == nil && bytes.Equal(digest, old.Digest) {
+			w.index.add(file)
+			return
+		}
+	}
+
+	w.removeFile(file.Path)
+	w.index.add(file)
+	if !report {
+		return
+	}
+
+	dupes, err := w.sameContent(file)
+	if err != nil {
+		w.index.remove(file.Path)
+		w.error(file.Path, err)
+		return
+	}
+	if len(dupes) > 0 {
+		w.emit(EventDuplicate, file, dupes)
+	}
+}
+
+// compares reports whether a file is compared with others, which empty
+// files are only with the duplicates policy
+func (w *Watcher) compares(file *fs.File) bool {
+	if file.Size > 0 {
+		return true
+	}
+	return w.Scanner.Empty == scanner.EmptyDuplicates
+}
+
+// removeFile removes a file from the index, and reports the files it was a
+// duplicate of
+func (w *Watcher) removeFile(path string) {
+	file := w.index.remove(path)
+	if file == nil || file.Digest == nil {
+		return
+	}
+	dupes, err := w.sameContent(file)
+	if err == nil && len(dupes) > 0 {
+		w.emit(EventResolved, file, dupes)
+	}
+}
+
+// sameContent returns the indexed files with the content of file, other
+// than itself. Files of the same size are hashed as needed; those that
+// cannot be read anymore are dropped from the index.
+func (w *Watcher) sameContent(file *fs.File) ([]*fs.File, error) {
+	others := w.index.sameSize(file)
+	if len(others) == 0 {
+		return nil, nil
+	}
+	digest, err := file.GetDigest()
+	if err != nil {
+		return nil, err
+	}
+	if w.Ignore.IgnoresFile(file) {
+		return nil, nil
+	}
+
+	var dupes []*fs.File
+	for _, other := range others {
+		otherDigest, err := other.GetDigest()
+		if err != nil {
+			w.index.remove(other.Path)
+			w.error(other.Path, err)
+			continue
+		}
+		if bytes.Equal(digest, otherDigest) && !w.Ignore.IgnoresFile(other) && !w.Ignore.IgnoresPair(file, other) {
+			dupes = append(dupes, other)
+		}
+	}
+	return dupes, nil
+}
+
+// groups returns the duplicate groups of the indexed files, hashing the
+// files of the same size
+func (w *Watcher) groups() []*engine.DuplicateGroup {
+	var groups []*engine.DuplicateGroup
+	for _, size := range w.index.sizes() {
+		files := append([]*fs.File(nil), w.index.bySize[size]...)
+		sort.Slice(files, func(i, j int) bool {
+			return fs.ComparePaths(files[i].Path, files[j].Path) < 0
+		})
+
+		// Group files by digest, in the order digests are first seen
+		byDigest := make(map[string][]*fs.File)
+		var digests []string
+		for _, file := range files {
+			digest, err := file.GetDigest()
+			if err != nil {
+				w.index.remove(file.Path)
+				w.error(file.Path, err)
+				continue
+			}
+			if w.Ignore.IgnoresFile(file) {
+				continue
+			}
+			key := string(digest)
+			if byDigest[key] == nil {
+				digests = append(digests, key)
+			}
+			byDigest[key] = append(byDigest[key], file)
+		}
+
+		for _, key := range digests {
+			group := &engine.DuplicateGroup{Reference: byDigest[key][0]}
+			for _, file := range byDigest[key][1:] {
+				if w.Ignore.IgnoresPair(group.Reference, file) {
+					continue
+				}
+				group.Duplicates = append(group.Duplicates, file)
+				group.Matches = append(group.Matches, &matcher.Match{First: group.Reference, Second: file, Percentage: 100})
+			}
+			if len(group.Duplicates) > 0 {
+				groups = append(groups, group)
+			}
+		}
+	}
+	return groups
+}
+
+// emit reports an event about file
+func (w *Watcher) emit(typ EventType, file *fs.File, files []*fs.File) {
+	if w.OnEvent == nil {
+		return
+	}
+	event := Event{Type: typ, Time: time.Now(), Path: file.Path, Size: file.Size}
+	for _, f := range files {
+		event.Files = append(event.Files, f.Path)
+	}
+	sort.Slice(event.Files, func(i, j int) bool {
+		return fs.ComparePaths(event.Files[i], event.Files[j]) < 0
+	})
+	w.OnEvent(event)
+}
+
+// report reports an error syncing a directory
+func (w *Watcher) report(err error, dir string) {
+	if err != nil {
+		w.error(dir, err)
+	}
+}
+
+// error reports a path that could not be read
+func (w *Watcher) error(path string, err error) {
+	if w.OnError != nil {
+		w.OnError(fs.NewPathError(path, err))
+	}
+}
@@ -0,0 +1,208 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tendant/dupe-cli/internal/engine"
+	"github.com/tendant/dupe-cli/internal/fs"
+	"github.com/tendant/dupe-cli/internal/scanner"
+)
+
+// fakeNotifier records the watched directories; changes are passed to the
+// watcher by the tests
+type fakeNotifier struct {
+	dirs map[string]bool
+}
+
+func (n *fakeNotifier) add(dir string) error     { n.dirs[dir] = true; return nil }
+func (n *fakeNotifier) remove(dir string)        { delete(n.dirs, dir) }
+func (n *fakeNotifier) watching(dir string) bool { return n.dirs[dir] }
+func (n *fakeNotifier) read() ([]change, error)  { return nil, os.ErrClosed }
+func (n *fakeNotifier) close() error             { return nil }
+
+// testWatcher is a watcher of root with a fake notifier, and the events
+// it reports
+type testWatcher struct {
+	*Watcher
+	root   string
+	events []string
+}
+
+// newTestWatcher creates a watcher of root, and syncs it like Start
+func newTestWatcher(t *testing.T, root string) *testWatcher {
+	t.Helper()
+	s, err := scanner.NewScanner([]string{root}, "", true, scanner.ScanTypeContent, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &testWatcher{Watcher: NewWatcher(s), root: root}
+	w.notifier = &fakeNotifier{dirs: make(map[string]bool)}
+	w.OnEvent = func(e Event) {
+		desc := string(e.Type) + " " + w.rel(e.Path) + ":"
+		for _, path := range e.Files {
+			desc += " " + w.rel(path)
+		}
+		w.events = append(w.events, desc)
+	}
+	w.OnError = func(err *fs.PathError) { t.Errorf("error: %v", err) }
+	if err := w.sync(root, false); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+// rel returns a path relative to the root, with slashes
+func (w *testWatcher) rel(path string) string {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// path returns the full path of a path relative to the root
+func (w *testWatcher) path(rel string) string {
+	return filepath.Join(w.root, filepath.FromSlash(rel))
+}
+
+// write writes a file and reports it closed after writing
+func (w *testWatcher) write(t *testing.T, rel, content string) {
+	t.Helper()
+	writeFile(t, w.path(rel), content)
+	w.handle(change{path: w.path(rel), op: opDone})
+	w.checkSettled(time.Now())
+}
+
+// remove removes a file or directory and reports it removed
+func (w *testWatcher) remove(t *testing.T, rel string, dir bool) {
+	t.Helper()
+	if err := os.RemoveAll(w.path(rel)); err != nil {
+		t.Fatal(err)
+	}
+	w.handle(change{path: w.path(rel), dir: dir, op: opRemove})
+}
+
+// takeEvents returns the events reported since the last call
+func (w *testWatcher) takeEvents() []string {
+	events := w.events
+	w.events = nil
+	return events
+}
+
+// writeFile creates a file holding content, and its directory
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// groupPaths returns the paths of groups, relative to the root
+func (w *testWatcher) groupPaths(groups []*engine.DuplicateGroup) [][]string {
+	var paths [][]string
+	for _, group := range groups {
+		files := []string{w.rel(group.Reference.Path)}
+		for _, dupe := range group.Duplicates {
+			files = append(files, w.rel(dupe.Path))
+		}
+		paths = append(paths, files)
+	}
+	return paths
+}
+
+func TestWatcherInitialGroups(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "b"), "same")
+	writeFile(t, filepath.Join(root, "sub", "a"), "same")
+	writeFile(t, filepath.Join(root, "c"), "long content")
+	writeFile(t, filepath.Join(root, "d"), "other")
+	writeFile(t, filepath.Join(root, "e"), "")
+	writeFile(t, filepath.Join(root, "f"), "")
+
+	w := newTestWatcher(t, root)
+	want := [][]string{{"b", "sub/a"}}
+	if got := w.groupPaths(w.groups()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got groups %q, want %q", got, want)
+	}
+	if !w.notifier.watching(filepath.Join(root, "sub")) {
+		t.Error("subdirectory is not watched")
+	}
+	if events := w.takeEvents(); len(events) != 0 {
+		t.Errorf("initial sync reported events %q", events)
+	}
+}
+
+func TestWatcherEvents(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a"), "same")
+	w := newTestWatcher(t, root)
+
+	steps := []struct {
+		name string
+		do   func()
+		want []string
+	}{
+		{"copy created", func() { w.write(t, "b", "same") }, []string{"duplicate b: a"}},
+		{"other file created", func() { w.write(t, "c", "diff") }, nil},
+		{"second copy", func() { w.write(t, "sub/d", "same") }, []string{"duplicate sub/d: a b"}},
+		{"rewritten with the same content", func() { w.write(t, "b", "same") }, nil},
+		{"copy changed", func() { w.write(t, "b", "diff") }, []string{"resolved b: a sub/d", "duplicate b: c"}},
+		{"copy removed", func() { w.remove(t, "c", false) }, []string{"resolved c: b"}},
+		{"directory removed", func() { w.remove(t, "sub", true) }, []string{"resolved sub/d: a"}},
+	}
+	for _, step := range steps {
+		step.do()
+		if got := w.takeEvents(); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: got events %q, want %q", step.name, got, step.want)
+		}
+	}
+}
+
+func TestWatcherSettle(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a"), "same")
+	w := newTestWatcher(t, root)
+	w.Settle = time.Minute
+
+	// A file still being written is checked once it goes unchanged for the settle time
+	writeFile(t, w.path("b"), "same")
+	w.handle(change{path: w.path("b"), op: opChange})
+	w.checkSettled(time.Now())
+	if events := w.takeEvents(); len(events) != 0 {
+		t.Errorf("file reported before it settled: %q", events)
+	}
+	if next, ok := w.nextCheck(); !ok || time.Until(next) < 59*time.Second {
+		t.Errorf("next check at %v, want in a minute", next)
+	}
+
+	w.checkSettled(time.Now().Add(time.Minute))
+	if got, want := w.takeEvents(), []string{"duplicate b: a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %q, want %q", got, want)
+	}
+	if _, ok := w.nextCheck(); ok {
+		t.Error("files are still pending")
+	}
+}
+
+func TestWatcherDirectoryMovedIn(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a"), "same")
+	w := newTestWatcher(t, root)
+
+	// The files of a new directory are found by walking it
+	writeFile(t, w.path("new/b"), "same")
+	w.handle(change{path: w.path("new"), dir: true, op: opDone})
+	if got, want := w.takeEvents(), []string{"duplicate new/b: a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %q, want %q", got, want)
+	}
+	if !w.notifier.watching(w.path("new")) {
+		t.Error("new directory is not watched")
+	}
+}